@@ -0,0 +1,384 @@
+package viabtc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccountingFormat is the plain-text double-entry accounting format.
+type AccountingFormat uint32
+
+const (
+	// FormatBeancount is the format of beancount bookkeeping tool.
+	FormatBeancount AccountingFormat = 1
+
+	// FormatLedger is the format of ledger-cli bookkeeping tool.
+	FormatLedger AccountingFormat = 2
+)
+
+// AccountMap determines the accounts which are used in exported
+// transactions. Every account might contain "{asset}" placeholder which is
+// replaced with the name of the asset.
+type AccountMap struct {
+	// Holdings is the account of the user funds on the exchange.
+	Holdings string
+
+	// Deposits is the counterpart account of deposits.
+	Deposits string
+
+	// Withdrawals is the counterpart account of withdrawals.
+	Withdrawals string
+
+	// Fees is the account of trading fees.
+	Fees string
+}
+
+// DefaultAccountMap is the account mapping used if none is given.
+var DefaultAccountMap = AccountMap{
+	Holdings:    "Assets:Exchange:ViaBTC:{asset}",
+	Deposits:    "Equity:Transfers:{asset}",
+	Withdrawals: "Equity:Transfers:{asset}",
+	Fees:        "Expenses:Fees:ViaBTC",
+}
+
+func (m *AccountMap) account(template string, asset AssetType) string {
+	return strings.Replace(template, "{asset}", string(asset), -1)
+}
+
+// posting is the single line of the transaction.
+type posting struct {
+	account string
+	amount  string
+	asset   AssetType
+
+	// cost is the total cost of the posting in another asset, it is used
+	// to balance the trades.
+	cost      string
+	costAsset AssetType
+}
+
+// transaction is the double-entry accounting transaction.
+type transaction struct {
+	time      float64
+	narration string
+	meta      map[string]string
+	postings  []posting
+}
+
+// AccountingExporter writes the exchange activity in the plain-text
+// double-entry accounting format, so that it might be pulled straight into
+// bookkeeping tools. In beancount format the account is opened with the
+// date of the first transaction which uses it, so transactions should be
+// written in the order of time.
+type AccountingExporter struct {
+	w        io.Writer
+	format   AccountingFormat
+	accounts AccountMap
+
+	// opened is the set of accounts for which open directive has been
+	// written, beancount rejects postings to the accounts which aren't
+	// opened.
+	opened map[string]struct{}
+}
+
+// NewAccountingExporter creates new exporter which writes transactions in
+// the given format, default account mapping is used if accounts are nil.
+func NewAccountingExporter(w io.Writer, format AccountingFormat,
+	accounts *AccountMap) *AccountingExporter {
+
+	m := DefaultAccountMap
+	if accounts != nil {
+		m = *accounts
+	}
+
+	return &AccountingExporter{
+		w:        w,
+		format:   format,
+		accounts: m,
+		opened:   make(map[string]struct{}),
+	}
+}
+
+// negate returns the decimal number with the opposite sign.
+func negate(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return s[1:]
+	}
+
+	return "-" + s
+}
+
+func (x *AccountingExporter) write(t *transaction) error {
+	date := time.Unix(int64(t.time), 0).UTC()
+
+	var b strings.Builder
+	switch x.format {
+	case FormatBeancount:
+		x.open(&b, date, t)
+		fmt.Fprintf(&b, "%v * %q\n", date.Format("2006-01-02"), t.narration)
+	case FormatLedger:
+		fmt.Fprintf(&b, "%v %v\n", date.Format("2006/01/02"), t.narration)
+	default:
+		return fmt.Errorf("unknown accounting format: %v", x.format)
+	}
+
+	keys := make([]string, 0, len(t.meta))
+	for key := range t.meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if x.format == FormatBeancount {
+			fmt.Fprintf(&b, "  %v: %q\n", key, t.meta[key])
+		} else {
+			fmt.Fprintf(&b, "    ; %v: %v\n", key, t.meta[key])
+		}
+	}
+
+	for _, p := range t.postings {
+		fmt.Fprintf(&b, "  %v  %v %v", p.account, p.amount, p.asset)
+		if p.cost != "" {
+			fmt.Fprintf(&b, " @@ %v %v", p.cost, p.costAsset)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(x.w, b.String())
+	return err
+}
+
+// open writes the beancount open directives of the accounts of the
+// transaction which haven't been opened yet.
+func (x *AccountingExporter) open(b *strings.Builder, date time.Time,
+	t *transaction) {
+
+	var opened bool
+	for _, p := range t.postings {
+		if _, ok := x.opened[p.account]; ok {
+			continue
+		}
+
+		fmt.Fprintf(b, "%v open %v\n", date.Format("2006-01-02"), p.account)
+		x.opened[p.account] = struct{}{}
+		opened = true
+	}
+
+	if opened {
+		b.WriteString("\n")
+	}
+}
+
+// balanceTransaction converts the deposit or withdrawal record into the
+// transaction.
+func (x *AccountingExporter) balanceTransaction(
+	r *BalanceHistoryRecord) (*transaction, error) {
+
+	asset := AssetType(r.Asset)
+
+	var counterpart string
+	switch r.ActionType {
+	case ActionDeposit:
+		counterpart = x.accounts.account(x.accounts.Deposits, asset)
+	case ActionWithdrawal:
+		counterpart = x.accounts.account(x.accounts.Withdrawals, asset)
+	default:
+		return nil, fmt.Errorf("balance change %v isn't exported, "+
+			"trades are exported from deals", r.ActionType)
+	}
+
+	return &transaction{
+		time:      r.Time,
+		narration: fmt.Sprintf("ViaBTC %v %v", r.ActionType, asset),
+		postings: []posting{
+			{
+				account: x.accounts.account(x.accounts.Holdings, asset),
+				amount:  r.Change,
+				asset:   asset,
+			},
+			{
+				account: counterpart,
+				amount:  negate(r.Change),
+				asset:   asset,
+			},
+		},
+	}, nil
+}
+
+// WriteBalanceRecord writes the deposit or withdrawal record of the
+// balance history. Trade records are rejected, because trades are exported
+// from deals with more details.
+func (x *AccountingExporter) WriteBalanceRecord(r *BalanceHistoryRecord) error {
+	t, err := x.balanceTransaction(r)
+	if err != nil {
+		return err
+	}
+
+	return x.write(t)
+}
+
+// dealTransaction converts the user's deal into the transaction. On bid
+// side user receives stock for money and pays fee in stock, on ask side
+// user receives money for stock and pays fee in money.
+func (x *AccountingExporter) dealTransaction(market MarketType,
+	d *DealDetail) (*transaction, error) {
+
+	stock := x.accounts.account(x.accounts.Holdings, market.Stock)
+	money := x.accounts.account(x.accounts.Holdings, market.Money)
+
+	t := &transaction{
+		time: d.Time,
+		meta: map[string]string{
+			"deal_id":  fmt.Sprint(d.DealID),
+			"order_id": fmt.Sprint(d.DealOrderID),
+			"role":     d.Role.String(),
+		},
+	}
+
+	var feeAsset AssetType
+	switch d.Side {
+	case MarketOrderSideBid:
+		t.narration = fmt.Sprintf("ViaBTC buy %v %v @ %v %v", d.Amount,
+			market.Stock, d.Price, market.Money)
+		t.postings = []posting{
+			{
+				account:   stock,
+				amount:    d.Amount,
+				asset:     market.Stock,
+				cost:      d.Deal,
+				costAsset: market.Money,
+			},
+			{account: money, amount: negate(d.Deal), asset: market.Money},
+		}
+		feeAsset = market.Stock
+
+	case MarketOrderSideAsk:
+		t.narration = fmt.Sprintf("ViaBTC sell %v %v @ %v %v", d.Amount,
+			market.Stock, d.Price, market.Money)
+		t.postings = []posting{
+			{
+				account:   stock,
+				amount:    negate(d.Amount),
+				asset:     market.Stock,
+				cost:      d.Deal,
+				costAsset: market.Money,
+			},
+			{account: money, amount: d.Deal, asset: market.Money},
+		}
+		feeAsset = market.Money
+
+	default:
+		return nil, fmt.Errorf("deal %v has unknown side: %v", d.DealID,
+			d.Side)
+	}
+
+	if fee, err := parseDecimal(d.Fee); err == nil && fee.Sign() != 0 {
+		t.postings = append(t.postings,
+			posting{
+				account: x.accounts.Fees,
+				amount:  d.Fee,
+				asset:   feeAsset,
+			},
+			posting{
+				account: x.accounts.account(x.accounts.Holdings, feeAsset),
+				amount:  negate(d.Fee),
+				asset:   feeAsset,
+			},
+		)
+	}
+
+	return t, nil
+}
+
+// WriteDeal writes the user's deal on the market.
+func (x *AccountingExporter) WriteDeal(market MarketType, d *DealDetail) error {
+	t, err := x.dealTransaction(market, d)
+	if err != nil {
+		return err
+	}
+
+	return x.write(t)
+}
+
+// AccountingExportConfig is an structure which holds parameters of the
+// accounting export.
+type AccountingExportConfig struct {
+	UserID uint32
+
+	// Assets which deposits and withdrawals are exported.
+	Assets []AssetType
+
+	// Markets which user deals are exported.
+	Markets []MarketType
+
+	Start time.Time
+	End   time.Time
+
+	Format AccountingFormat
+
+	// Accounts is the account mapping, default is used if nil.
+	Accounts *AccountMap
+}
+
+// ExportAccounting fetches the user's deposits, withdrawals and deals
+// within the time range and writes them ordered by time in the
+// double-entry accounting format.
+func ExportAccounting(client *Client, w io.Writer,
+	cfg *AccountingExportConfig) error {
+
+	x := NewAccountingExporter(w, cfg.Format, cfg.Accounts)
+
+	var transactions []*transaction
+	for _, asset := range cfg.Assets {
+		for _, action := range []ActionType{ActionDeposit, ActionWithdrawal} {
+			it := NewBalanceHistoryQuery(cfg.UserID).Asset(asset).
+				Action(action).Since(cfg.Start).Until(cfg.End).Iter(client)
+
+			for it.Next() {
+				t, err := x.balanceTransaction(it.Record())
+				if err != nil {
+					return err
+				}
+				transactions = append(transactions, t)
+			}
+
+			if err := it.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, market := range cfg.Markets {
+		it := NewUserDealsQuery(cfg.UserID).Market(market).
+			Since(cfg.Start).Until(cfg.End).Iter(client)
+
+		for it.Next() {
+			deal := it.Deal()
+			t, err := x.dealTransaction(market, &deal)
+			if err != nil {
+				return err
+			}
+			transactions = append(transactions, t)
+		}
+
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].time < transactions[j].time
+	})
+
+	for _, t := range transactions {
+		if err := x.write(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package viabtc
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultShardBits is the number of bits of the action id which hold the
+// shard number if not specified otherwise, it allows 256 shards and about
+// eight million actions per shard.
+const DefaultShardBits uint = 8
+
+// actionIDBits is the number of bits of the positive action id.
+const actionIDBits uint = 31
+
+// ActionIDAllocator allocates action ids of balance updates which embed the
+// number of the service instance, or shard, in the high bits of the id. The
+// engine discards balance updates with repeated action id, so replicas of
+// the same service which use different shards never collide on it.
+//
+// NOTE: The sequence isn't persisted, the last allocated id should be stored
+// by the service and passed on restart, otherwise the new updates will be
+// discarded as repeated.
+type ActionIDAllocator struct {
+	sync.Mutex
+
+	shard     uint32
+	shardBits uint
+	next      uint32
+	max       uint32
+}
+
+// NewActionIDAllocator creates the allocator of the shard. The shard bits
+// determine the maximum number of shards, zero means DefaultShardBits. The
+// last is the last id allocated by this shard before, zero if none.
+func NewActionIDAllocator(shard uint32, shardBits uint, last int32) (
+	*ActionIDAllocator, error) {
+
+	if shardBits == 0 {
+		shardBits = DefaultShardBits
+	}
+
+	if shardBits >= actionIDBits {
+		return nil, fmt.Errorf("shard bits should be less than %v",
+			actionIDBits)
+	}
+
+	if shard >= 1<<shardBits {
+		return nil, fmt.Errorf("shard %v doesn't fit into %v bits",
+			shard, shardBits)
+	}
+
+	a := &ActionIDAllocator{
+		shard:     shard,
+		shardBits: shardBits,
+		next:      1,
+		max:       1<<(actionIDBits-shardBits) - 1,
+	}
+
+	if last != 0 {
+		if a.Shard(last) != shard {
+			return nil, fmt.Errorf("last id %v belongs to shard %v, "+
+				"not %v", last, a.Shard(last), shard)
+		}
+		a.next = a.Sequence(last) + 1
+	}
+
+	return a, nil
+}
+
+// Next returns the next action id of the shard.
+func (a *ActionIDAllocator) Next() (int32, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.next > a.max {
+		return 0, fmt.Errorf("action ids of shard %v are exhausted",
+			a.shard)
+	}
+
+	seq := a.next
+	a.next++
+
+	return int32(a.shard<<(actionIDBits-a.shardBits) | seq), nil
+}
+
+// Shard returns the shard number embedded into the action id.
+func (a *ActionIDAllocator) Shard(id int32) uint32 {
+	return uint32(id) >> (actionIDBits - a.shardBits)
+}
+
+// Sequence returns the sequence number of the action id within the shard.
+func (a *ActionIDAllocator) Sequence(id int32) uint32 {
+	return uint32(id) & a.max
+}
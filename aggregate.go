@@ -0,0 +1,147 @@
+package viabtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultFanOutConcurrency is the number of simultaneous requests which is
+// used by the helpers which query many markets or orders at once, if not
+// specified otherwise.
+const DefaultFanOutConcurrency = 4
+
+// fanOut calls the function for every index in [0, n) using at most
+// configured number of goroutines, and returns the first occurred error.
+func (e *Client) fanOut(n int, f func(i int) error) error {
+	concurrency := e.cfg.FanOutConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	semaphore := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			if err := f(i); err != nil {
+				once.Do(func() {
+					firstErr = err
+				})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// allPending fetches all pages of user's pending orders on the market.
+func (e *Client) allPending(userID uint32, market string) (
+	[]*OrderDetailedInfo, error) {
+
+	return e.allPendingContext(context.Background(), userID, market)
+}
+
+// allPendingContext fetches all pages of user's pending orders on the
+// market within the context.
+func (e *Client) allPendingContext(ctx context.Context, userID uint32,
+	market string) ([]*OrderDetailedInfo, error) {
+
+	var orders []*OrderDetailedInfo
+	req := &OrderPendingRequest{
+		UserID: userID,
+		Market: market,
+		Limit:  MaxLimit,
+	}
+
+	for {
+		resp, err := e.OrderPendingContext(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, resp.Orders...)
+		if int32(len(resp.Orders)) < req.Limit {
+			return orders, nil
+		}
+
+		req.Offset += int32(len(resp.Orders))
+	}
+}
+
+// listMarkets returns the markets available in the engine.
+func (e *Client) listMarkets() ([]MarketType, error) {
+	return e.listMarketsContext(context.Background())
+}
+
+// listMarketsContext returns the markets available in the engine within
+// the context.
+func (e *Client) listMarketsContext(ctx context.Context) ([]MarketType,
+	error) {
+
+	list, err := e.MarketListContext(ctx, &MarketListRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		return nil, nil
+	}
+
+	markets := make([]MarketType, len(*list))
+	for i, market := range *list {
+		markets[i] = market.MarketName
+	}
+
+	return markets, nil
+}
+
+// AllPendingOrders returns the user's pending orders across all markets
+// available in the engine. Markets are queried concurrently, and markets
+// without pending orders are omitted from the result.
+func (e *Client) AllPendingOrders(userID uint32) (
+	map[MarketType][]*OrderDetailedInfo, error) {
+
+	markets, err := e.listMarkets()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list markets: %w", err)
+	}
+
+	var mtx sync.Mutex
+	result := make(map[MarketType][]*OrderDetailedInfo)
+
+	err = e.fanOut(len(markets), func(i int) error {
+		orders, err := e.allPending(userID, markets[i].String())
+		if err != nil {
+			return fmt.Errorf("unable to fetch pending orders of "+
+				"market %v: %w", markets[i], err)
+		}
+
+		if len(orders) == 0 {
+			return nil
+		}
+
+		mtx.Lock()
+		result[markets[i]] = orders
+		mtx.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
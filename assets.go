@@ -0,0 +1,121 @@
+package viabtc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Assets is the view of the metadata registry which is used to work with
+// amounts of assets, according to the asset precisions reported by the
+// engine in asset.list.
+type Assets struct {
+	registry *Registry
+}
+
+// Assets returns the asset precision helpers backed by the registry.
+func (r *Registry) Assets() Assets {
+	return Assets{registry: r}
+}
+
+// Assets returns the asset precision helpers backed by the client's
+// registry, which should be loaded beforehand.
+func (e *Client) Assets() Assets {
+	return e.registry.Assets()
+}
+
+// info returns the metadata of the asset.
+func (a Assets) info(asset AssetType) (AssetInfo, error) {
+	info, ok := a.registry.Asset(asset)
+	if !ok {
+		return AssetInfo{}, fmt.Errorf("asset %v isn't supported by the "+
+			"engine", asset)
+	}
+
+	return info, nil
+}
+
+// Precision returns the number of decimal places with which the amounts of
+// the asset are displayed, the same as ShowPrecision.
+func (a Assets) Precision(asset AssetType) (int, error) {
+	return a.ShowPrecision(asset)
+}
+
+// ShowPrecision returns the number of decimal places with which the
+// amounts of the asset are displayed.
+func (a Assets) ShowPrecision(asset AssetType) (int, error) {
+	info, err := a.info(asset)
+	if err != nil {
+		return 0, err
+	}
+
+	if info.PrecShow > 0 {
+		return int(info.PrecShow), nil
+	}
+
+	return int(info.Prec), nil
+}
+
+// SavePrecision returns the number of decimal places with which the
+// amounts of the asset are stored by the engine, it isn't less than the
+// show precision.
+func (a Assets) SavePrecision(asset AssetType) (int, error) {
+	info, err := a.info(asset)
+	if err != nil {
+		return 0, err
+	}
+
+	prec := int(info.Prec)
+	if int(info.PrecShow) > prec {
+		prec = int(info.PrecShow)
+	}
+	if int(info.PrecSave) > prec {
+		prec = int(info.PrecSave)
+	}
+
+	return prec, nil
+}
+
+// FormatAmount renders the amount with exactly as many decimal places as
+// the show precision of the asset, rounding the extra digits.
+func (a Assets) FormatAmount(asset AssetType, amount string) (string, error) {
+	prec, err := a.ShowPrecision(asset)
+	if err != nil {
+		return "", err
+	}
+
+	v, err := parseDecimal(amount)
+	if err != nil {
+		return "", err
+	}
+
+	return v.FloatString(prec), nil
+}
+
+// ParseAmount parses the amount entered by the user and returns it in the
+// canonical form accepted by the engine, with the save precision of the
+// asset. Only the plain non-negative decimal notation is accepted, e.g.
+// "1.5". Input with more decimal places than the save precision is
+// rejected instead of being silently rounded.
+func (a Assets) ParseAmount(asset AssetType, input string) (string, error) {
+	prec, err := a.SavePrecision(asset)
+	if err != nil {
+		return "", err
+	}
+
+	input = strings.TrimSpace(input)
+	v, err := parseStrictDecimal(input)
+	if err != nil {
+		return "", fmt.Errorf("amount should be non-negative decimal "+
+			"number, got %q", input)
+	}
+
+	if i := strings.IndexByte(input, '.'); i != -1 {
+		if decimals := len(strings.TrimRight(input[i+1:], "0")); decimals > prec {
+			return "", fmt.Errorf("amount %q of %v has %v decimal "+
+				"places, asset precision is %v", input, asset, decimals,
+				prec)
+		}
+	}
+
+	return v.FloatString(prec), nil
+}
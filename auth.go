@@ -0,0 +1,31 @@
+package viabtc
+
+import (
+	"net/http"
+)
+
+// BasicAuth is the credentials of the http basic authentication, which is
+// usually required by the reverse proxy in front of the engine.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// applyAuthHeaders adds the default headers and the credentials of the
+// config to the request.
+func (cfg *Config) applyAuthHeaders(req *http.Request) {
+	for key, values := range cfg.Headers {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	switch {
+	case cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+
+	case cfg.BasicAuth != nil:
+		req.SetBasicAuth(cfg.BasicAuth.Username, cfg.BasicAuth.Password)
+	}
+}
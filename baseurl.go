@@ -0,0 +1,42 @@
+package viabtc
+
+import (
+	"net/url"
+	"strings"
+)
+
+// baseURL returns the parsed base url of the config, nil if it isn't
+// specified or malformed.
+func (cfg *Config) baseURL() *url.URL {
+	if cfg.BaseURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil
+	}
+
+	return u
+}
+
+// path returns the path of the rpc endpoint, empty if the engine is
+// mounted at the root.
+func (cfg *Config) path() string {
+	path := cfg.Path
+	if u := cfg.baseURL(); u != nil {
+		path = u.EscapedPath()
+	}
+
+	path = strings.TrimRight(path, "/")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return path
+}
+
+// endpointURL returns the url of the rpc endpoint on the given host.
+func (cfg *Config) endpointURL(host string) string {
+	return cfg.scheme() + "://" + host + cfg.path()
+}
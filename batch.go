@@ -0,0 +1,126 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BalanceUpdateResult is the result of the single balance update of the
+// batch.
+type BalanceUpdateResult struct {
+	Request *BalanceUpdateRequest
+
+	// Response is the response of the applied update, nil if it has
+	// failed.
+	Response *BalanceUpdateResponse
+
+	// Err is the error of the update, the rejection of the engine is
+	// *RPCError of balance.update, so that it might be matched with
+	// ErrRepeatUpdate or ErrBalanceNotEnough.
+	Err error
+}
+
+// BalanceUpdateBatch applies the balance changes, e.g. the deposits of the
+// sweep, and returns the result of every change in the order of the given
+// requests.
+func (e *Client) BalanceUpdateBatch(
+	updates []*BalanceUpdateRequest) []*BalanceUpdateResult {
+
+	return e.BalanceUpdateBatchContext(context.Background(), updates)
+}
+
+// BalanceUpdateBatchContext is the same as BalanceUpdateBatch, but the
+// requests are bound to the given context.
+//
+// Changes are sent with the single asset.update_batch request. Engines which
+// don't support it reject it with ErrMethodNotFound, in which case, and if
+// the batch request fails, the changes are applied by the concurrent
+// balance.update requests. Repeating is safe, because the engine
+// deduplicates the changes by the action id, the change which has already
+// been applied fails with ErrRepeatUpdate.
+func (e *Client) BalanceUpdateBatchContext(ctx context.Context,
+	updates []*BalanceUpdateRequest) []*BalanceUpdateResult {
+
+	results := make([]*BalanceUpdateResult, len(updates))
+	for i, update := range updates {
+		results[i] = &BalanceUpdateResult{Request: update}
+	}
+
+	if len(updates) == 0 {
+		return results
+	}
+
+	if !e.noBalanceBatch.Load() {
+		err := e.balanceUpdateBatch(ctx, results)
+		if err == nil {
+			return results
+		}
+
+		if errors.Is(err, ErrMethodNotFound) {
+			e.noBalanceBatch.Store(true)
+		}
+	}
+
+	ctx = WithRetrySafe(ctx)
+	e.fanOut(len(results), func(i int) error {
+		results[i].Response, results[i].Err = e.BalanceUpdateContext(ctx,
+			results[i].Request)
+		return nil
+	})
+
+	return results
+}
+
+// balanceUpdateBatch sends the changes with the single batch request, its
+// params are the params of balance.update of every change, and the result
+// is the result or the error of every change.
+func (e *Client) balanceUpdateBatch(ctx context.Context,
+	results []*BalanceUpdateResult) error {
+
+	params := make([][]interface{}, len(results))
+	for i, result := range results {
+		args, err := extractArguments(result.Request)
+		if err != nil {
+			return err
+		}
+		params[i] = args
+	}
+
+	type Item struct {
+		Error  *RPCError              `json:"error"`
+		Result *BalanceUpdateResponse `json:"result"`
+	}
+
+	type Response struct {
+		baseResponse
+		Result []Item
+	}
+
+	response := &Response{}
+	err := e.makeRPCCallContext(ctx, "asset.update_batch", params, response)
+	if err != nil {
+		return err
+	}
+
+	// https://golang.org/doc/faq#nil_error
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if len(response.Result) != len(results) {
+		return fmt.Errorf("batch of %v balance updates returned %v "+
+			"results", len(results), len(response.Result))
+	}
+
+	for i, item := range response.Result {
+		if item.Error != nil {
+			item.Error.Method = "balance.update"
+			results[i].Err = item.Error
+			continue
+		}
+		results[i].Response = item.Result
+	}
+
+	return nil
+}
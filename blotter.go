@@ -0,0 +1,487 @@
+package viabtc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBlotterPollInterval is the interval with which the user deals
+	// are polled if not specified otherwise.
+	defaultBlotterPollInterval = time.Second
+
+	// defaultBlotterMaxSize is the size of the blotter file after which it
+	// is rotated if not specified otherwise.
+	defaultBlotterMaxSize int64 = 64 << 20
+
+	// defaultBlotterPrefix is the name prefix of the blotter files if not
+	// specified otherwise.
+	defaultBlotterPrefix = "blotter"
+
+	// blotterPageSize is the number of deals which are fetched per page.
+	blotterPageSize int32 = 100
+
+	// blotterExt is the extension of the blotter files.
+	blotterExt = ".jsonl"
+)
+
+// BlotterConfig is an structure which holds configurable parameters of the
+// trade blotter.
+type BlotterConfig struct {
+	// Dir is the directory where blotter files are stored.
+	Dir string
+
+	// Prefix is the name prefix of the blotter files.
+	Prefix string
+
+	// MaxSize is the size of the active blotter file in bytes after which
+	// it is rotated.
+	MaxSize int64
+
+	// UserID is the user whose deals are polled.
+	UserID uint32
+
+	// Markets is the list of markets which deals are polled.
+	Markets []MarketType
+
+	// PollInterval is the interval with which the user deals are polled.
+	PollInterval time.Duration
+}
+
+// BlotterEntry is the single execution written in the blotter.
+type BlotterEntry struct {
+	Market   string     `json:"market"`
+	Recorded time.Time  `json:"recorded"`
+	Deal     DealDetail `json:"deal"`
+}
+
+// Blotter appends every own fill to the file on disk as soon as it is
+// detected, so that there is always a record of executions even if the
+// process crashes. Entries are written as JSON lines and synced to disk
+// one by one, the active file is rotated when it exceeds the size limit.
+// Fills are either detected by polling of the user deals, or passed
+// directly with Record.
+type Blotter struct {
+	client *Client
+	cfg    BlotterConfig
+
+	mtx  sync.Mutex
+	file *os.File
+	size int64
+
+	// last is the id of the last recorded deal per market, it is used to
+	// skip already recorded deals after restart.
+	last map[string]int32
+
+	started bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	lastErr error
+}
+
+// NewBlotter opens the blotter in the configured directory. Already written
+// blotter files are scanned to find the last recorded deals, so that the
+// polling resumes without duplicates.
+func NewBlotter(client *Client, cfg *BlotterConfig) (*Blotter, error) {
+	c := *cfg
+
+	if c.Prefix == "" {
+		c.Prefix = defaultBlotterPrefix
+	}
+
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultBlotterMaxSize
+	}
+
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultBlotterPollInterval
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create blotter directory: %w",
+			err)
+	}
+
+	b := &Blotter{
+		client: client,
+		cfg:    c,
+		last:   make(map[string]int32),
+	}
+
+	if err := b.recover(); err != nil {
+		return nil, err
+	}
+
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// activePath returns the path of the file which entries are appended to.
+func (b *Blotter) activePath() string {
+	return filepath.Join(b.cfg.Dir, b.cfg.Prefix+blotterExt)
+}
+
+// files returns the active and rotated blotter files.
+func (b *Blotter) files() ([]string, error) {
+	pattern := filepath.Join(b.cfg.Dir, b.cfg.Prefix+"*"+blotterExt)
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// recover reads the blotter files and restores the last recorded deal of
+// every market. Partially written last line, which might be left after the
+// crash, is ignored.
+func (b *Blotter) recover() error {
+	paths, err := b.files()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("unable to open blotter file: %w", err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var entry BlotterEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+
+			if entry.Deal.DealID > b.last[entry.Market] {
+				b.last[entry.Market] = entry.Deal.DealID
+			}
+		}
+
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to read blotter file %v: %w", path,
+				err)
+		}
+	}
+
+	return nil
+}
+
+// open opens the active blotter file for appending.
+func (b *Blotter) open() error {
+	if err := repairTail(b.activePath()); err != nil {
+		return fmt.Errorf("unable to repair blotter file: %w", err)
+	}
+
+	f, err := os.OpenFile(b.activePath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0600)
+	if err != nil {
+		return fmt.Errorf("unable to open blotter file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	b.file = f
+	b.size = info.Size()
+	return nil
+}
+
+// repairTail makes the file end with the complete line, so that the next
+// entry isn't appended to the line partially written before the crash. The
+// unterminated last line is terminated if it is the valid entry, because it
+// has been accounted by recover, otherwise it is truncated.
+func repairTail(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Find the end of the last complete line.
+	var (
+		end   = info.Size()
+		chunk = make([]byte, 4096)
+		tail  []byte
+	)
+	for end > 0 {
+		n := int64(len(chunk))
+		if end < n {
+			n = end
+		}
+
+		if _, err := f.ReadAt(chunk[:n], end-n); err != nil {
+			return err
+		}
+
+		i := bytes.LastIndexByte(chunk[:n], '\n')
+		if i >= 0 {
+			tail = append(append([]byte(nil), chunk[i+1:n]...), tail...)
+			end = end - n + int64(i) + 1
+			break
+		}
+
+		tail = append(append([]byte(nil), chunk[:n]...), tail...)
+		end -= n
+	}
+
+	if len(tail) == 0 {
+		return nil
+	}
+
+	var entry BlotterEntry
+	if json.Unmarshal(tail, &entry) == nil {
+		if _, err := f.WriteAt([]byte{'\n'}, info.Size()); err != nil {
+			return err
+		}
+	} else if err := f.Truncate(end); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// rotate renames the active file with the rotation timestamp and opens the
+// new one.
+func (b *Blotter) rotate() error {
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+
+	stamp := b.client.now().UTC().Format("20060102T150405.000000000")
+	stamp = strings.Replace(stamp, ".", "", 1)
+	rotated := filepath.Join(b.cfg.Dir,
+		fmt.Sprintf("%v-%v%v", b.cfg.Prefix, stamp, blotterExt))
+
+	if err := os.Rename(b.activePath(), rotated); err != nil {
+		return fmt.Errorf("unable to rotate blotter file: %w", err)
+	}
+
+	return b.open()
+}
+
+// Record appends the deal of the market to the blotter and syncs it to
+// disk. Deals which are already recorded are skipped.
+func (b *Blotter) Record(market string, deal *DealDetail) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.record(market, deal)
+}
+
+func (b *Blotter) record(market string, deal *DealDetail) error {
+	if b.file == nil {
+		return errors.New("blotter is closed")
+	}
+
+	if deal.DealID <= b.last[market] {
+		return nil
+	}
+
+	data, err := json.Marshal(&BlotterEntry{
+		Market:   market,
+		Recorded: b.client.now().UTC(),
+		Deal:     *deal,
+	})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if b.size > 0 && b.size+int64(len(data)) > b.cfg.MaxSize {
+		if err := b.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := b.file.Write(data)
+	b.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("unable to write blotter entry: %w", err)
+	}
+
+	if err := b.file.Sync(); err != nil {
+		return fmt.Errorf("unable to sync blotter file: %w", err)
+	}
+
+	b.last[market] = deal.DealID
+	return nil
+}
+
+// Poll fetches the deals of every configured market which have been made
+// since the last recorded one and records them, oldest first.
+func (b *Blotter) Poll() error {
+	for _, market := range b.cfg.Markets {
+		name := market.String()
+
+		b.mtx.Lock()
+		last := b.last[name]
+		b.mtx.Unlock()
+
+		deals, err := b.fetchSince(name, last)
+		if err != nil {
+			return err
+		}
+
+		b.mtx.Lock()
+		// Deals are fetched from newest to oldest.
+		for i := len(deals) - 1; i >= 0; i-- {
+			if err := b.record(name, &deals[i]); err != nil {
+				b.mtx.Unlock()
+				return err
+			}
+		}
+		b.mtx.Unlock()
+	}
+
+	return nil
+}
+
+// fetchSince fetches the user deals of the market newer than the given
+// one, page by page from the newest, until the given deal is reached.
+func (b *Blotter) fetchSince(market string, last int32) ([]DealDetail,
+	error) {
+
+	var (
+		deals []DealDetail
+		seen  = make(map[int32]struct{})
+	)
+
+	for offset := int32(0); ; offset += blotterPageSize {
+		resp, err := b.client.MarketUserDeals(&MarketUserDealsRequest{
+			UserID: b.cfg.UserID,
+			Market: market,
+			Offset: offset,
+			Limit:  blotterPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if resp == nil {
+			return deals, nil
+		}
+
+		reached := false
+		for _, deal := range resp.Deals {
+			if deal.DealID <= last {
+				reached = true
+				break
+			}
+
+			// Deals made while paging shift the pages, so the deal
+			// might be returned twice.
+			if _, ok := seen[deal.DealID]; ok {
+				continue
+			}
+			seen[deal.DealID] = struct{}{}
+			deals = append(deals, deal)
+		}
+
+		if reached || len(resp.Deals) < int(blotterPageSize) {
+			return deals, nil
+		}
+	}
+}
+
+// Start starts polling of the user deals in the background.
+func (b *Blotter) Start() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.started {
+		return errors.New("blotter is already started")
+	}
+	b.started = true
+	b.quit = make(chan struct{})
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(b.cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			err := b.Poll()
+
+			b.mtx.Lock()
+			b.lastErr = err
+			b.mtx.Unlock()
+
+			select {
+			case <-ticker.C:
+			case <-b.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops polling of the user deals.
+func (b *Blotter) Stop() {
+	b.mtx.Lock()
+	if !b.started {
+		b.mtx.Unlock()
+		return
+	}
+	b.started = false
+	close(b.quit)
+	b.mtx.Unlock()
+
+	b.wg.Wait()
+}
+
+// Err returns the error of the last poll, nil if it was successful.
+func (b *Blotter) Err() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.lastErr
+}
+
+// Close stops polling and closes the active blotter file.
+func (b *Blotter) Close() error {
+	b.Stop()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
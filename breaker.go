@@ -0,0 +1,267 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failures which
+	// trip the breaker if not specified.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCoolDown is the time the breaker stays open if not
+	// specified.
+	DefaultBreakerCoolDown = 10 * time.Second
+)
+
+// ErrBreakerOpen is returned without making the request while the circuit
+// breaker is open.
+var ErrBreakerOpen = errors.New("circuit breaker is open, requests to the " +
+	"engine are rejected")
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState uint32
+
+const (
+	// BreakerClosed is the normal state, requests are made.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen is the state after the repeated failures, requests fail
+	// fast with ErrBreakerOpen until the cool-down passes.
+	BreakerOpen
+
+	// BreakerHalfOpen is the state after the cool-down, the single probe
+	// request is made, its success closes the breaker and its failure
+	// opens it again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig is an structure which holds configurable parameters of the
+// circuit breaker.
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failed requests which trip
+	// the breaker. Only failures of the requests which have reached the
+	// engine, such as transport errors, 5xx responses and engine
+	// overload, are counted. Local failures, e.g. the leader check or the
+	// cancellation of the caller's context, aren't.
+	Threshold int
+
+	// CoolDown is the time the breaker stays open before the probe
+	// request is allowed.
+	CoolDown time.Duration
+
+	// OnStateChange, if specified, is called when the state of the
+	// breaker changes. It is called synchronously by the request which
+	// caused the change, so it shouldn't block.
+	OnStateChange func(from, to BreakerState)
+}
+
+// circuitBreaker stops making the requests while the engine is failing.
+type circuitBreaker struct {
+	cfg      BreakerConfig
+	counters *clientCounters
+
+	// now returns the current time, it is used for the cool-down.
+	now func() time.Time
+
+	mtx      sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(cfg *BreakerConfig, counters *clientCounters,
+	now func() time.Time) *circuitBreaker {
+
+	b := &circuitBreaker{
+		cfg:      *cfg,
+		counters: counters,
+		now:      now,
+	}
+
+	if b.cfg.Threshold <= 0 {
+		b.cfg.Threshold = DefaultBreakerThreshold
+	}
+	if b.cfg.CoolDown <= 0 {
+		b.cfg.CoolDown = DefaultBreakerCoolDown
+	}
+
+	return b
+}
+
+// setState changes the state, mutex should be held. The previous state is
+// returned.
+func (b *circuitBreaker) setState(state BreakerState) BreakerState {
+	prev := b.state
+	b.state = state
+
+	if state == BreakerOpen && prev != BreakerOpen {
+		b.openedAt = b.now()
+		atomic.AddInt64(&b.counters.breakerTrips, 1)
+	}
+
+	return prev
+}
+
+// notify calls the callback if the state has changed.
+func (b *circuitBreaker) notify(from, to BreakerState) {
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
+
+// allow returns ErrBreakerOpen if the request shouldn't be made.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mtx.Lock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.CoolDown {
+			b.mtx.Unlock()
+			return ErrBreakerOpen
+		}
+
+		prev := b.setState(BreakerHalfOpen)
+		b.probing = true
+		b.mtx.Unlock()
+
+		b.notify(prev, BreakerHalfOpen)
+		return nil
+
+	case BreakerHalfOpen:
+		defer b.mtx.Unlock()
+
+		// Only the single probe is allowed at once.
+		if b.probing {
+			return ErrBreakerOpen
+		}
+		b.probing = true
+		return nil
+
+	default:
+		b.mtx.Unlock()
+		return nil
+	}
+}
+
+// report accounts the outcome of the allowed request.
+func (b *circuitBreaker) report(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+
+	prev, next := b.state, b.state
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			next = BreakerOpen
+		} else {
+			next = BreakerClosed
+		}
+		b.failures = 0
+
+	case failed:
+		b.failures++
+		if b.failures >= b.cfg.Threshold {
+			next = BreakerOpen
+			b.failures = 0
+		}
+
+	default:
+		b.failures = 0
+	}
+
+	b.setState(next)
+	b.mtx.Unlock()
+
+	b.notify(prev, next)
+}
+
+// release gives up the probe of the allowed request which hasn't reached
+// the engine, so that its outcome isn't accounted.
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// sentKey is the context key of the flag which is set when the request of
+// the attempt is sent to the host.
+type sentKey struct{}
+
+// withSentFlag returns the context of the attempt and its flag.
+func withSentFlag(ctx context.Context) (context.Context, *atomic.Bool) {
+	sent := &atomic.Bool{}
+	return context.WithValue(ctx, sentKey{}, sent), sent
+}
+
+// markSent sets the flag of the attempt, if any, once all local checks
+// have passed and the request is about to be sent.
+func markSent(ctx context.Context) {
+	if sent, ok := ctx.Value(sentKey{}).(*atomic.Bool); ok {
+		sent.Store(true)
+	}
+}
+
+// breakerFailure returns true if the attempt, which has reached the host,
+// failed because of the host: transport error, 5xx response or transient
+// engine error. Cancellation of the caller's context isn't the failure.
+func breakerFailure(ctx context.Context, err error,
+	rpcResp interface{}) bool {
+
+	if err != nil {
+		return hostFailed(ctx, err)
+	}
+
+	return retryable(nil, rpcResp)
+}
+
+// current returns the state of the breaker.
+func (b *circuitBreaker) current() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.state
+}
+
+// BreakerState returns the state of the circuit breaker, it is always
+// closed if the breaker isn't configured.
+func (e *Client) BreakerState() BreakerState {
+	return e.breaker.current()
+}
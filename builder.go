@@ -0,0 +1,225 @@
+package viabtc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// validatePositive checks that the field is the positive decimal number.
+func validatePositive(field, value string) error {
+	v, err := parseStrictDecimal(value)
+	if err != nil {
+		return fmt.Errorf("%v should be decimal number, got %q", field,
+			value)
+	}
+
+	if v.Sign() <= 0 {
+		return fmt.Errorf("%v should be positive, got %q", field, value)
+	}
+
+	return nil
+}
+
+// validateFeeRate checks that the field is the fee coefficient from [0;1).
+func validateFeeRate(field, value string) error {
+	v, err := parseStrictDecimal(value)
+	if err != nil {
+		return fmt.Errorf("%v should be decimal number, got %q", field,
+			value)
+	}
+
+	if v.Sign() < 0 || v.Cmp(big.NewRat(1, 1)) >= 0 {
+		return fmt.Errorf("%v should be within [0;1), got %q", field,
+			value)
+	}
+
+	return nil
+}
+
+// LimitOrderBuilder is used to construct validated limit order requests,
+// for example:
+//
+//	req, err := NewLimitOrder(MarketBTCETH).User(1).Buy().
+//		Price("0.05").Amount("10").Build()
+//
+// Fee rates are zero unless specified.
+type LimitOrderBuilder struct {
+	req     OrderPutLimitRequest
+	userSet bool
+}
+
+// NewLimitOrder starts building the limit order on the given market.
+func NewLimitOrder(market MarketType) *LimitOrderBuilder {
+	return &LimitOrderBuilder{
+		req: OrderPutLimitRequest{
+			Market:       market.String(),
+			TakerFeeRate: "0",
+			MakerFeeRate: "0",
+		},
+	}
+}
+
+// User sets the user on behalf of whom the order is placed.
+func (b *LimitOrderBuilder) User(userID uint32) *LimitOrderBuilder {
+	b.req.UserID = userID
+	b.userSet = true
+	return b
+}
+
+// Buy makes the order to be bid, i.e. user gives money for the stock.
+func (b *LimitOrderBuilder) Buy() *LimitOrderBuilder {
+	b.req.Side = MarketOrderSideBid
+	return b
+}
+
+// Sell makes the order to be ask, i.e. user gives stock for the money.
+func (b *LimitOrderBuilder) Sell() *LimitOrderBuilder {
+	b.req.Side = MarketOrderSideAsk
+	return b
+}
+
+// Price sets the price in market money for one stock.
+func (b *LimitOrderBuilder) Price(price string) *LimitOrderBuilder {
+	b.req.Price = price
+	return b
+}
+
+// Amount sets the number of stock which user is willing to sell/buy.
+func (b *LimitOrderBuilder) Amount(amount string) *LimitOrderBuilder {
+	b.req.Amount = amount
+	return b
+}
+
+// TakerFee sets the fee coefficient applied to immediately executed part of
+// the order.
+func (b *LimitOrderBuilder) TakerFee(rate string) *LimitOrderBuilder {
+	b.req.TakerFeeRate = rate
+	return b
+}
+
+// MakerFee sets the fee coefficient applied to the part of the order which
+// was executed after matching with another order.
+func (b *LimitOrderBuilder) MakerFee(rate string) *LimitOrderBuilder {
+	b.req.MakerFeeRate = rate
+	return b
+}
+
+// Source sets the origin of the order.
+func (b *LimitOrderBuilder) Source(source string) *LimitOrderBuilder {
+	b.req.Source = source
+	return b
+}
+
+// Build validates the order and returns the request.
+func (b *LimitOrderBuilder) Build() (*OrderPutLimitRequest, error) {
+	if !b.userSet {
+		return nil, errors.New("user isn't specified")
+	}
+
+	if b.req.Side != MarketOrderSideAsk && b.req.Side != MarketOrderSideBid {
+		return nil, errors.New("side isn't specified, use Buy() or Sell()")
+	}
+
+	if err := validatePositive("price", b.req.Price); err != nil {
+		return nil, err
+	}
+
+	if err := validatePositive("amount", b.req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := validateFeeRate("taker fee", b.req.TakerFeeRate); err != nil {
+		return nil, err
+	}
+
+	if err := validateFeeRate("maker fee", b.req.MakerFeeRate); err != nil {
+		return nil, err
+	}
+
+	req := b.req
+	return &req, nil
+}
+
+// MarketOrderBuilder is used to construct validated market order requests,
+// for example:
+//
+//	req, err := NewMarketOrder(MarketBTCETH).User(1).Sell().
+//		Amount("10").Build()
+//
+// Taker fee rate is zero unless specified.
+type MarketOrderBuilder struct {
+	req     OrderPutMarketRequest
+	userSet bool
+}
+
+// NewMarketOrder starts building the market order on the given market.
+func NewMarketOrder(market MarketType) *MarketOrderBuilder {
+	return &MarketOrderBuilder{
+		req: OrderPutMarketRequest{
+			Market:       market.String(),
+			TakerFeeRate: "0",
+		},
+	}
+}
+
+// User sets the user on behalf of whom the order is placed.
+func (b *MarketOrderBuilder) User(userID uint32) *MarketOrderBuilder {
+	b.req.UserID = userID
+	b.userSet = true
+	return b
+}
+
+// Buy makes the order to be bid, in this case amount is expressed in money
+// which user wants to spend.
+func (b *MarketOrderBuilder) Buy() *MarketOrderBuilder {
+	b.req.Side = MarketOrderSideBid
+	return b
+}
+
+// Sell makes the order to be ask, in this case amount is expressed in stock
+// which user wants to sell.
+func (b *MarketOrderBuilder) Sell() *MarketOrderBuilder {
+	b.req.Side = MarketOrderSideAsk
+	return b
+}
+
+// Amount sets the amount of the order, see Buy and Sell for the units.
+func (b *MarketOrderBuilder) Amount(amount string) *MarketOrderBuilder {
+	b.req.Amount = amount
+	return b
+}
+
+// TakerFee sets the fee coefficient applied to the order.
+func (b *MarketOrderBuilder) TakerFee(rate string) *MarketOrderBuilder {
+	b.req.TakerFeeRate = rate
+	return b
+}
+
+// Source sets the origin of the order.
+func (b *MarketOrderBuilder) Source(source string) *MarketOrderBuilder {
+	b.req.Source = source
+	return b
+}
+
+// Build validates the order and returns the request.
+func (b *MarketOrderBuilder) Build() (*OrderPutMarketRequest, error) {
+	if !b.userSet {
+		return nil, errors.New("user isn't specified")
+	}
+
+	if b.req.Side != MarketOrderSideAsk && b.req.Side != MarketOrderSideBid {
+		return nil, errors.New("side isn't specified, use Buy() or Sell()")
+	}
+
+	if err := validatePositive("amount", b.req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := validateFeeRate("taker fee", b.req.TakerFeeRate); err != nil {
+		return nil, err
+	}
+
+	req := b.req
+	return &req, nil
+}
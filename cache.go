@@ -0,0 +1,275 @@
+package viabtc
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// klineCacheKey identifies the kline window.
+type klineCacheKey struct {
+	market   string
+	interval int32
+	start    float64
+	end      float64
+}
+
+// dealsCacheKey identifies the page of the market deals.
+type dealsCacheKey struct {
+	market string
+	limit  int32
+	lastID int32
+}
+
+// userDealsCacheKey identifies the page of the user deals.
+type userDealsCacheKey struct {
+	userID uint32
+	market string
+	offset int32
+	limit  int32
+}
+
+type cacheEntry struct {
+	key     interface{}
+	value   interface{}
+	expires time.Time
+}
+
+// lruCache is the cache of the responses which is bounded by the number of
+// entries, least recently used entries are evicted first.
+type lruCache struct {
+	sync.Mutex
+
+	size    int
+	entries map[interface{}]*list.Element
+	order   *list.List
+
+	// now returns the current time, it is used for expiration.
+	now func() time.Time
+}
+
+func newLRUCache(size int, now func() time.Time) *lruCache {
+	return &lruCache{
+		size:    size,
+		entries: make(map[interface{}]*list.Element),
+		order:   list.New(),
+		now:     now,
+	}
+}
+
+// get returns the value of the key, if it is cached and not expired.
+func (c *lruCache) get(key interface{}) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && c.now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// put caches the value of the key, zero ttl means that entry doesn't
+// expire.
+func (c *lruCache) put(key, value interface{}, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// len returns the number of cached entries.
+func (c *lruCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.order.Len()
+}
+
+// klineWindowClosed returns true if the kline window is entirely in the
+// past of the server clock, so that its klines won't change anymore. Client
+// clock might be ahead of the server, in which case the window which is
+// still open on the server would be cached with the incomplete last kline.
+func klineWindowClosed(params *MarketKLineRequest, now time.Time) bool {
+	if params.EndTime == 0 || params.Interval <= 0 {
+		return false
+	}
+
+	// The last interval is closed only when its end is passed.
+	closed := float64(now.Unix()) - float64(params.Interval)
+	return params.EndTime <= closed
+}
+
+// cachedKLine returns the cached klines of the window.
+func (e *Client) cachedKLine(params *MarketKLineRequest) (MarketKLineResponse,
+	bool) {
+
+	if e.cache == nil || !klineWindowClosed(params, e.serverNow()) {
+		return nil, false
+	}
+
+	value, ok := e.cache.get(klineCacheKey{
+		market:   e.marketKey(params.Market),
+		interval: params.Interval,
+		start:    params.StartTime,
+		end:      params.EndTime,
+	})
+	if !ok {
+		return nil, false
+	}
+
+	// Copy is returned, so that modifications made by the caller don't
+	// corrupt the cache.
+	klines := append(MarketKLineResponse(nil),
+		value.(MarketKLineResponse)...)
+
+	// Klines are cached with the engine market, see cacheKLine.
+	if engine := e.marketKey(params.Market); engine != params.Market {
+		e.cfg.SymbolMap.translateResponse(klines, engine, params.Market)
+	}
+
+	return klines, true
+}
+
+// cacheKLine caches the klines of the window, if it is closed.
+func (e *Client) cacheKLine(params *MarketKLineRequest,
+	klines MarketKLineResponse) {
+
+	if e.cache == nil || !klineWindowClosed(params, e.serverNow()) {
+		return
+	}
+
+	klines = append(MarketKLineResponse(nil), klines...)
+
+	// Klines requested with the unified symbol have the translated market,
+	// they are cached with the engine one, so that the cached klines don't
+	// depend on the notation of the request.
+	engine := e.marketKey(params.Market)
+	if engine != params.Market {
+		market, err := ParseMarket(engine)
+		if err != nil {
+			return
+		}
+
+		for i := range klines {
+			klines[i].Market = market
+		}
+	}
+
+	e.cache.put(klineCacheKey{
+		market:   engine,
+		interval: params.Interval,
+		start:    params.StartTime,
+		end:      params.EndTime,
+	}, klines, 0)
+}
+
+// cachedDeals returns the cached page of the market deals.
+func (e *Client) cachedDeals(params *MarketDealsRequest) (MarketDealsResponse,
+	bool) {
+
+	if e.cache == nil || e.cfg.DealCacheTTL <= 0 {
+		return nil, false
+	}
+
+	value, ok := e.cache.get(dealsCacheKey{
+		market: e.marketKey(params.Market),
+		limit:  params.Limit,
+		lastID: params.LastID,
+	})
+	if !ok {
+		return nil, false
+	}
+
+	deals := value.(MarketDealsResponse)
+	return append(MarketDealsResponse(nil), deals...), true
+}
+
+// cacheDeals caches the page of the market deals.
+func (e *Client) cacheDeals(params *MarketDealsRequest,
+	deals MarketDealsResponse) {
+
+	if e.cache == nil || e.cfg.DealCacheTTL <= 0 {
+		return
+	}
+
+	e.cache.put(dealsCacheKey{
+		market: e.marketKey(params.Market),
+		limit:  params.Limit,
+		lastID: params.LastID,
+	}, append(MarketDealsResponse(nil), deals...), e.cfg.DealCacheTTL)
+}
+
+// cachedUserDeals returns the cached page of the user deals.
+func (e *Client) cachedUserDeals(params *MarketUserDealsRequest) (
+	*MarketUserDealsResponse, bool) {
+
+	if e.cache == nil || e.cfg.DealCacheTTL <= 0 {
+		return nil, false
+	}
+
+	value, ok := e.cache.get(userDealsCacheKey{
+		userID: params.UserID,
+		market: e.marketKey(params.Market),
+		offset: params.Offset,
+		limit:  params.Limit,
+	})
+	if !ok {
+		return nil, false
+	}
+
+	page := *value.(*MarketUserDealsResponse)
+	page.Deals = append([]DealDetail(nil), page.Deals...)
+	return &page, true
+}
+
+// cacheUserDeals caches the page of the user deals.
+func (e *Client) cacheUserDeals(params *MarketUserDealsRequest,
+	resp *MarketUserDealsResponse) {
+
+	if e.cache == nil || e.cfg.DealCacheTTL <= 0 || resp == nil {
+		return
+	}
+
+	page := *resp
+	page.Deals = append([]DealDetail(nil), resp.Deals...)
+
+	e.cache.put(userDealsCacheKey{
+		userID: params.UserID,
+		market: e.marketKey(params.Market),
+		offset: params.Offset,
+		limit:  params.Limit,
+	}, &page, e.cfg.DealCacheTTL)
+}
@@ -0,0 +1,187 @@
+package viabtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// cancelAttempts is the number of attempts made to cancel the single
+	// order if cancellation fails with transient error.
+	cancelAttempts = 3
+
+	// cancelBackoff is the delay before the repeated cancellation attempt,
+	// it grows linearly with the number of attempts.
+	cancelBackoff = 100 * time.Millisecond
+)
+
+// CancelResult is the result of the cancellation of the single order.
+type CancelResult struct {
+	Market  MarketType
+	OrderID int32
+
+	// Order is the state of the canceled order, nil if cancellation has
+	// failed.
+	Order *OrderCancelResponse
+
+	// Err is the error of the last cancellation attempt.
+	Err error
+
+	// Attempts is the number of made cancellation attempts.
+	Attempts int
+}
+
+// cancelOrder cancels the order, repeating the cancellation if it fails
+// with transient error. Repeated attempts aren't made once the context is
+// done or client is closed.
+func (e *Client) cancelOrder(ctx context.Context, userID uint32,
+	market MarketType, orderID int32) *CancelResult {
+
+	result := &CancelResult{
+		Market:  market,
+		OrderID: orderID,
+	}
+
+	for result.Attempts < cancelAttempts {
+		if result.Attempts > 0 {
+			if !e.cancelWait(ctx, result.Attempts) {
+				break
+			}
+			e.counters.retry()
+		}
+		result.Attempts++
+
+		req := &OrderCancelRequest{
+			UserID:  userID,
+			Market:  market.String(),
+			OrderID: orderID,
+		}
+		result.Order, result.Err = e.OrderCancelContext(ctx, req)
+		if !isTransient(result.Err) {
+			break
+		}
+	}
+
+	return result
+}
+
+// cancelWait sleeps before the repeated cancellation attempt, false is
+// returned if the context is done or client is closed earlier.
+func (e *Client) cancelWait(ctx context.Context, attempt int) bool {
+	timer := time.NewTimer(cancelBackoff * time.Duration(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-e.calls.quit:
+		return false
+	}
+}
+
+// CancelAllPending cancels all pending orders of the user on the given
+// markets, or on all markets available in the engine if none is given. It
+// is the client-side replacement of OrderCancelAll for engines which
+// don't support it. Pending orders are fetched first and then canceled
+// concurrently, cancellations which failed with transient errors are
+// repeated. The result of every order cancellation is reported. Markets
+// which pending orders couldn't be fetched don't prevent cancellation on
+// the other ones, in this case the results of the other markets are
+// returned along with the non-nil error.
+func (e *Client) CancelAllPending(userID uint32, markets ...MarketType) (
+	[]*CancelResult, error) {
+
+	return e.CancelAllPendingContext(context.Background(), userID,
+		markets...)
+}
+
+// CancelAllPendingContext is the same as CancelAllPending, but it stops
+// fetching and canceling orders once the context is done.
+func (e *Client) CancelAllPendingContext(ctx context.Context, userID uint32,
+	markets ...MarketType) ([]*CancelResult, error) {
+
+	if len(markets) == 0 {
+		var err error
+		markets, err = e.listMarketsContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list markets: %w", err)
+		}
+	}
+
+	type pendingOrder struct {
+		market  MarketType
+		orderID int32
+	}
+
+	var (
+		mtx     sync.Mutex
+		pending []pendingOrder
+		failed  []MarketType
+	)
+
+	err := e.fanOut(len(markets), func(i int) error {
+		orders, err := e.allPendingContext(ctx, userID,
+			markets[i].String())
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if err != nil {
+			failed = append(failed, markets[i])
+			return fmt.Errorf("unable to fetch pending orders of "+
+				"market %v: %w", markets[i], err)
+		}
+
+		for _, order := range orders {
+			pending = append(pending, pendingOrder{
+				market:  markets[i],
+				orderID: order.OrderID,
+			})
+		}
+		return nil
+	})
+
+	results := make([]*CancelResult, len(pending))
+	e.fanOut(len(pending), func(i int) error {
+		results[i] = e.cancelOrder(ctx, userID, pending[i].market,
+			pending[i].orderID)
+		return nil
+	})
+
+	if len(failed) > 1 {
+		err = fmt.Errorf("pending orders of %v markets %v couldn't be "+
+			"fetched, first error: %w", len(failed), failed, err)
+	}
+
+	return results, err
+}
+
+// OrderCancelBatch cancels the given orders of the user on the market
+// concurrently, since the engine has no batch cancellation request.
+// Cancellations which failed with transient errors are repeated. Results are
+// returned in the order of the given ids, so that partial failures are
+// visible.
+func (e *Client) OrderCancelBatch(userID uint32, market MarketType,
+	orderIDs []int32) []*CancelResult {
+
+	return e.OrderCancelBatchContext(context.Background(), userID, market,
+		orderIDs)
+}
+
+// OrderCancelBatchContext is the same as OrderCancelBatch, but it stops
+// repeating the cancellations once the context is done.
+func (e *Client) OrderCancelBatchContext(ctx context.Context, userID uint32,
+	market MarketType, orderIDs []int32) []*CancelResult {
+
+	results := make([]*CancelResult, len(orderIDs))
+	e.fanOut(len(orderIDs), func(i int) error {
+		results[i] = e.cancelOrder(ctx, userID, market, orderIDs[i])
+		return nil
+	})
+
+	return results
+}
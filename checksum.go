@@ -0,0 +1,59 @@
+package viabtc
+
+import (
+	"hash/crc32"
+	"strings"
+)
+
+// normalizeDecimal removes insignificant trailing zeros of the decimal
+// number, so that "1.50" and "1.5" are treated equally.
+func normalizeDecimal(s string) string {
+	if strings.IndexByte(s, '.') == -1 {
+		return s
+	}
+
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+
+	// Zero with the decimal places, e.g. "0.00", is trimmed entirely, it
+	// should have the same form as the plain zero.
+	if s == "" {
+		return "0"
+	}
+
+	return s
+}
+
+// DepthChecksum computes the canonical checksum of the top n levels of the
+// order book. Levels are interleaved starting from the best bid, as
+// "bid_price:bid_volume:ask_price:ask_volume:...", side which has fewer
+// levels is skipped once exhausted, and CRC32 (IEEE) of the resulting
+// string is returned. The same checksum is used for fetched and locally
+// maintained books, so that their integrity might be verified after
+// reconnects and diff application.
+func DepthChecksum(bids, asks []Depth, n int) uint32 {
+	var parts []string
+	for i := 0; i < n; i++ {
+		if i >= len(bids) && i >= len(asks) {
+			break
+		}
+
+		if i < len(bids) {
+			parts = append(parts, normalizeDecimal(bids[i].Price),
+				normalizeDecimal(bids[i].Volume))
+		}
+
+		if i < len(asks) {
+			parts = append(parts, normalizeDecimal(asks[i].Price),
+				normalizeDecimal(asks[i].Volume))
+		}
+	}
+
+	return crc32.ChecksumIEEE([]byte(strings.Join(parts, ":")))
+}
+
+// Checksum computes the canonical checksum of the top n levels of the
+// fetched depth, see DepthChecksum.
+func (r *OrderDepthResponse) Checksum(n int) uint32 {
+	return DepthChecksum(r.Bids, r.Asks, n)
+}
@@ -0,0 +1,44 @@
+package viabtc
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time of the client. It might be
+// replaced in tests, so that the timestamps of the requests, e.g. the
+// default time ranges and the signatures, are deterministic. It is also
+// used for the age of the market data and the clock skew measurement,
+// cache expiration and the cool-down of the circuit breaker.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is the clock which returns the system time.
+type SystemClock struct{}
+
+// A compile time check to ensure SystemClock implements the Clock
+// interface.
+var _ Clock = SystemClock{}
+
+// Now returns the current system time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Now returns the current time of the client clock, it is intended to be
+// used by the code built on top of the client, e.g. for timestamps of the
+// events, so that it follows the clock given in the config.
+func (e *Client) Now() time.Time {
+	return e.clock.Now()
+}
+
+// now returns the current time of the client clock.
+func (e *Client) now() time.Time {
+	return e.clock.Now()
+}
+
+// since returns the time passed since t according to the client clock.
+func (e *Client) since(t time.Time) time.Duration {
+	return e.clock.Now().Sub(t)
+}
@@ -0,0 +1,306 @@
+// viabtc-bench drives configurable mixes of exchange RPC calls at the target
+// rate and reports latency percentiles and error breakdowns, it is used to
+// capacity-test the engine deployments with the exact client stack.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// operation is a single benchmarked action, it might consist of several rpc
+// calls, each of them is reported separately.
+type operation func(c *viabtc.Client, r *recorder)
+
+// recorder accumulates latencies and errors of the rpc calls.
+type recorder struct {
+	sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]map[string]int),
+	}
+}
+
+// measure executes the call and records its latency and error.
+func (r *recorder) measure(method string, call func() error) error {
+	start := time.Now()
+	err := call()
+	elapsed := time.Since(start)
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.latencies[method] = append(r.latencies[method], elapsed)
+	if err != nil {
+		if r.errors[method] == nil {
+			r.errors[method] = make(map[string]int)
+		}
+		r.errors[method][errorKind(err)]++
+	}
+
+	return err
+}
+
+// errorKind returns the category of the error which is used in the report.
+func errorKind(err error) string {
+	var e *viabtc.RPCError
+	if errors.As(err, &e) {
+		return fmt.Sprintf("engine code %v: %v", e.Code, e.Message)
+	}
+
+	return "transport: " + err.Error()
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// report prints the latency percentiles and error breakdown of every
+// benchmarked rpc method.
+func (r *recorder) report(elapsed time.Duration, skipped int) {
+	r.Lock()
+	defer r.Unlock()
+
+	methods := make([]string, 0, len(r.latencies))
+	for method := range r.latencies {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	fmt.Printf("duration: %v, skipped (all workers busy): %v\n\n", elapsed,
+		skipped)
+	fmt.Printf("%-20s %8s %8s %10s %10s %10s %10s %10s\n", "method", "count",
+		"errors", "rps", "p50", "p90", "p99", "max")
+
+	for _, method := range methods {
+		latencies := r.latencies[method]
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+
+		errorsNum := 0
+		for _, n := range r.errors[method] {
+			errorsNum += n
+		}
+
+		fmt.Printf("%-20s %8d %8d %10.1f %10v %10v %10v %10v\n", method,
+			len(latencies), errorsNum,
+			float64(len(latencies))/elapsed.Seconds(),
+			percentile(latencies, 0.5), percentile(latencies, 0.9),
+			percentile(latencies, 0.99), latencies[len(latencies)-1])
+	}
+
+	for _, method := range methods {
+		if len(r.errors[method]) == 0 {
+			continue
+		}
+
+		fmt.Printf("\nerrors of %v:\n", method)
+		for kind, n := range r.errors[method] {
+			fmt.Printf("\t%6d  %v\n", n, kind)
+		}
+	}
+}
+
+// parseMix parses the workload mix in format "name=weight,name=weight".
+func parseMix(mix string, ops map[string]operation) ([]operation, error) {
+	var weighted []operation
+	for _, part := range strings.Split(mix, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("wrong mix element %q", part)
+		}
+
+		op, ok := ops[kv[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown operation %q", kv[0])
+		}
+
+		weight, err := strconv.Atoi(kv[1])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("wrong weight of %q: %v", kv[0], kv[1])
+		}
+
+		for i := 0; i < weight; i++ {
+			weighted = append(weighted, op)
+		}
+	}
+
+	if len(weighted) == 0 {
+		return nil, fmt.Errorf("empty workload mix")
+	}
+
+	return weighted, nil
+}
+
+func main() {
+	var (
+		host        = flag.String("host", "localhost", "accesshttp host")
+		port        = flag.Int("port", 8080, "accesshttp port")
+		duration    = flag.Duration("duration", time.Minute, "benchmark duration")
+		rate        = flag.Float64("rate", 100, "target rate of operations per second")
+		concurrency = flag.Int("concurrency", 32, "maximum number of in-flight operations")
+		mix         = flag.String("mix", "order=1,depth=4,last=4",
+			"weighted mix of operations: order, depth, last, pending, status")
+		market = flag.String("market", viabtc.MarketBTCETH.String(), "market name")
+		userID = flag.Uint("user", 1, "user id used for order placement")
+		price  = flag.String("price", "0.00000001",
+			"price of placed orders, should be far from the market to not be matched")
+		amount = flag.String("amount", "1", "amount of placed orders")
+	)
+	flag.Parse()
+
+	// Negated comparison rejects NaN as well.
+	if !(*rate > 0) || *concurrency <= 0 {
+		fmt.Fprintln(os.Stderr, "rate and concurrency should be positive")
+		os.Exit(1)
+	}
+
+	client := viabtc.NewClient(&viabtc.Config{
+		Host: *host,
+		Port: *port,
+	})
+
+	ops := map[string]operation{
+		"order": func(c *viabtc.Client, r *recorder) {
+			var order *viabtc.OrderPutLimitResponse
+			err := r.measure("order.put_limit", func() error {
+				var err error
+				order, err = c.OrderPutLimit(&viabtc.OrderPutLimitRequest{
+					UserID:       uint32(*userID),
+					Market:       *market,
+					Side:         viabtc.MarketOrderSideBid,
+					Amount:       *amount,
+					Price:        *price,
+					TakerFeeRate: "0",
+					MakerFeeRate: "0",
+					Source:       "viabtc-bench",
+				})
+				return err
+			})
+			if err != nil {
+				return
+			}
+
+			r.measure("order.cancel", func() error {
+				_, err := c.OrderCancel(&viabtc.OrderCancelRequest{
+					UserID:  uint32(*userID),
+					Market:  *market,
+					OrderID: order.OrderID,
+				})
+				return err
+			})
+		},
+		"depth": func(c *viabtc.Client, r *recorder) {
+			r.measure("order.depth", func() error {
+				_, err := c.OrderDepth(&viabtc.OrderDepthRequest{
+					Market:   *market,
+					Limit:    viabtc.MaxLimit,
+					Interval: "0",
+				})
+				return err
+			})
+		},
+		"last": func(c *viabtc.Client, r *recorder) {
+			r.measure("market.last", func() error {
+				_, err := c.MarketLast(&viabtc.MarketLastRequest{
+					Market: *market,
+				})
+				return err
+			})
+		},
+		"pending": func(c *viabtc.Client, r *recorder) {
+			r.measure("order.pending", func() error {
+				_, err := c.OrderPending(&viabtc.OrderPendingRequest{
+					UserID: uint32(*userID),
+					Market: *market,
+					Limit:  viabtc.MaxLimit,
+				})
+				return err
+			})
+		},
+		"status": func(c *viabtc.Client, r *recorder) {
+			r.measure("market.status_today", func() error {
+				_, err := c.MarketStatusToday(&viabtc.MarketStatusTodayRequest{
+					Market: *market,
+				})
+				return err
+			})
+		},
+	}
+
+	workload, err := parseMix(*mix, ops)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	r := newRecorder()
+	queue := make(chan operation)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range queue {
+				op(client, r)
+			}
+		}()
+	}
+
+	// Period of the very high rate is rounded to zero, which isn't
+	// accepted by the ticker.
+	period := time.Duration(float64(time.Second) / *rate)
+	if period < time.Nanosecond {
+		period = time.Nanosecond
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	start := time.Now()
+	deadline := time.After(*duration)
+	skipped := 0
+
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			op := workload[rand.Intn(len(workload))]
+			select {
+			case queue <- op:
+			default:
+				skipped++
+			}
+
+		case <-deadline:
+			break loop
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	r.report(time.Since(start), skipped)
+}
@@ -0,0 +1,116 @@
+// viabtc-check scans the downloaded or recorded klines and deals for gaps,
+// duplicate ids and non-monotonic timestamps, and prints the JSON report
+// with the missing ranges:
+//
+//	viabtc-check -interval 60 data/BTCETH recordings/
+//
+// Deal ids are assigned by the engine across all markets, so missing ids
+// are reported as gaps only with -contiguous-deal-ids, e.g. if the engine
+// runs the single market.
+//
+// Directories are walked for the *.jsonl files, which are checked in the
+// lexical order of their paths, so that the daily partitions go from
+// oldest to newest. Exit code is 2 if any issue has been found.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// collect returns the sorted paths of the event files.
+func collect(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(p string, info os.FileInfo,
+			err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			// Explicitly specified files are checked regardless of
+			// the extension.
+			if !info.IsDir() && (p == path || strings.HasSuffix(p, ".jsonl")) {
+				files = append(files, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func check(checker *viabtc.HistoryChecker, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return checker.Check(path, viabtc.NewWatchEventReader(f))
+}
+
+func main() {
+	var (
+		interval = flag.Int("interval", 60, "kline interval in seconds, gaps of klines aren't checked if zero")
+		indent   = flag.Bool("indent", true, "indent the report")
+		dealIDs  = flag.Bool("contiguous-deal-ids", false, "report missing deal ids as gaps, deal ids are contiguous only if the engine runs the single market")
+	)
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "files or directories should be specified")
+		os.Exit(1)
+	}
+
+	if *interval < 0 {
+		fmt.Fprintln(os.Stderr, "interval shouldn't be negative")
+		os.Exit(1)
+	}
+
+	files, err := collect(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to list files: %v\n", err)
+		os.Exit(1)
+	}
+
+	checker := viabtc.NewHistoryChecker(int32(*interval))
+	checker.ContiguousDealIDs = *dealIDs
+	for _, path := range files {
+		if err := check(checker, path); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to check %v: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+
+	report := checker.Report()
+
+	var data []byte
+	if *indent {
+		data, err = json.MarshalIndent(report, "", "  ")
+	} else {
+		data, err = json.Marshal(report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to encode report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+
+	if len(report.Issues) != 0 {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,444 @@
+// viabtc-download downloads the kline and deal history of the markets for
+// building research datasets. Data is written as watch events, one per
+// line, into the files partitioned by market, data type and day:
+//
+//	<out>/<market>/kline/2006-01-02.jsonl
+//	<out>/<market>/deals/2006-01-02.jsonl
+//
+// so that it might be replayed by the backtest in the same way as the
+// recorded data. Progress is saved after every chunk into
+// <out>/progress.json and the interrupted download continues from the
+// last saved chunk.
+//
+// With -repair the stored kline series are checked for gaps after the
+// download, and only the missing intervals are re-requested from the
+// engine and spliced into the partitions, so that long-running datasets
+// are kept complete without full re-downloads.
+//
+// NOTE: Engine keeps only the recent deals in memory, the deals which
+// aren't available anymore can't be downloaded. If more deals than the
+// page size happened between two requests, the older ones are skipped and
+// the possibly missing range is reported.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+const (
+	// progressFile is the name of the file with the download progress.
+	progressFile = "progress.json"
+
+	// dayLayout is the layout of the partition file names.
+	dayLayout = "2006-01-02"
+)
+
+// marketProgress is the download progress of the single market.
+type marketProgress struct {
+	// KLineEnd is the end of the last downloaded kline chunk.
+	KLineEnd float64 `json:"kline_end"`
+
+	// LastDealID is the id of the newest downloaded deal.
+	LastDealID int32 `json:"last_deal_id"`
+}
+
+type progress struct {
+	Markets map[string]*marketProgress `json:"markets"`
+}
+
+type downloader struct {
+	client *viabtc.Client
+	out    string
+
+	interval   int32
+	chunk      int32
+	dealsLimit int32
+
+	progress *progress
+
+	// partitions is the currently open partition of every market and data
+	// type, the partition of the previous day is closed once the next day
+	// starts, so that the long download doesn't run out of file
+	// descriptors.
+	partitions map[string]*partition
+}
+
+// partition is the open file of the single day.
+type partition struct {
+	path string
+	file *os.File
+
+	// dirty is true if partition has been written since the last save of
+	// the progress.
+	dirty bool
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(dayLayout, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func (d *downloader) loadProgress() error {
+	d.progress = &progress{Markets: make(map[string]*marketProgress)}
+
+	data, err := ioutil.ReadFile(filepath.Join(d.out, progressFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, d.progress); err != nil {
+		return fmt.Errorf("unable to parse progress: %v", err)
+	}
+
+	if d.progress.Markets == nil {
+		d.progress.Markets = make(map[string]*marketProgress)
+	}
+
+	return nil
+}
+
+// saveProgress syncs the partitions written since the last save and
+// atomically replaces the progress file, so that the progress never gets
+// ahead of the data.
+func (d *downloader) saveProgress() error {
+	for _, p := range d.partitions {
+		if !p.dirty {
+			continue
+		}
+
+		if err := p.file.Sync(); err != nil {
+			return err
+		}
+		p.dirty = false
+	}
+
+	data, err := json.MarshalIndent(d.progress, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.out, progressFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func (d *downloader) marketProgress(market string) *marketProgress {
+	p, ok := d.progress.Markets[market]
+	if !ok {
+		p = &marketProgress{}
+		d.progress.Markets[market] = p
+	}
+
+	return p
+}
+
+// write appends the event to the partition of its market, type and day.
+func (d *downloader) write(event *viabtc.WatchEvent) error {
+	dir := filepath.Join(d.out, event.Market, string(event.Type))
+	path := filepath.Join(dir, event.Time.UTC().Format(dayLayout)+".jsonl")
+
+	p, ok := d.partitions[dir]
+	if ok && p.path != path {
+		// Written data of the previous day should be synced before the
+		// progress is saved, so it is synced on close.
+		if err := closePartition(p); err != nil {
+			return err
+		}
+		ok = false
+	}
+
+	if !ok {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+			0644)
+		if err != nil {
+			return err
+		}
+
+		p = &partition{path: path, file: f}
+		d.partitions[dir] = p
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.dirty = true
+	_, err = p.file.Write(append(data, '\n'))
+	return err
+}
+
+// closePartition syncs the partition if it has been written since the last
+// save of the progress, and closes it.
+func closePartition(p *partition) error {
+	if p.dirty {
+		if err := p.file.Sync(); err != nil {
+			p.file.Close()
+			return err
+		}
+	}
+
+	return p.file.Close()
+}
+
+func (d *downloader) close() {
+	for _, p := range d.partitions {
+		p.file.Close()
+	}
+}
+
+func unixTime(t float64) time.Time {
+	sec := int64(t)
+	return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC()
+}
+
+// downloadKLines downloads the klines of the market chunk by chunk, every
+// chunk covers the fixed number of intervals.
+func (d *downloader) downloadKLines(market string, start, end time.Time) error {
+	p := d.marketProgress(market)
+
+	// Chunks are aligned to the intervals.
+	from := float64(start.Unix() - start.Unix()%int64(d.interval))
+	if p.KLineEnd > from {
+		from = p.KLineEnd
+	}
+
+	// The current interval isn't closed yet.
+	now := time.Now().Unix()
+	last := float64(now - now%int64(d.interval))
+	to := float64(end.Unix())
+	if to > last {
+		to = last
+	}
+
+	for from < to {
+		chunkEnd := from + float64(d.interval*d.chunk)
+		if chunkEnd > to {
+			chunkEnd = to
+		}
+
+		klines, err := d.client.MarketKLine(&viabtc.MarketKLineRequest{
+			Market:    market,
+			StartTime: from,
+			EndTime:   chunkEnd,
+			Interval:  d.interval,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch klines of %v from %v: %v",
+				market, unixTime(from), err)
+		}
+
+		var written int
+		for i := range klines {
+			// Bounds of the chunks might be returned twice.
+			if klines[i].Time < from || klines[i].Time >= chunkEnd {
+				continue
+			}
+
+			if err := d.write(&viabtc.WatchEvent{
+				Market: market,
+				Type:   viabtc.WatchKLine,
+				Time:   unixTime(klines[i].Time),
+				Data:   klines[i : i+1],
+			}); err != nil {
+				return err
+			}
+			written++
+		}
+
+		p.KLineEnd = chunkEnd
+		if err := d.saveProgress(); err != nil {
+			return err
+		}
+
+		fmt.Printf("%v: %v klines till %v\n", market, written,
+			unixTime(chunkEnd).Format(time.RFC3339))
+		from = chunkEnd
+	}
+
+	return nil
+}
+
+// downloadDeals downloads the deals of the market which are newer than the
+// last downloaded one, page by page.
+func (d *downloader) downloadDeals(market string, start, end time.Time) error {
+	p := d.marketProgress(market)
+
+	for {
+		deals, err := d.client.MarketDeals(&viabtc.MarketDealsRequest{
+			Market: market,
+			Limit:  d.dealsLimit,
+			LastID: p.LastDealID,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to fetch deals of %v after %v: %v",
+				market, p.LastDealID, err)
+		}
+
+		if len(deals) == 0 {
+			return nil
+		}
+
+		// Deals are returned from newest to oldest, the full page might
+		// not contain all deals after the last one. Deal ids aren't
+		// contiguous within the market, so it isn't known whether any
+		// deal has been actually skipped.
+		oldest := deals[len(deals)-1].DealID
+		if p.LastDealID != 0 && int32(len(deals)) >= d.dealsLimit &&
+			oldest > p.LastDealID+1 {
+
+			fmt.Fprintf(os.Stderr, "%v: page of deals is full, deals "+
+				"from %v to %v might be missing\n", market,
+				p.LastDealID+1, oldest-1)
+		}
+
+		var written int
+		for i := len(deals) - 1; i >= 0; i-- {
+			t := unixTime(deals[i].Time)
+			if t.Before(start) || !t.Before(end) {
+				continue
+			}
+
+			if err := d.write(&viabtc.WatchEvent{
+				Market: market,
+				Type:   viabtc.WatchDeals,
+				Time:   t,
+				Data:   deals[i : i+1],
+			}); err != nil {
+				return err
+			}
+			written++
+		}
+
+		p.LastDealID = deals[0].DealID
+		if err := d.saveProgress(); err != nil {
+			return err
+		}
+
+		fmt.Printf("%v: %v deals till id %v\n", market, written,
+			p.LastDealID)
+
+		if int32(len(deals)) < d.dealsLimit {
+			return nil
+		}
+	}
+}
+
+func main() {
+	var (
+		host       = flag.String("host", "localhost", "accesshttp host")
+		port       = flag.Int("port", 8080, "accesshttp port")
+		markets    = flag.String("markets", viabtc.MarketBTCETH.String(), "comma separated market names")
+		start      = flag.String("start", "", "start of the range, date or RFC3339 time")
+		end        = flag.String("end", "", "end of the range, now if not specified")
+		out        = flag.String("out", "data", "output directory")
+		interval   = flag.Int("interval", 60, "kline interval in seconds")
+		chunk      = flag.Int("chunk", 1000, "number of klines fetched per request")
+		dealsLimit = flag.Int("deals-limit", int(viabtc.MaxLimit), "number of deals fetched per request")
+		klines     = flag.Bool("klines", true, "download klines")
+		deals      = flag.Bool("deals", true, "download deals")
+		repair     = flag.Bool("repair", false, "re-fetch klines missing from the stored series")
+	)
+	flag.Parse()
+
+	if *start == "" {
+		fmt.Fprintln(os.Stderr, "start of the range should be specified")
+		os.Exit(1)
+	}
+
+	startTime, err := parseTime(*start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wrong start: %v\n", err)
+		os.Exit(1)
+	}
+
+	endTime := time.Now()
+	if *end != "" {
+		if endTime, err = parseTime(*end); err != nil {
+			fmt.Fprintf(os.Stderr, "wrong end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *interval <= 0 || *chunk <= 0 || *dealsLimit <= 0 {
+		fmt.Fprintln(os.Stderr, "interval, chunk and deals limit should "+
+			"be positive")
+		os.Exit(1)
+	}
+
+	d := &downloader{
+		client: viabtc.NewClient(&viabtc.Config{
+			Host: *host,
+			Port: *port,
+		}),
+		out:        *out,
+		interval:   int32(*interval),
+		chunk:      int32(*chunk),
+		dealsLimit: int32(*dealsLimit),
+		partitions: make(map[string]*partition),
+	}
+	defer d.close()
+
+	if err := os.MkdirAll(d.out, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create output directory: %v\n",
+			err)
+		os.Exit(1)
+	}
+
+	if err := d.loadProgress(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load progress: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, market := range strings.Split(*markets, ",") {
+		market = strings.TrimSpace(market)
+		if market == "" {
+			continue
+		}
+
+		if *klines {
+			if err := d.downloadKLines(market, startTime, endTime); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				d.close()
+				os.Exit(1)
+			}
+		}
+
+		if *klines && *repair {
+			if err := d.repairKLines(market); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				d.close()
+				os.Exit(1)
+			}
+		}
+
+		if *deals {
+			if err := d.downloadDeals(market, startTime, endTime); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				d.close()
+				os.Exit(1)
+			}
+		}
+	}
+}
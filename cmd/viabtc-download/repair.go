@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+func readEvents(path string) ([]*viabtc.WatchEvent, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []*viabtc.WatchEvent
+	r := viabtc.NewWatchEventReader(f)
+	for r.Next() {
+		events = append(events, r.Event())
+	}
+
+	return events, r.Err()
+}
+
+// closeFile closes the partition if it is open, so that it might be
+// replaced.
+func (d *downloader) closeFile(path string) error {
+	dir := filepath.Dir(path)
+	p, ok := d.partitions[dir]
+	if !ok || p.path != path {
+		return nil
+	}
+	delete(d.partitions, dir)
+
+	return closePartition(p)
+}
+
+// splice merges the klines into the partition, the klines which are
+// already stored are kept. Partition is replaced atomically, so that the
+// interrupted repair doesn't corrupt it.
+func (d *downloader) splice(path string, events []*viabtc.WatchEvent) error {
+	if err := d.closeFile(path); err != nil {
+		return err
+	}
+
+	stored, err := readEvents(path)
+	if err != nil {
+		return fmt.Errorf("unable to read %v: %v", path, err)
+	}
+
+	times := make(map[time.Time]struct{}, len(stored))
+	for _, event := range stored {
+		times[event.Time] = struct{}{}
+	}
+
+	merged := stored
+	for _, event := range events {
+		if _, ok := times[event.Time]; ok {
+			continue
+		}
+		times[event.Time] = struct{}{}
+		merged = append(merged, event)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	for _, event := range merged {
+		data, err := json.Marshal(event)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// refetch requests the klines of the range [from, to) and splices them into
+// the partitions, the number of the restored klines is returned.
+func (d *downloader) refetch(market string, from, to time.Time) (int, error) {
+	partitions := make(map[string][]*viabtc.WatchEvent)
+
+	start := float64(from.Unix())
+	end := float64(to.Unix())
+	for start < end {
+		chunkEnd := start + float64(d.interval*d.chunk)
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+
+		klines, err := d.client.MarketKLine(&viabtc.MarketKLineRequest{
+			Market:    market,
+			StartTime: start,
+			EndTime:   chunkEnd,
+			Interval:  d.interval,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("unable to fetch klines of %v from %v: %v",
+				market, unixTime(start), err)
+		}
+
+		for i := range klines {
+			if klines[i].Time < start || klines[i].Time >= chunkEnd {
+				continue
+			}
+
+			t := unixTime(klines[i].Time)
+			path := filepath.Join(d.out, market, string(viabtc.WatchKLine),
+				t.Format(dayLayout)+".jsonl")
+
+			partitions[path] = append(partitions[path], &viabtc.WatchEvent{
+				Market: market,
+				Type:   viabtc.WatchKLine,
+				Time:   t,
+				Data:   klines[i : i+1],
+			})
+		}
+
+		start = chunkEnd
+	}
+
+	var restored int
+	for path, events := range partitions {
+		if err := d.splice(path, events); err != nil {
+			return 0, err
+		}
+		restored += len(events)
+	}
+
+	return restored, nil
+}
+
+// repairKLines checks the stored kline series of the market and
+// re-requests only the missing intervals from the engine.
+func (d *downloader) repairKLines(market string) error {
+	dir := filepath.Join(d.out, market, string(viabtc.WatchKLine))
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	checker := viabtc.NewHistoryChecker(d.interval)
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		err = checker.Check(path, viabtc.NewWatchEventReader(f))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to check %v: %v", path, err)
+		}
+	}
+
+	var gaps, restored int
+	for _, issue := range checker.Report().Issues {
+		if issue.Issue != viabtc.HistoryGap {
+			continue
+		}
+		gaps++
+
+		n, err := d.refetch(market, issue.From, issue.To)
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			fmt.Fprintf(os.Stderr, "%v: klines from %v to %v aren't "+
+				"available\n", market, issue.From.Format(time.RFC3339),
+				issue.To.Format(time.RFC3339))
+		}
+		restored += n
+	}
+
+	fmt.Printf("%v: %v klines restored in %v gaps\n", market, restored,
+		gaps)
+
+	return nil
+}
@@ -0,0 +1,344 @@
+// viabtc-soak is the long-running consistency harness. It performs
+// randomized matched trades between two test accounts and after every round
+// verifies the invariants of the engine state, stopping on the first
+// violated one.
+//
+// NOTE: Test accounts should be used exclusively by the harness, and
+// orders are placed with zero fee, otherwise the balance conservation
+// invariant doesn't hold.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"os"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// violation describes the broken invariant.
+type violation struct {
+	Invariant string
+	Details   string
+}
+
+func (v *violation) Error() string {
+	return fmt.Sprintf("invariant %q violated: %v", v.Invariant, v.Details)
+}
+
+type harness struct {
+	client *viabtc.Client
+	market viabtc.MarketType
+	seller uint32
+	buyer  uint32
+	rand   *rand.Rand
+
+	minPrice  float64
+	maxPrice  float64
+	maxAmount float64
+	prec      int
+
+	// initial is the overall amount of funds of both accounts in the every
+	// asset of the market, which should be preserved.
+	initial map[viabtc.AssetType]*big.Rat
+}
+
+func parseRat(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse decimal %q", s)
+	}
+
+	return r, nil
+}
+
+// totals returns the overall amount of funds of both test accounts and
+// verifies that none of the balances is negative.
+func (h *harness) totals() (map[viabtc.AssetType]*big.Rat, error) {
+	totals := map[viabtc.AssetType]*big.Rat{
+		h.market.Stock: new(big.Rat),
+		h.market.Money: new(big.Rat),
+	}
+
+	for _, userID := range []uint32{h.seller, h.buyer} {
+		balances, err := h.client.BalanceQuery(&viabtc.BalanceQueryRequest{
+			UserID: userID,
+			Assets: []viabtc.AssetType{h.market.Stock, h.market.Money},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for asset, total := range totals {
+			balance := balances[asset]
+			for _, s := range []string{balance.Available, balance.Freeze} {
+				if s == "" {
+					continue
+				}
+
+				v, err := parseRat(s)
+				if err != nil {
+					return nil, err
+				}
+
+				if v.Sign() < 0 {
+					return nil, &violation{
+						Invariant: "non-negative balance",
+						Details: fmt.Sprintf("user %v has %v %v", userID,
+							s, asset),
+					}
+				}
+
+				total.Add(total, v)
+			}
+		}
+	}
+
+	return totals, nil
+}
+
+func (h *harness) randomDecimal(min, max float64) string {
+	v := min + h.rand.Float64()*(max-min)
+	return big.NewFloat(v).Text('f', h.prec)
+}
+
+// round places the opposite orders with the same price and amount, which
+// should be fully matched with each other, and verifies the invariants.
+func (h *harness) round() error {
+	price := h.randomDecimal(h.minPrice, h.maxPrice)
+	amount := h.randomDecimal(0, h.maxAmount)
+	if v, _ := parseRat(amount); v == nil || v.Sign() == 0 {
+		return nil
+	}
+
+	orders := []*viabtc.OrderPutLimitRequest{
+		{
+			UserID:       h.seller,
+			Market:       h.market.String(),
+			Side:         viabtc.MarketOrderSideAsk,
+			Amount:       amount,
+			Price:        price,
+			TakerFeeRate: "0",
+			MakerFeeRate: "0",
+			Source:       "viabtc-soak",
+		},
+		{
+			UserID:       h.buyer,
+			Market:       h.market.String(),
+			Side:         viabtc.MarketOrderSideBid,
+			Amount:       amount,
+			Price:        price,
+			TakerFeeRate: "0",
+			MakerFeeRate: "0",
+			Source:       "viabtc-soak",
+		},
+	}
+
+	// Randomize which of the accounts is the maker.
+	if h.rand.Intn(2) == 0 {
+		orders[0], orders[1] = orders[1], orders[0]
+	}
+
+	var ids []int32
+	for _, order := range orders {
+		resp, err := h.client.OrderPutLimit(order)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, resp.OrderID)
+	}
+
+	totals, err := h.totals()
+	if err != nil {
+		return err
+	}
+
+	for asset, initial := range h.initial {
+		if totals[asset].Cmp(initial) != 0 {
+			return &violation{
+				Invariant: "balance conservation",
+				Details: fmt.Sprintf("overall %v changed from %v to %v "+
+					"after matching %v@%v", asset, initial.FloatString(h.prec),
+					totals[asset].FloatString(h.prec), amount, price),
+			}
+		}
+	}
+
+	for _, userID := range []uint32{h.seller, h.buyer} {
+		pending, err := h.client.OrderPending(&viabtc.OrderPendingRequest{
+			UserID: userID,
+			Market: h.market.String(),
+			Limit:  viabtc.MaxLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		if pending.Total != 0 {
+			return &violation{
+				Invariant: "matched orders are not pending",
+				Details: fmt.Sprintf("user %v has %v pending orders after "+
+					"matching %v@%v", userID, pending.Total, amount, price),
+			}
+		}
+	}
+
+	for _, id := range ids {
+		order, err := h.client.OrderFinishedDetail(
+			&viabtc.OrderFinishedDetailRequest{OrderID: id})
+		if err != nil {
+			return err
+		}
+
+		if order == nil {
+			return &violation{
+				Invariant: "matched orders are finished",
+				Details:   fmt.Sprintf("order %v is not in finished orders", id),
+			}
+		}
+
+		left, err := parseRat(order.Left)
+		if err != nil {
+			return err
+		}
+
+		if left.Sign() != 0 {
+			return &violation{
+				Invariant: "matched orders are fully executed",
+				Details: fmt.Sprintf("finished order %v has %v left", id,
+					order.Left),
+			}
+		}
+	}
+
+	return nil
+}
+
+// cleanup cancels orders left by the failed round, so that the next round
+// starts from the consistent state. All pages of the pending orders are
+// canceled, not only the first one. Error is returned if any order might
+// be left, because balances snapshotted after the failed cleanup would be
+// reported as the drift.
+func (h *harness) cleanup() error {
+	for _, userID := range []uint32{h.seller, h.buyer} {
+		results, err := h.client.CancelAllPending(userID, h.market)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			// Order might be finished after it was fetched, in this
+			// case it isn't left either.
+			if result.Err != nil &&
+				!errors.Is(result.Err, viabtc.ErrOrderNotFound) {
+
+				return fmt.Errorf("unable to cancel order %v of user "+
+					"%v: %v", result.OrderID, userID, result.Err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	var (
+		host      = flag.String("host", "localhost", "accesshttp host")
+		port      = flag.Int("port", 8080, "accesshttp port")
+		market    = flag.String("market", viabtc.MarketBTCETH.String(), "market name")
+		seller    = flag.Uint("seller", 1, "user id of the selling test account")
+		buyer     = flag.Uint("buyer", 2, "user id of the buying test account")
+		duration  = flag.Duration("duration", 4*time.Hour, "overall soak duration")
+		interval  = flag.Duration("interval", 100*time.Millisecond, "pause between rounds")
+		minPrice  = flag.Float64("min-price", 0.01, "minimum price of the trade")
+		maxPrice  = flag.Float64("max-price", 0.1, "maximum price of the trade")
+		maxAmount = flag.Float64("max-amount", 1, "maximum amount of the trade")
+		prec      = flag.Int("prec", 4, "decimal places of price and amount")
+		maxErrors = flag.Int("max-errors", 100, "number of rpc errors after which harness gives up")
+		seed      = flag.Int64("seed", time.Now().UnixNano(), "random seed")
+	)
+	flag.Parse()
+
+	m, err := viabtc.ParseMarket(*market)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid market: %v\n", err)
+		os.Exit(1)
+	}
+
+	h := &harness{
+		client: viabtc.NewClient(&viabtc.Config{
+			Host: *host,
+			Port: *port,
+		}),
+		market:    m,
+		seller:    uint32(*seller),
+		buyer:     uint32(*buyer),
+		rand:      rand.New(rand.NewSource(*seed)),
+		minPrice:  *minPrice,
+		maxPrice:  *maxPrice,
+		maxAmount: *maxAmount,
+		prec:      *prec,
+	}
+
+	fmt.Printf("soak started: market %v, seed %v\n", h.market, *seed)
+
+	if err := h.cleanup(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to cancel pending orders: %v\n", err)
+		os.Exit(1)
+	}
+
+	initial, err := h.totals()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to fetch initial balances: %v\n", err)
+		os.Exit(1)
+	}
+	h.initial = initial
+
+	var rounds, rpcErrors int
+	start := time.Now()
+	for time.Since(start) < *duration {
+		err := h.round()
+		rounds++
+
+		switch err := err.(type) {
+		case nil:
+
+		case *violation:
+			fmt.Fprintf(os.Stderr, "round %v: %v\n", rounds, err)
+			os.Exit(2)
+
+		default:
+			rpcErrors++
+			fmt.Fprintf(os.Stderr, "round %v: rpc error: %v\n", rounds, err)
+			if rpcErrors >= *maxErrors {
+				fmt.Fprintln(os.Stderr, "too many rpc errors, giving up")
+				os.Exit(1)
+			}
+
+			// State of the round is unknown, cancel leftovers and
+			// re-snapshot the balances.
+			if err := h.cleanup(); err != nil {
+				fmt.Fprintf(os.Stderr, "round %v: unable to cancel "+
+					"pending orders: %v\n", rounds, err)
+				os.Exit(1)
+			}
+			if initial, err := h.totals(); err == nil {
+				h.initial = initial
+			}
+		}
+
+		if rounds%1000 == 0 {
+			fmt.Printf("%v rounds passed, %v rpc errors, elapsed %v\n",
+				rounds, rpcErrors, time.Since(start))
+		}
+
+		time.Sleep(*interval)
+	}
+
+	fmt.Printf("soak finished: %v rounds, %v rpc errors, no invariant "+
+		"violations\n", rounds, rpcErrors)
+}
@@ -0,0 +1,238 @@
+package viabtc
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// DepthDivergence describes the price level which differs between the
+// reference endpoint and the replica.
+type DepthDivergence struct {
+	Side  MarketOrderSide `json:"side"`
+	Price string          `json:"price"`
+
+	// Reference is the volume of the level on the reference endpoint, empty
+	// if the level is absent.
+	Reference string `json:"reference"`
+
+	// Replica is the volume of the level on the replica, empty if the level
+	// is absent.
+	Replica string `json:"replica"`
+}
+
+// ReplicaDepthReport is the result of depth comparison of the single
+// replica with the reference endpoint.
+type ReplicaDepthReport struct {
+	Endpoint    string            `json:"endpoint"`
+	Checksum    uint32            `json:"checksum"`
+	Divergences []DepthDivergence `json:"divergences"`
+
+	// Err is the error of the depth request, if any.
+	Err error `json:"-"`
+}
+
+// DepthConsistencyReport is the result of depth comparison across
+// endpoints.
+type DepthConsistencyReport struct {
+	Market    string               `json:"market"`
+	Reference string               `json:"reference"`
+	Checksum  uint32               `json:"checksum"`
+	Replicas  []ReplicaDepthReport `json:"replicas"`
+}
+
+// Consistent returns true if all replicas responded and none of them
+// diverged from the reference endpoint.
+func (r *DepthConsistencyReport) Consistent() bool {
+	for _, replica := range r.Replicas {
+		if replica.Err != nil || len(replica.Divergences) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareLevels returns the levels which volume differs more than by the
+// tolerance, relative to the reference volume.
+func compareLevels(side MarketOrderSide, reference, replica []Depth,
+	tolerance *big.Rat) ([]DepthDivergence, error) {
+
+	volumes := func(levels []Depth) (map[string]string, error) {
+		m := make(map[string]string, len(levels))
+		for _, level := range levels {
+			if _, err := parseDecimal(level.Volume); err != nil {
+				return nil, err
+			}
+			m[normalizeDecimal(level.Price)] = level.Volume
+		}
+		return m, nil
+	}
+
+	ref, err := volumes(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := volumes(replica)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]struct{})
+	for price := range ref {
+		prices[price] = struct{}{}
+	}
+	for price := range rep {
+		prices[price] = struct{}{}
+	}
+
+	var divergences []DepthDivergence
+	for price := range prices {
+		refVolume, refOk := ref[price]
+		repVolume, repOk := rep[price]
+
+		if refOk && repOk {
+			a, _ := parseDecimal(refVolume)
+			b, _ := parseDecimal(repVolume)
+
+			diff := new(big.Rat).Sub(a, b)
+			diff.Abs(diff)
+			if diff.Cmp(new(big.Rat).Mul(a, tolerance)) <= 0 {
+				continue
+			}
+		}
+
+		divergences = append(divergences, DepthDivergence{
+			Side:      side,
+			Price:     price,
+			Reference: refVolume,
+			Replica:   repVolume,
+		})
+	}
+
+	sort.Slice(divergences, func(i, j int) bool {
+		return divergences[i].Price < divergences[j].Price
+	})
+
+	return divergences, nil
+}
+
+// CheckDepthConsistency queries the depth from all endpoints simultaneously
+// and compares every replica with the reference endpoint. Volumes which
+// differ by more than the tolerance, relative to the reference volume, and
+// levels present only on one side are reported as divergences. It is used
+// to detect lagging read replicas before routing market data reads to them.
+func CheckDepthConsistency(endpoints map[string]*Client, reference string,
+	params *OrderDepthRequest, tolerance string) (*DepthConsistencyReport,
+	error) {
+
+	if _, ok := endpoints[reference]; !ok {
+		return nil, fmt.Errorf("reference endpoint %q isn't in the "+
+			"list of endpoints", reference)
+	}
+
+	tol, err := parseDecimal(tolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		depth *OrderDepthResponse
+		err   error
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		results = make(map[string]result, len(endpoints))
+	)
+
+	for name, client := range endpoints {
+		wg.Add(1)
+		go func(name string, client *Client) {
+			defer wg.Done()
+
+			depth, err := client.OrderDepth(params)
+			if err == nil && depth == nil {
+				depth = &OrderDepthResponse{}
+			}
+
+			mtx.Lock()
+			results[name] = result{depth: depth, err: err}
+			mtx.Unlock()
+		}(name, client)
+	}
+	wg.Wait()
+
+	ref := results[reference]
+	if ref.err != nil {
+		return nil, fmt.Errorf("unable to fetch depth from reference "+
+			"endpoint: %w", ref.err)
+	}
+
+	// Limit might be unset and filled by the defaults of the clients, in
+	// this case all fetched levels are compared.
+	n := int(params.Limit)
+	if n <= 0 {
+		for _, res := range results {
+			if res.err != nil {
+				continue
+			}
+
+			if len(res.depth.Asks) > n {
+				n = len(res.depth.Asks)
+			}
+			if len(res.depth.Bids) > n {
+				n = len(res.depth.Bids)
+			}
+		}
+	}
+
+	report := &DepthConsistencyReport{
+		Market:    params.Market,
+		Reference: reference,
+		Checksum:  ref.depth.Checksum(n),
+	}
+
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		if name != reference {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		res := results[name]
+		replica := ReplicaDepthReport{
+			Endpoint: name,
+			Err:      res.err,
+		}
+
+		if res.err == nil {
+			replica.Checksum = res.depth.Checksum(n)
+
+			if replica.Checksum != report.Checksum {
+				asks, err := compareLevels(MarketOrderSideAsk,
+					ref.depth.Asks, res.depth.Asks, tol)
+				if err != nil {
+					return nil, err
+				}
+
+				bids, err := compareLevels(MarketOrderSideBid,
+					ref.depth.Bids, res.depth.Bids, tol)
+				if err != nil {
+					return nil, err
+				}
+
+				replica.Divergences = append(asks, bids...)
+			}
+		}
+
+		report.Replicas = append(report.Replicas, replica)
+	}
+
+	return report, nil
+}
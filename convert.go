@@ -0,0 +1,177 @@
+package viabtc
+
+import "fmt"
+
+// defaultConversionPrec is the number of decimal places of converted amount
+// if precision of target asset is unknown.
+const defaultConversionPrec = 8
+
+// ConversionStep is the single exchange of one asset to another on the
+// market, which is made during conversion.
+type ConversionStep struct {
+	Market MarketType `json:"market"`
+	From   AssetType  `json:"from"`
+	To     AssetType  `json:"to"`
+
+	// Price is the market price used for the exchange, it is expressed in
+	// market money for one stock.
+	Price string `json:"price"`
+}
+
+// Conversion is the result of conversion of amount from one asset to
+// another.
+type Conversion struct {
+	Amount string           `json:"amount"`
+	Path   []ConversionStep `json:"path"`
+}
+
+// ConverterConfig is an structure which holds configurable parameters of
+// the converter.
+type ConverterConfig struct {
+	// PriceSource is used to get the market prices, by default the last
+	// trade price is used.
+	PriceSource PriceSource
+}
+
+// Converter converts amounts from one asset to another using the graph of
+// available markets, going through intermediate markets if there is no
+// direct one. It is used to normalize portfolio and fees into a single
+// quote asset.
+type Converter struct {
+	client *Client
+	source PriceSource
+}
+
+// NewConverter creates new converter, markets are taken from the client's
+// registry, which is loaded on demand if it's empty.
+func NewConverter(client *Client, cfg *ConverterConfig) *Converter {
+	var source PriceSource
+	if cfg != nil {
+		source = cfg.PriceSource
+	}
+
+	if source == nil {
+		source = NewLastPriceSource(client)
+	}
+
+	return &Converter{
+		client: client,
+		source: source,
+	}
+}
+
+// edge is the possible exchange of one asset to another.
+type edge struct {
+	market MarketType
+	to     AssetType
+}
+
+// findPath returns the shortest sequence of exchanges from one asset to
+// another.
+func findPath(markets []MarketInfo, from, to AssetType) ([]ConversionStep,
+	bool) {
+
+	graph := make(map[AssetType][]edge)
+	for _, m := range markets {
+		graph[m.Stock] = append(graph[m.Stock], edge{m.MarketName, m.Money})
+		graph[m.Money] = append(graph[m.Money], edge{m.MarketName, m.Stock})
+	}
+
+	type hop struct {
+		prev   AssetType
+		market MarketType
+	}
+
+	visited := map[AssetType]hop{from: {}}
+	queue := []AssetType{from}
+	for len(queue) != 0 && queue[0] != to {
+		asset := queue[0]
+		queue = queue[1:]
+
+		for _, e := range graph[asset] {
+			if _, ok := visited[e.to]; ok {
+				continue
+			}
+
+			visited[e.to] = hop{prev: asset, market: e.market}
+			queue = append(queue, e.to)
+		}
+	}
+
+	if _, ok := visited[to]; !ok {
+		return nil, false
+	}
+
+	var path []ConversionStep
+	for asset := to; asset != from; {
+		h := visited[asset]
+		path = append([]ConversionStep{{
+			Market: h.market,
+			From:   h.prev,
+			To:     asset,
+		}}, path...)
+		asset = h.prev
+	}
+
+	return path, true
+}
+
+// Convert converts the amount of one asset into another, returning the
+// resulting amount and the path of markets which was used.
+func (c *Converter) Convert(amount string, from, to AssetType) (*Conversion,
+	error) {
+
+	value, err := parseDecimal(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := c.client.Registry()
+	if len(registry.Markets()) == 0 {
+		if err := registry.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	path, ok := findPath(registry.Markets(), from, to)
+	if !ok {
+		return nil, fmt.Errorf("there is no markets to convert %v "+
+			"to %v", from, to)
+	}
+
+	for i, step := range path {
+		p, err := c.source.Price(step.Market)
+		if err != nil {
+			return nil, err
+		}
+
+		price, err := parseDecimal(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if price.Sign() == 0 {
+			return nil, fmt.Errorf("market %v has zero price",
+				step.Market)
+		}
+
+		info, _ := registry.Market(step.Market)
+		if step.From == info.Stock {
+			value.Mul(value, price)
+		} else {
+			value.Quo(value, price)
+		}
+
+		path[i].Price = p
+	}
+
+	prec, err := c.client.Assets().Precision(to)
+	if err != nil {
+		prec = defaultConversionPrec
+	}
+
+	return &Conversion{
+		Amount: value.FloatString(prec),
+		Path:   path,
+	}, nil
+}
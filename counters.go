@@ -0,0 +1,160 @@
+package viabtc
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// MethodCounters is the number of requests of the single rpc method.
+type MethodCounters struct {
+	Requests     int64 `json:"requests"`
+	Errors       int64 `json:"errors"`
+	EngineErrors int64 `json:"engine_errors"`
+}
+
+// ClientCounters is the snapshot of the client request counters.
+type ClientCounters struct {
+	// Requests is the number of rpc requests made by the client.
+	Requests int64 `json:"requests"`
+
+	// Errors is the number of requests which failed, including the ones
+	// rejected by the engine.
+	Errors int64 `json:"errors"`
+
+	// EngineErrors is the number of requests rejected by the engine with
+	// error code.
+	EngineErrors int64 `json:"engine_errors"`
+
+	// Retries is the number of requests repeated by the client helpers
+	// after the transient error.
+	Retries int64 `json:"retries"`
+
+	// BreakerTrips is the number of times the circuit breaker opened.
+	BreakerTrips int64 `json:"breaker_trips"`
+
+	// Hedges is the number of the second requests sent by the hedged
+	// calls.
+	Hedges int64 `json:"hedges"`
+
+	Methods map[string]MethodCounters `json:"methods"`
+}
+
+// engineErrorer is implemented by rpc responses, it is used to count the
+// requests rejected by the engine.
+type engineErrorer interface {
+	engineError() *RPCError
+}
+
+func (r *baseResponse) engineError() *RPCError {
+	return r.Error
+}
+
+// clientCounters keeps track of the requests made by the client.
+type clientCounters struct {
+	requests     int64
+	errors       int64
+	engineErrors int64
+	retries      int64
+	breakerTrips int64
+	hedges       int64
+
+	mtx     sync.Mutex
+	methods map[string]*MethodCounters
+}
+
+func newClientCounters() *clientCounters {
+	return &clientCounters{
+		methods: make(map[string]*MethodCounters),
+	}
+}
+
+// record counts the request of the method with its outcome.
+func (c *clientCounters) record(method string, err error, rpcResp interface{}) {
+	var engineErr bool
+	if err == nil {
+		if r, ok := rpcResp.(engineErrorer); ok && r.engineError() != nil {
+			engineErr = true
+		}
+	}
+
+	atomic.AddInt64(&c.requests, 1)
+	if err != nil || engineErr {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	if engineErr {
+		atomic.AddInt64(&c.engineErrors, 1)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	m, ok := c.methods[method]
+	if !ok {
+		m = &MethodCounters{}
+		c.methods[method] = m
+	}
+
+	m.Requests++
+	if err != nil || engineErr {
+		m.Errors++
+	}
+	if engineErr {
+		m.EngineErrors++
+	}
+}
+
+func (c *clientCounters) retry() {
+	atomic.AddInt64(&c.retries, 1)
+}
+
+func (c *clientCounters) hedge() {
+	atomic.AddInt64(&c.hedges, 1)
+}
+
+func (c *clientCounters) snapshot() ClientCounters {
+	s := ClientCounters{
+		Requests:     atomic.LoadInt64(&c.requests),
+		Errors:       atomic.LoadInt64(&c.errors),
+		EngineErrors: atomic.LoadInt64(&c.engineErrors),
+		Retries:      atomic.LoadInt64(&c.retries),
+		BreakerTrips: atomic.LoadInt64(&c.breakerTrips),
+		Hedges:       atomic.LoadInt64(&c.hedges),
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	s.Methods = make(map[string]MethodCounters, len(c.methods))
+	for method, m := range c.methods {
+		s.Methods[method] = *m
+	}
+
+	return s
+}
+
+// Counters returns the snapshot of the client request counters.
+func (e *Client) Counters() ClientCounters {
+	return e.counters.snapshot()
+}
+
+// PublishExpvar publishes the client counters via expvar under the given
+// name, so that they are exposed on /debug/vars. Every client should be
+// published under its own name.
+func (e *Client) PublishExpvar(prefix string) error {
+	if prefix == "" {
+		return errors.New("expvar prefix should be specified")
+	}
+
+	if expvar.Get(prefix) != nil {
+		return fmt.Errorf("expvar %q is already published", prefix)
+	}
+
+	expvar.Publish(prefix, expvar.Func(func() interface{} {
+		return e.Counters()
+	}))
+
+	return nil
+}
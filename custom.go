@@ -0,0 +1,186 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// customMethod is the declaration of the registered method.
+type customMethod struct {
+	req  reflect.Type
+	resp reflect.Type
+
+	// readOnly is set if the method doesn't change the state of the
+	// engine, idempotent is set if the mutating method is safe to repeat.
+	readOnly   bool
+	idempotent bool
+}
+
+// MethodOption declares the properties of the registered method.
+type MethodOption func(m *customMethod)
+
+// ReadOnly declares that the method doesn't change the state of the
+// engine, so that it is repeated by the retry policy and it isn't guarded
+// by the leader lease.
+func ReadOnly() MethodOption {
+	return func(m *customMethod) {
+		m.readOnly = true
+	}
+}
+
+// Idempotent declares that the mutating method is safe to repeat, e.g.
+// because the engine deduplicates it by the business id, so that it is
+// repeated by the retry policy. It is still guarded by the leader lease.
+func Idempotent() MethodOption {
+	return func(m *customMethod) {
+		m.idempotent = true
+	}
+}
+
+// lookupCustomMethod returns the declaration of the registered method.
+func lookupCustomMethod(name string) (customMethod, bool) {
+	customMethodsMtx.RLock()
+	defer customMethodsMtx.RUnlock()
+
+	m, ok := customMethods[name]
+	return m, ok
+}
+
+var (
+	customMethodsMtx sync.RWMutex
+	customMethods    = make(map[string]customMethod)
+)
+
+// Method is the type-safe handle of the engine method which isn't
+// supported by the package, e.g. the one added by the private fork of the
+// matching engine. Fields of the request are passed as the positional
+// params in the order of declaration, in the same way as for built-in
+// methods, result is decoded into the response type:
+//
+//	type FreezeRequest struct {
+//		UserID uint32
+//		Asset  string
+//		Amount string
+//	}
+//
+//	var freeze = viabtc.MustRegisterMethod[FreezeRequest, string](
+//		"balance.freeze")
+//
+//	status, err := freeze.Call(client, &FreezeRequest{1, "BTC", "0.5"})
+//
+// Custom methods are considered mutating unless they are registered with
+// ReadOnly: they are guarded by the leader lease, and they aren't repeated
+// by the retry policy unless they are registered with Idempotent.
+type Method[Req, Resp any] struct {
+	name string
+}
+
+// RegisterMethod declares the engine method with the given request and
+// response types. Request should be the struct or the slice. Method might
+// be registered again only with the same types and options.
+func RegisterMethod[Req, Resp any](name string,
+	opts ...MethodOption) (*Method[Req, Resp], error) {
+
+	if name == "" {
+		return nil, errors.New("method name should be specified")
+	}
+
+	req := reflect.TypeOf((*Req)(nil)).Elem()
+	switch req.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array:
+	default:
+		return nil, fmt.Errorf("request of %v should be struct or "+
+			"slice, got %v", name, req)
+	}
+
+	decl := customMethod{
+		req:  req,
+		resp: reflect.TypeOf((*Resp)(nil)).Elem(),
+	}
+	for _, opt := range opts {
+		opt(&decl)
+	}
+
+	customMethodsMtx.Lock()
+	defer customMethodsMtx.Unlock()
+
+	if registered, ok := customMethods[name]; ok && registered != decl {
+		return nil, fmt.Errorf("method %v is already registered with "+
+			"request %v and response %v", name, registered.req,
+			registered.resp)
+	}
+	customMethods[name] = decl
+
+	return &Method[Req, Resp]{name: name}, nil
+}
+
+// MustRegisterMethod is the same as RegisterMethod, but it panics if the
+// method can't be registered. It is intended to be used for the
+// initialization of package level variables.
+func MustRegisterMethod[Req, Resp any](name string,
+	opts ...MethodOption) *Method[Req, Resp] {
+
+	m, err := RegisterMethod[Req, Resp](name, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// RegisteredMethods returns the names of the registered custom methods.
+func RegisteredMethods() []string {
+	customMethodsMtx.RLock()
+	defer customMethodsMtx.RUnlock()
+
+	names := make([]string, 0, len(customMethods))
+	for name := range customMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Name returns the name of the method.
+func (m *Method[Req, Resp]) Name() string {
+	return m.name
+}
+
+// Call executes the method using the client.
+func (m *Method[Req, Resp]) Call(client *Client, params *Req) (Resp, error) {
+	return m.CallContext(context.Background(), client, params)
+}
+
+// CallContext is the same as Call, but the request is bound to the given
+// context.
+func (m *Method[Req, Resp]) CallContext(ctx context.Context, client *Client,
+	params *Req) (Resp, error) {
+
+	type Response struct {
+		baseResponse
+		Result Resp
+	}
+
+	var zero Resp
+	if params == nil {
+		params = new(Req)
+	}
+
+	response := &Response{}
+	err := client.makeRPCCallContext(ctx, m.name, params, response)
+	if err != nil {
+		return zero, err
+	}
+
+	// https://golang.org/doc/faq#nil_error
+	if response.Error != nil {
+		return zero, response.Error
+	}
+
+	return response.Result, nil
+}
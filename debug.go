@@ -0,0 +1,225 @@
+package viabtc
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DebugConfig is the redacted configuration of the client. Pluggable
+// components are reported only by their type, so that credentials which
+// they might hold don't leak into support bundles.
+type DebugConfig struct {
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	URL             string `json:"url"`
+	UserAgent       string `json:"user_agent"`
+	ClampPagination bool   `json:"clamp_pagination"`
+	DefaultsEnabled bool   `json:"defaults_enabled"`
+
+	FanOutConcurrency int  `json:"fan_out_concurrency"`
+	SymbolMapEnabled  bool `json:"symbol_map_enabled"`
+
+	MaxClockSkew    string `json:"max_clock_skew"`
+	AdjustClockSkew bool   `json:"adjust_clock_skew"`
+
+	TLS bool `json:"tls"`
+
+	BasicAuth   bool     `json:"basic_auth"`
+	BearerToken bool     `json:"bearer_token"`
+	Headers     []string `json:"headers,omitempty"`
+
+	// Proxy is the proxy url without the credentials.
+	Proxy           string `json:"proxy,omitempty"`
+	DisableEnvProxy bool   `json:"disable_env_proxy"`
+
+	DialTimeout           string `json:"dial_timeout"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout"`
+	ResponseBodyTimeout   string `json:"response_body_timeout"`
+	RequestTimeout        string `json:"request_timeout"`
+
+	MaxIdleConns        int    `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int    `json:"max_conns_per_host"`
+	IdleConnTimeout     string `json:"idle_conn_timeout"`
+	KeepAlive           string `json:"keep_alive"`
+	DisableKeepAlives   bool   `json:"disable_keep_alives"`
+
+	ResolveInterval string `json:"resolve_interval"`
+	SRVName         string `json:"srv_name,omitempty"`
+	EndpointSource  string `json:"endpoint_source,omitempty"`
+	RateLimiter     string `json:"rate_limiter,omitempty"`
+	LeaderLease     string `json:"leader_lease,omitempty"`
+	Signer          string `json:"signer,omitempty"`
+}
+
+// DebugEndpoints is the state of the endpoint discovery.
+type DebugEndpoints struct {
+	Enabled   bool     `json:"enabled"`
+	Endpoints []string `json:"endpoints"`
+	LastError string   `json:"last_error,omitempty"`
+}
+
+// DebugRegistry is the state of the markets and assets registry.
+type DebugRegistry struct {
+	Markets   int       `json:"markets"`
+	Assets    int       `json:"assets"`
+	Updated   time.Time `json:"updated"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// DebugSnapshot is the structured snapshot of the client state which is
+// intended to be attached to support bundles.
+type DebugSnapshot struct {
+	Taken  time.Time   `json:"taken"`
+	Closed bool        `json:"closed"`
+	Config DebugConfig `json:"config"`
+
+	Endpoints DebugEndpoints `json:"endpoints"`
+
+	// Leader is true if the process holds the leader lease, it is absent
+	// if lease isn't configured.
+	Leader *bool `json:"leader,omitempty"`
+
+	// ClockSkew is the last measured skew, it is absent if it hasn't been
+	// measured.
+	ClockSkew string `json:"clock_skew,omitempty"`
+
+	// Breaker is the state of the circuit breaker, it is absent if the
+	// breaker isn't configured.
+	Breaker string `json:"breaker,omitempty"`
+
+	// ActiveEndpoint is the host which requests are sent to, it is absent
+	// if failover isn't configured.
+	ActiveEndpoint string `json:"active_endpoint,omitempty"`
+
+	Registry DebugRegistry `json:"registry"`
+
+	// MarketStats is the freshness of the market data per market.
+	MarketStats map[string]MarketDataStats `json:"market_stats"`
+
+	// Reservations is the number of user assets with funds reserved by
+	// orders which are being placed.
+	Reservations int `json:"reservations"`
+
+	// CacheEntries is the number of cached klines and deal pages.
+	CacheEntries int `json:"cache_entries"`
+
+	Counters ClientCounters `json:"counters"`
+}
+
+// typeName returns the type of the component, or empty string if it is nil.
+func typeName(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// Debug returns the snapshot of the client configuration and internal
+// state, it is serializable to json.
+func (e *Client) Debug() *DebugSnapshot {
+	cfg := &e.cfg
+
+	snapshot := &DebugSnapshot{
+		Taken:  e.now().UTC(),
+		Closed: atomic.LoadInt32(&e.closed) == 1,
+		Config: DebugConfig{
+			Host:                  cfg.Host,
+			Port:                  cfg.Port,
+			URL:                   e.url,
+			UserAgent:             cfg.userAgent(),
+			ClampPagination:       cfg.ClampPagination,
+			DefaultsEnabled:       e.defaults != nil,
+			FanOutConcurrency:     cfg.FanOutConcurrency,
+			SymbolMapEnabled:      cfg.SymbolMap != nil,
+			MaxClockSkew:          cfg.MaxClockSkew.String(),
+			AdjustClockSkew:       cfg.AdjustClockSkew,
+			TLS:                   cfg.scheme() == "https",
+			BasicAuth:             cfg.BasicAuth != nil,
+			BearerToken:           cfg.BearerToken != "",
+			DisableEnvProxy:       cfg.DisableEnvProxy,
+			DialTimeout:           cfg.dialTimeout().String(),
+			TLSHandshakeTimeout:   cfg.tlsHandshakeTimeout().String(),
+			ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.String(),
+			ResponseBodyTimeout:   cfg.ResponseBodyTimeout.String(),
+			RequestTimeout:        cfg.requestTimeout().String(),
+			MaxIdleConns:          cfg.MaxIdleConns,
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cfg.MaxConnsPerHost,
+			IdleConnTimeout:       cfg.IdleConnTimeout.String(),
+			KeepAlive:             cfg.keepAlive().String(),
+			DisableKeepAlives:     cfg.DisableKeepAlives,
+			ResolveInterval:       cfg.ResolveInterval.String(),
+			SRVName:               cfg.SRVName,
+			EndpointSource:        typeName(cfg.EndpointSource),
+			RateLimiter:           typeName(cfg.RateLimiter),
+			LeaderLease:           typeName(cfg.LeaderLease),
+			Signer:                typeName(cfg.Signer),
+		},
+		MarketStats: e.AllMarketStats(),
+		Counters:    e.Counters(),
+	}
+
+	if cfg.Proxy != nil {
+		proxy := *cfg.Proxy
+		proxy.User = nil
+		snapshot.Config.Proxy = proxy.String()
+	}
+
+	// Only the names of the headers are reported, since their values
+	// might be credentials.
+	for key := range cfg.Headers {
+		snapshot.Config.Headers = append(snapshot.Config.Headers, key)
+	}
+	sort.Strings(snapshot.Config.Headers)
+
+	if e.discovering() {
+		snapshot.Endpoints = DebugEndpoints{
+			Enabled:   true,
+			Endpoints: e.Endpoints(),
+		}
+		if err := e.EndpointsErr(); err != nil {
+			snapshot.Endpoints.LastError = err.Error()
+		}
+	}
+
+	if cfg.LeaderLease != nil {
+		leader := cfg.LeaderLease.IsLeader()
+		snapshot.Leader = &leader
+	}
+
+	if skew, ok := e.ClockSkew(); ok {
+		snapshot.ClockSkew = skew.String()
+	}
+
+	if e.breaker != nil {
+		snapshot.Breaker = e.breaker.current().String()
+	}
+
+	snapshot.ActiveEndpoint = e.ActiveEndpoint()
+
+	e.registry.mtx.RLock()
+	snapshot.Registry = DebugRegistry{
+		Markets: len(e.registry.markets),
+		Assets:  len(e.registry.assets),
+		Updated: e.registry.updated,
+	}
+	if e.registry.lastErr != nil {
+		snapshot.Registry.LastError = e.registry.lastErr.Error()
+	}
+	e.registry.mtx.RUnlock()
+
+	if e.cache != nil {
+		snapshot.CacheEntries = e.cache.len()
+	}
+
+	e.ledger.Lock()
+	snapshot.Reservations = len(e.ledger.reserved)
+	e.ledger.Unlock()
+
+	return snapshot
+}
@@ -0,0 +1,101 @@
+package viabtc
+
+import "sync"
+
+// dealKey identifies the deal. Deal of the user's own orders is returned
+// twice in the user's history, once per side, so side is part of the key.
+type dealKey struct {
+	id   int32
+	side MarketOrderSide
+}
+
+// DealDeduplicator remembers the ids of the seen deals, so that the deals
+// returned by the overlapping time-window queries or by the restarted
+// cursors are counted only once. If capacity is specified, only the given
+// number of the most recently seen deals are remembered.
+type DealDeduplicator struct {
+	capacity int
+
+	mtx   sync.Mutex
+	seen  map[dealKey]struct{}
+	order []dealKey
+}
+
+// NewDealDeduplicator creates new deduplicator which remembers at most
+// capacity deals, the number of remembered deals is unlimited if capacity
+// is zero.
+func NewDealDeduplicator(capacity int) *DealDeduplicator {
+	return &DealDeduplicator{
+		capacity: capacity,
+		seen:     make(map[dealKey]struct{}),
+	}
+}
+
+// add marks the deal as seen, false is returned if it has been already
+// seen.
+func (d *DealDeduplicator) add(key dealKey) bool {
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = struct{}{}
+
+	if d.capacity > 0 {
+		d.order = append(d.order, key)
+		if len(d.order) > d.capacity {
+			delete(d.seen, d.order[0])
+			d.order = d.order[1:]
+		}
+	}
+
+	return true
+}
+
+// Seen returns true if the deal with the given id has been already seen,
+// otherwise the deal is remembered.
+func (d *DealDeduplicator) Seen(dealID int32) bool {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return !d.add(dealKey{id: dealID})
+}
+
+// Deals returns the market deals which haven't been seen before, the order
+// of the deals is preserved.
+func (d *DealDeduplicator) Deals(deals MarketDealsResponse) MarketDealsResponse {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	var fresh MarketDealsResponse
+	for _, deal := range deals {
+		if d.add(dealKey{id: deal.DealID}) {
+			fresh = append(fresh, deal)
+		}
+	}
+
+	return fresh
+}
+
+// DealDetails returns the user's deals which haven't been seen before, the
+// order of the deals is preserved.
+func (d *DealDeduplicator) DealDetails(deals []DealDetail) []DealDetail {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	var fresh []DealDetail
+	for _, deal := range deals {
+		if d.add(dealKey{id: deal.DealID, side: deal.Side}) {
+			fresh = append(fresh, deal)
+		}
+	}
+
+	return fresh
+}
+
+// Reset forgets all seen deals.
+func (d *DealDeduplicator) Reset() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.seen = make(map[dealKey]struct{})
+	d.order = nil
+}
@@ -0,0 +1,150 @@
+package viabtc
+
+import "time"
+
+const (
+	// DefaultTimeRange is the time range which is used for time-ranged
+	// queries if start and end time aren't specified.
+	DefaultTimeRange = 24 * time.Hour
+
+	// DefaultKLineInterval is the kline interval in seconds which is used if
+	// it isn't specified.
+	DefaultKLineInterval int32 = 3600
+
+	// DefaultDepthInterval is the depth merge interval which is used if it
+	// isn't specified, zero means that volumes aren't merged.
+	DefaultDepthInterval = "0"
+
+	// DefaultStatusPeriod is the market status period in seconds which is
+	// used if it isn't specified.
+	DefaultStatusPeriod int32 = 86400
+)
+
+// RequestDefaults holds the values which are used to fill omitted optional
+// fields of the requests, so that quick scripts don't need to populate every
+// field of every request. Zero fields are replaced with package defaults.
+type RequestDefaults struct {
+	// Limit is used for paginated requests with zero limit.
+	Limit int32
+
+	// TimeRange is used for time-ranged requests without start and end
+	// time, the range ends at the current moment.
+	TimeRange time.Duration
+
+	// KLineInterval is used for kline requests with zero interval.
+	KLineInterval int32
+
+	// DepthInterval is used for depth requests with empty interval.
+	DepthInterval string
+
+	// StatusPeriod is used for market status requests with zero period.
+	StatusPeriod int32
+}
+
+// withFallbacks returns the copy of defaults with zero fields replaced by
+// package defaults.
+func (d RequestDefaults) withFallbacks() *RequestDefaults {
+	if d.Limit == 0 {
+		d.Limit = MaxLimit
+	}
+
+	if d.TimeRange == 0 {
+		d.TimeRange = DefaultTimeRange
+	}
+
+	if d.KLineInterval == 0 {
+		d.KLineInterval = DefaultKLineInterval
+	}
+
+	if d.DepthInterval == "" {
+		d.DepthInterval = DefaultDepthInterval
+	}
+
+	if d.StatusPeriod == 0 {
+		d.StatusPeriod = DefaultStatusPeriod
+	}
+
+	return &d
+}
+
+func (d *RequestDefaults) fillLimit(limit *int32) {
+	if *limit == 0 {
+		*limit = d.Limit
+	}
+}
+
+// fillTimeRange fills the time range ending at the given moment if it
+// isn't specified, or fills only the end time if start time is given.
+func (d *RequestDefaults) fillTimeRange(start, end *float64, t time.Time) {
+	now := float64(t.Unix())
+
+	if *end == 0 {
+		*end = now
+	}
+
+	if *start == 0 {
+		*start = *end - d.TimeRange.Seconds()
+	}
+}
+
+// fillDefaults fills the omitted fields of the request, if client is
+// configured with defaults. Request should be the copy of the user's
+// request, because it is modified in place.
+func (e *Client) fillDefaults(params interface{}) {
+	d := e.defaults
+	if d == nil {
+		return
+	}
+
+	switch p := params.(type) {
+	case *BalanceHistoryRequest:
+		d.fillLimit(&p.Limit)
+		d.fillTimeRange(&p.StartTime, &p.EndTime, e.now())
+
+	case *OrderDealsRequest:
+		d.fillLimit(&p.Limit)
+
+	case *OrderBookRequest:
+		d.fillLimit(&p.Limit)
+
+	case *OrderDepthRequest:
+		d.fillLimit(&p.Limit)
+		if p.Interval == "" {
+			p.Interval = d.DepthInterval
+		}
+
+	case *OrderPendingRequest:
+		d.fillLimit(&p.Limit)
+
+	case *OrderFinishedRequest:
+		d.fillLimit(&p.Limit)
+		d.fillTimeRange(&p.StartTime, &p.EndTime, e.now())
+
+	case *OrderPendingStopRequest:
+		d.fillLimit(&p.Limit)
+
+	case *OrderFinishedStopRequest:
+		d.fillLimit(&p.Limit)
+		d.fillTimeRange(&p.StartTime, &p.EndTime, e.now())
+
+	case *MarketDealsRequest:
+		d.fillLimit(&p.Limit)
+
+	case *MarketDealsExtRequest:
+		d.fillLimit(&p.Limit)
+
+	case *MarketUserDealsRequest:
+		d.fillLimit(&p.Limit)
+
+	case *MarketKLineRequest:
+		d.fillTimeRange(&p.StartTime, &p.EndTime, e.now())
+		if p.Interval == 0 {
+			p.Interval = d.KLineInterval
+		}
+
+	case *MarketStatusRequest:
+		if p.Period == 0 {
+			p.Period = d.StatusPeriod
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package viabtc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDiscoveryTimeout is the timeout of the request to the service
+// registry if not specified otherwise.
+const defaultDiscoveryTimeout = 5 * time.Second
+
+// registryRequest makes the request to the service registry and decodes the
+// json response into the result.
+func registryRequest(httpClient *http.Client, req *http.Request,
+	result interface{}) error {
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// ConsulConfig is an structure which holds configurable parameters of the
+// Consul endpoint source.
+type ConsulConfig struct {
+	// Address is the url of the Consul http api, e.g.
+	// "http://127.0.0.1:8500".
+	Address string
+
+	// Service is the name of the registered accesshttp service.
+	Service string
+
+	// Tag, if set, filters the service instances by tag.
+	Tag string
+
+	// Datacenter, if set, is the datacenter to query instead of the agent's
+	// one.
+	Datacenter string
+
+	// Token is the ACL token of the requests.
+	Token string
+
+	// Timeout is the timeout of the request to Consul.
+	Timeout time.Duration
+}
+
+// ConsulSource discovers the endpoints from the Consul catalog, only the
+// instances which pass their health checks are returned.
+type ConsulSource struct {
+	cfg        ConsulConfig
+	httpClient *http.Client
+}
+
+// A compile time check to ensure ConsulSource implements the EndpointSource
+// interface.
+var _ EndpointSource = (*ConsulSource)(nil)
+
+// NewConsulSource creates new instance of Consul endpoint source.
+func NewConsulSource(cfg *ConsulConfig) *ConsulSource {
+	c := *cfg
+	if c.Timeout <= 0 {
+		c.Timeout = defaultDiscoveryTimeout
+	}
+
+	return &ConsulSource{
+		cfg:        c,
+		httpClient: &http.Client{Timeout: c.Timeout},
+	}
+}
+
+// consulServiceEntry is the part of the Consul health api response which
+// is needed to get the address of the service instance.
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// Endpoints returns the addresses of the healthy instances of the service.
+func (s *ConsulSource) Endpoints() ([]string, error) {
+	query := url.Values{}
+	query.Set("passing", "true")
+	if s.cfg.Tag != "" {
+		query.Set("tag", s.cfg.Tag)
+	}
+	if s.cfg.Datacenter != "" {
+		query.Set("dc", s.cfg.Datacenter)
+	}
+
+	u := fmt.Sprintf("%v/v1/health/service/%v?%v",
+		strings.TrimSuffix(s.cfg.Address, "/"),
+		url.PathEscape(s.cfg.Service), query.Encode())
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", s.cfg.Token)
+	}
+
+	var entries []consulServiceEntry
+	if err := registryRequest(s.httpClient, req, &entries); err != nil {
+		return nil, fmt.Errorf("unable to query consul service %v: %w",
+			s.cfg.Service, err)
+	}
+
+	addrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Service address is empty if it is the same as the node one.
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+
+		addrs = append(addrs, net.JoinHostPort(host,
+			strconv.Itoa(entry.Service.Port)))
+	}
+
+	return addrs, nil
+}
+
+// EtcdConfig is an structure which holds configurable parameters of the
+// etcd endpoint source.
+type EtcdConfig struct {
+	// Address is the url of the etcd v3 http gateway, e.g.
+	// "http://127.0.0.1:2379".
+	Address string
+
+	// Prefix is the key prefix under which the endpoints are registered,
+	// e.g. "/services/accesshttp/". Every key holds the single endpoint.
+	Prefix string
+
+	// Token, if set, is the auth token of the requests.
+	Token string
+
+	// Timeout is the timeout of the request to etcd.
+	Timeout time.Duration
+}
+
+// EtcdSource discovers the endpoints from the etcd keys with the common
+// prefix. The value of the key is either "host:port" address, or json
+// object with "Addr" field, which is used by etcd endpoint manager.
+type EtcdSource struct {
+	cfg        EtcdConfig
+	httpClient *http.Client
+}
+
+// A compile time check to ensure EtcdSource implements the EndpointSource
+// interface.
+var _ EndpointSource = (*EtcdSource)(nil)
+
+// NewEtcdSource creates new instance of etcd endpoint source.
+func NewEtcdSource(cfg *EtcdConfig) *EtcdSource {
+	c := *cfg
+	if c.Timeout <= 0 {
+		c.Timeout = defaultDiscoveryTimeout
+	}
+
+	return &EtcdSource{
+		cfg:        c,
+		httpClient: &http.Client{Timeout: c.Timeout},
+	}
+}
+
+// prefixRangeEnd returns the end of the key range which covers all keys
+// with the given prefix.
+func prefixRangeEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+
+	// Prefix consists of 0xff bytes, range ends with the last key.
+	return "\x00"
+}
+
+// etcdRangeResponse is the response of etcd range api.
+type etcdRangeResponse struct {
+	KVs []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"kvs"`
+}
+
+// parseEtcdEndpoint returns the address stored in the etcd value.
+func parseEtcdEndpoint(value []byte) (string, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || value[0] != '{' {
+		return string(value), nil
+	}
+
+	var endpoint struct {
+		Addr string `json:"Addr"`
+	}
+	if err := json.Unmarshal(value, &endpoint); err != nil {
+		return "", err
+	}
+
+	return endpoint.Addr, nil
+}
+
+// Endpoints returns the addresses registered under the prefix.
+func (s *EtcdSource) Endpoints() ([]string, error) {
+	body, err := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(s.cfg.Prefix)),
+		"range_end": base64.StdEncoding.EncodeToString([]byte(prefixRangeEnd(s.cfg.Prefix))),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := strings.TrimSuffix(s.cfg.Address, "/") + "/v3/kv/range"
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", s.cfg.Token)
+	}
+
+	var resp etcdRangeResponse
+	if err := registryRequest(s.httpClient, req, &resp); err != nil {
+		return nil, fmt.Errorf("unable to query etcd prefix %v: %w",
+			s.cfg.Prefix, err)
+	}
+
+	addrs := make([]string, 0, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode etcd value: %w", err)
+		}
+
+		addr, err := parseEtcdEndpoint(value)
+		if err != nil {
+			key, _ := base64.StdEncoding.DecodeString(kv.Key)
+			return nil, fmt.Errorf("wrong endpoint in etcd key %v: %w",
+				string(key), err)
+		}
+
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,273 @@
+package viabtc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResolveInterval is the interval with which discovered endpoints
+// are refreshed if not specified otherwise.
+const DefaultResolveInterval = 30 * time.Second
+
+// defaultDiscoveredHost is the host header of the requests if the host
+// isn't specified and endpoints are discovered from the endpoint source.
+const defaultDiscoveredHost = "accesshttp"
+
+// endpointPool holds the set of addresses of the server, and spreads new
+// connections across them in round-robin order. The set is refreshed in the
+// background, and idle connections are closed on every refresh, so that
+// long-living keep-alive connections don't pin the client to the single
+// address for the life of the process.
+type endpointPool struct {
+	mtx   sync.Mutex
+	addrs []string
+	next  int
+
+	// closeIdle closes idle connections of the transport.
+	closeIdle func()
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	lastErr error
+}
+
+func newEndpointPool(closeIdle func()) *endpointPool {
+	return &endpointPool{
+		closeIdle: closeIdle,
+		quit:      make(chan struct{}),
+	}
+}
+
+// set replaces the address set of the pool. Empty sets are ignored, so that
+// the temporary resolution failure doesn't leave client without endpoints.
+func (p *endpointPool) set(addrs []string) {
+	if len(addrs) == 0 {
+		return
+	}
+
+	sorted := make([]string, len(addrs))
+	copy(sorted, addrs)
+	sort.Strings(sorted)
+
+	p.mtx.Lock()
+	p.addrs = sorted
+	if p.next >= len(sorted) {
+		p.next = 0
+	}
+	p.mtx.Unlock()
+
+	// New connections are spread across the refreshed set, while the busy
+	// ones are closed by the transport after they become idle.
+	p.closeIdle()
+}
+
+// pick returns the next address of the pool, false if pool is empty.
+func (p *endpointPool) pick() (string, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.addrs) == 0 {
+		return "", false
+	}
+
+	addr := p.addrs[p.next%len(p.addrs)]
+	p.next = (p.next + 1) % len(p.addrs)
+	return addr, true
+}
+
+// endpoints returns the current address set.
+func (p *endpointPool) endpoints() []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	addrs := make([]string, len(p.addrs))
+	copy(addrs, p.addrs)
+	return addrs
+}
+
+// run periodically refreshes the address set using the given function,
+// until pool is stopped.
+func (p *endpointPool) run(interval time.Duration,
+	refresh func() ([]string, error)) {
+
+	update := func() {
+		addrs, err := refresh()
+		if err == nil {
+			p.set(addrs)
+		}
+
+		p.mtx.Lock()
+		p.lastErr = err
+		p.mtx.Unlock()
+	}
+
+	update()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				update()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the background refresh.
+func (p *endpointPool) stop() {
+	select {
+	case <-p.quit:
+	default:
+		close(p.quit)
+	}
+
+	p.wg.Wait()
+}
+
+// err returns the error of the last refresh.
+func (p *endpointPool) err() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.lastErr
+}
+
+// dialContext returns the dial function which connects to the address
+// picked from the pool instead of the requested one. Requested address is
+// used if the pool is empty.
+func (p *endpointPool) dialContext(dialer *net.Dialer) func(ctx context.Context,
+	network, addr string) (net.Conn, error) {
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if picked, ok := p.pick(); ok {
+			addr = picked
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// EndpointSource is the source of the server endpoints, such as DNS or
+// service registry. Client periodically asks the source for the current
+// endpoint set and spreads connections across it.
+type EndpointSource interface {
+	// Endpoints returns the current set of server addresses in "host:port"
+	// form.
+	Endpoints() ([]string, error)
+}
+
+// HostSource resolves the host name into the set of addresses.
+type HostSource struct {
+	Host string
+	Port int
+}
+
+// A compile time check to ensure HostSource implements the EndpointSource
+// interface.
+var _ EndpointSource = (*HostSource)(nil)
+
+// Endpoints returns the addresses of the host with the given port.
+func (s *HostSource) Endpoints() ([]string, error) {
+	ips, err := net.LookupHost(s.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %v: %w", s.Host, err)
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(s.Port)))
+	}
+
+	return addrs, nil
+}
+
+// SRVSource discovers the endpoints using SRV records of the name, e.g.
+// "_accesshttp._tcp.example.com".
+type SRVSource struct {
+	Name string
+}
+
+// A compile time check to ensure SRVSource implements the EndpointSource
+// interface.
+var _ EndpointSource = (*SRVSource)(nil)
+
+// Endpoints returns the addresses of the targets of the SRV records with
+// the lowest priority, targets with higher priority are considered to be
+// backups and are used only if all of the preferred targets are gone from
+// the records.
+func (s *SRVSource) Endpoints() ([]string, error) {
+	_, records, err := net.LookupSRV("", "", s.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lookup SRV records of %v: %w",
+			s.Name, err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no SRV records of %v", s.Name)
+	}
+
+	// Records are sorted by priority by the lookup.
+	priority := records[0].Priority
+
+	var addrs []string
+	for _, record := range records {
+		if record.Priority != priority {
+			break
+		}
+
+		target := strings.TrimSuffix(record.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(target,
+			strconv.Itoa(int(record.Port))))
+	}
+
+	return addrs, nil
+}
+
+// Endpoints returns the discovered addresses which client currently
+// spreads its connections across, or the failover targets in the order of
+// preference if failover is configured along with the discovery. Empty
+// list is returned if endpoint discovery is disabled.
+func (e *Client) Endpoints() []string {
+	switch {
+	case e.pool != nil:
+		return e.pool.endpoints()
+	case e.failover.discovering():
+		return e.failover.endpoints()
+	default:
+		return nil
+	}
+}
+
+// EndpointsErr returns the error of the last endpoint refresh, nil if it
+// was successful or endpoint discovery is disabled.
+func (e *Client) EndpointsErr() error {
+	switch {
+	case e.pool != nil:
+		return e.pool.err()
+	case e.failover.discovering():
+		return e.failover.err()
+	default:
+		return nil
+	}
+}
+
+// discovering returns true if the endpoints are discovered, either to
+// spread the connections across them or as the failover targets.
+func (e *Client) discovering() bool {
+	return e.pool != nil || e.failover.discovering()
+}
@@ -1,32 +1,291 @@
 package viabtc
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned if the response body exceeds the
+// configured max response size.
+var ErrResponseTooLarge = errors.New("response body is too large")
+
 // EngineCodeError the error code which is used to identify the exact problem
 // which occurred on the exchange client side.
 type EngineCodeError uint8
 
+// Codes from 1 to 5 have the same meaning for all methods, while the
+// meaning of the codes starting from 10 depends on the method, see the
+// method specific codes below. Errors should be matched with errors.Is and
+// the sentinel errors, which account for the method.
 const (
 	CodeInvalidArgument    EngineCodeError = 1
 	CodeInternalError                      = 2
 	CodeServiceUnavailable                 = 3
 	CodeMethodNotFound                     = 4
 	CodeServiceTimeOut                     = 5
-	CodeBalanceNotEnough                   = 10
-	CodeRepeatUpdate                       = 11
-	CodeAmountToSmall                      = 12
-	CodeNoEnoughTrader                     = 13
 )
 
-type Error struct {
-	// Code...
+// Deprecated: codes starting from 10 are method specific, these values
+// match only some methods, e.g. balance.update returns 10 on repeated
+// update and 11 if balance isn't enough. Use the method specific codes or
+// the sentinel errors instead.
+const (
+	CodeBalanceNotEnough = 10
+	CodeRepeatUpdate     = 11
+	CodeAmountToSmall    = 12
+	CodeNoEnoughTrader   = 13
+)
+
+// Method specific codes, as they are returned by the matchengine.
+const (
+	// balance.update
+	CodeUpdateRepeat           EngineCodeError = 10
+	CodeUpdateBalanceNotEnough EngineCodeError = 11
+
+	// order.put_limit, order.put_market and order.put_stop_market
+	CodeOrderBalanceNotEnough EngineCodeError = 10
+	CodeOrderAmountTooSmall   EngineCodeError = 11
+	CodeOrderNoEnoughTrader   EngineCodeError = 12
+
+	// order.cancel and order.cancel_stop
+	CodeCancelOrderNotFound EngineCodeError = 10
+	CodeCancelUserNotMatch  EngineCodeError = 11
+)
+
+// RPCError is the error returned by the engine in the response. It matches
+// the sentinel errors of its code with errors.Is, e.g.
+//
+//	if errors.Is(err, viabtc.ErrBalanceNotEnough) {
+//		...
+//	}
+type RPCError struct {
+	// Code is the engine error code, its meaning depends on the method.
 	Code EngineCodeError `json:"code"`
 
-	// Message...
+	// Message is the human readable description of the error.
 	Message string `json:"message"`
+
+	// Method is the rpc method which returned the error, it is filled by
+	// the client.
+	Method string `json:"-"`
 }
 
-// A compile time check to ensure Error implements the error interface.
-var _ error = (*Error)(nil)
+// Error is the former name of RPCError.
+//
+// Deprecated: use RPCError instead.
+type Error = RPCError
 
-func (e *Error) Error() string {
+// A compile time check to ensure RPCError implements the error interface.
+var _ error = (*RPCError)(nil)
+
+func (e *RPCError) Error() string {
 	return e.Message
 }
+
+// Is reports whether the error matches the sentinel error of its code.
+func (e *RPCError) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+
+	return e.sentinel() == target
+}
+
+// sentinel returns the sentinel error of the code, nil if there is none.
+func (e *RPCError) sentinel() error {
+	if codes, ok := methodErrors[e.Method]; ok {
+		if err, ok := codes[e.Code]; ok {
+			return err
+		}
+	}
+
+	return commonErrors[e.Code]
+}
+
+var (
+	// ErrInvalidArgument is returned if the params of the request are
+	// rejected by the engine.
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// ErrInternal is returned if the engine failed to handle the request.
+	ErrInternal = errors.New("internal error")
+
+	// ErrServiceUnavailable is returned if the engine is unavailable.
+	ErrServiceUnavailable = errors.New("service unavailable")
+
+	// ErrMethodNotFound is returned if the method isn't supported by the
+	// engine.
+	ErrMethodNotFound = errors.New("method not found")
+
+	// ErrServiceTimeout is returned if the engine didn't handle the request
+	// in time.
+	ErrServiceTimeout = errors.New("service timeout")
+
+	// ErrBalanceNotEnough is returned if the balance of the user isn't
+	// enough to update the balance or to place the order.
+	ErrBalanceNotEnough = errors.New("balance not enough")
+
+	// ErrRepeatUpdate is returned if the balance update with the same
+	// business id has already been applied.
+	ErrRepeatUpdate = errors.New("repeat update")
+
+	// ErrAmountTooSmall is returned if the amount of the order is less
+	// than the min amount of the market.
+	ErrAmountTooSmall = errors.New("amount too small")
+
+	// ErrNoEnoughTrader is returned if there are no orders on the opposite
+	// side of the book to fill the market order.
+	ErrNoEnoughTrader = errors.New("no enough trader")
+
+	// ErrOrderNotFound is returned if the order to cancel isn't found.
+	ErrOrderNotFound = errors.New("order not found")
+
+	// ErrUserNotMatch is returned if the order to cancel belongs to the
+	// other user.
+	ErrUserNotMatch = errors.New("user not match")
+)
+
+// commonErrors is the sentinel errors of the codes which have the same
+// meaning for all methods.
+var commonErrors = map[EngineCodeError]error{
+	CodeInvalidArgument:    ErrInvalidArgument,
+	CodeInternalError:      ErrInternal,
+	CodeServiceUnavailable: ErrServiceUnavailable,
+	CodeMethodNotFound:     ErrMethodNotFound,
+	CodeServiceTimeOut:     ErrServiceTimeout,
+}
+
+// methodErrors is the sentinel errors of the method specific codes, as
+// they are returned by the matchengine.
+var methodErrors = map[string]map[EngineCodeError]error{
+	"balance.update": {
+		CodeUpdateRepeat:           ErrRepeatUpdate,
+		CodeUpdateBalanceNotEnough: ErrBalanceNotEnough,
+	},
+	"order.put_limit": {
+		CodeOrderBalanceNotEnough: ErrBalanceNotEnough,
+		CodeOrderAmountTooSmall:   ErrAmountTooSmall,
+	},
+	"order.put_market": {
+		CodeOrderBalanceNotEnough: ErrBalanceNotEnough,
+		CodeOrderAmountTooSmall:   ErrAmountTooSmall,
+		CodeOrderNoEnoughTrader:   ErrNoEnoughTrader,
+	},
+	"order.put_stop_market": {
+		CodeOrderBalanceNotEnough: ErrBalanceNotEnough,
+		CodeOrderAmountTooSmall:   ErrAmountTooSmall,
+	},
+	"order.cancel": {
+		CodeCancelOrderNotFound: ErrOrderNotFound,
+		CodeCancelUserNotMatch:  ErrUserNotMatch,
+	},
+	"order.cancel_stop": {
+		CodeCancelOrderNotFound: ErrOrderNotFound,
+		CodeCancelUserNotMatch:  ErrUserNotMatch,
+	},
+}
+
+// DecodeError is returned when the response of the exchange client couldn't
+// be decoded, for example if body is truncated, proxy returned the html
+// error page instead of json, or some field has unexpected type.
+type DecodeError struct {
+	// Method is the name of rpc method which response couldn't be decoded.
+	Method string
+
+	// Reason is the human readable description of the problem.
+	Reason string
+
+	// Snippet is the beginning of the offending payload.
+	Snippet string
+
+	// Err is the underlying decoding error, if any.
+	Err error
+}
+
+// A compile time check to ensure DecodeError implements the error interface.
+var _ error = (*DecodeError)(nil)
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unable to decode response of %v: %v, payload: %q",
+		e.Method, e.Reason, e.Snippet)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// maxHTTPErrorBody is the maximum number of bytes of the response body which
+// is preserved in HTTPError.
+const maxHTTPErrorBody = 4096
+
+// HTTPError is returned when the exchange client, or proxy which stands in
+// front of it, responded with non-200 status code. The body of such
+// response is usually not a json, and it is not decoded.
+type HTTPError struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+
+	// Header is the headers of the response.
+	Header http.Header
+
+	// Body is the beginning of the response body, it is bounded by
+	// maxHTTPErrorBody bytes.
+	Body []byte
+}
+
+// A compile time check to ensure HTTPError implements the error interface.
+var _ error = (*HTTPError)(nil)
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("status code: %v, body: %q", e.StatusCode,
+		snippet(e.Body))
+}
+
+// isTransient returns true if the error is likely caused by temporary
+// problem of the engine or network, and the request might succeed if it is
+// repeated.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case CodeInternalError, CodeServiceUnavailable, CodeServiceTimeOut:
+			return true
+		}
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return false
+	}
+
+	switch {
+	// Breaker is open because of the repeated failures, repeating the
+	// request immediately won't help.
+	case errors.Is(err, ErrBreakerOpen):
+		return false
+
+	// Rate limit is exceeded locally, the request hasn't been made.
+	case errors.Is(err, ErrRateLimited):
+		return false
+
+	// The same response is going to be returned again.
+	case errors.Is(err, ErrResponseTooLarge):
+		return false
+	}
+
+	// Errors which aren't produced by the engine are transport errors,
+	// such as refused connection or timeout.
+	return true
+}
@@ -0,0 +1,930 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// backtestPrec is the number of decimal places of the amounts reported by
+// the backtest.
+const backtestPrec = 8
+
+// BacktestConfig is an structure which holds configurable parameters of the
+// backtest.
+type BacktestConfig struct {
+	// Balances is the initial free funds of the account by asset.
+	Balances map[string]string
+
+	// TakerFeeRate and MakerFeeRate are the fee coefficients from [0;1)
+	// applied to the fills, zero if not specified. As in the engine, fee
+	// is taken from the received asset.
+	TakerFeeRate string
+	MakerFeeRate string
+
+	// Symbols, if specified, makes backtest to use unified "BASE/QUOTE"
+	// symbols instead of the engine market names.
+	Symbols *viabtc.SymbolMap
+
+	// Execution, if specified, models the latency, the slippage and the
+	// queue position of the orders. Otherwise orders are executed
+	// immediately, at the book prices and at the front of the queue.
+	Execution ExecutionModel
+}
+
+type bookLevel struct {
+	price  *big.Rat
+	amount *big.Rat
+}
+
+// backtestMarket is the recorded state of the market.
+type backtestMarket struct {
+	name   string
+	stock  string
+	money  string
+	last   string
+	volume string
+
+	// bids and asks are the last recorded depth without the liquidity
+	// taken by the simulated orders.
+	bids []*bookLevel
+	asks []*bookLevel
+}
+
+type backtestOrder struct {
+	order  Order
+	market *backtestMarket
+
+	// arrives is the time when the order reaches the matching engine,
+	// which is later than the placement if latency is modeled.
+	arrives time.Time
+
+	// arrived is the time when the order was executed by the matching
+	// engine.
+	arrived time.Time
+
+	price  *big.Rat
+	amount *big.Rat
+	filled *big.Rat
+
+	// spent is the quote asset spent by the buy order.
+	spent *big.Rat
+
+	// ahead is the volume of the level which should be traded before the
+	// resting order.
+	ahead *big.Rat
+
+	// slippage is the relative slippage of the taker execution.
+	slippage *big.Rat
+}
+
+func (o *backtestOrder) left() *big.Rat {
+	return new(big.Rat).Sub(o.amount, o.filled)
+}
+
+// Backtest implements the exchange interface over the recorded market
+// data, so that strategies written against the exchange interface might be
+// evaluated before touching the real engine. Market data is fed with the
+// watch events, e.g. the ones written by the file sink of the watch
+// supervisor, and orders are filled against the recorded depth, deals and
+// klines. Symbols are the engine market names, unless symbol mapping is
+// configured.
+type Backtest struct {
+	mtx sync.Mutex
+
+	cfg      BacktestConfig
+	takerFee *big.Rat
+	makerFee *big.Rat
+
+	now     time.Time
+	markets map[string]*backtestMarket
+	free    map[string]*big.Rat
+	locked  map[string]*big.Rat
+
+	// orders is the resting orders in the order of placement.
+	orders []*backtestOrder
+
+	// pending is the orders which haven't reached the matching engine
+	// yet.
+	pending []*backtestOrder
+	fills   []Fill
+	nextID  int64
+}
+
+// A compile time check to ensure Backtest implements the Exchange
+// interface.
+var _ Exchange = (*Backtest)(nil)
+
+// A compile time check to ensure Backtest implements the FillSource
+// interface.
+var _ FillSource = (*Backtest)(nil)
+
+func parseRat(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("wrong decimal %q", s)
+	}
+
+	return r, nil
+}
+
+func formatRat(r *big.Rat) string {
+	return r.FloatString(backtestPrec)
+}
+
+// NewBacktest creates new backtest with the initial balances.
+func NewBacktest(cfg *BacktestConfig) (*Backtest, error) {
+	b := &Backtest{
+		cfg:      *cfg,
+		takerFee: new(big.Rat),
+		makerFee: new(big.Rat),
+		markets:  make(map[string]*backtestMarket),
+		free:     make(map[string]*big.Rat),
+		locked:   make(map[string]*big.Rat),
+	}
+
+	var err error
+	if cfg.TakerFeeRate != "" {
+		if b.takerFee, err = parseRat(cfg.TakerFeeRate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MakerFeeRate != "" {
+		if b.makerFee, err = parseRat(cfg.MakerFeeRate); err != nil {
+			return nil, err
+		}
+	}
+
+	for asset, amount := range cfg.Balances {
+		if b.free[asset], err = parseRat(amount); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// balance returns the balance of the asset, creating it if needed.
+func balance(balances map[string]*big.Rat, asset string) *big.Rat {
+	b, ok := balances[asset]
+	if !ok {
+		b = new(big.Rat)
+		balances[asset] = b
+	}
+
+	return b
+}
+
+// marketState returns the state of the engine market, creating it if
+// needed. Error is returned if the market name is invalid.
+func (b *Backtest) marketState(name string) (*backtestMarket, error) {
+	m, ok := b.markets[name]
+	if !ok {
+		market, err := viabtc.ParseMarket(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m = &backtestMarket{
+			name:  name,
+			stock: string(market.Stock),
+			money: string(market.Money),
+		}
+		b.markets[name] = m
+	}
+
+	return m, nil
+}
+
+// market returns the engine market name of the symbol.
+func (b *Backtest) market(symbol string) (string, error) {
+	if b.cfg.Symbols == nil {
+		return symbol, nil
+	}
+
+	return b.cfg.Symbols.Engine(symbol)
+}
+
+// Name returns the name of the venue.
+func (b *Backtest) Name() string {
+	return "backtest"
+}
+
+// Now returns the time of the last fed event.
+func (b *Backtest) Now() time.Time {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.now
+}
+
+// Ticker returns the summary of the recorded market state.
+func (b *Backtest) Ticker(symbol string) (*Ticker, error) {
+	name, err := b.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m, ok := b.markets[name]
+	if !ok {
+		return nil, fmt.Errorf("no data recorded for %v", symbol)
+	}
+
+	ticker := &Ticker{
+		Symbol: symbol,
+		Last:   m.last,
+		Volume: m.volume,
+	}
+
+	if len(m.bids) != 0 {
+		ticker.Bid = formatRat(m.bids[0].price)
+	}
+
+	if len(m.asks) != 0 {
+		ticker.Ask = formatRat(m.asks[0].price)
+	}
+
+	return ticker, nil
+}
+
+func bookLevels(levels []*bookLevel, depth int) []Level {
+	if depth > 0 && len(levels) > depth {
+		levels = levels[:depth]
+	}
+
+	result := make([]Level, len(levels))
+	for i, l := range levels {
+		result[i] = Level{
+			Price:  formatRat(l.price),
+			Amount: formatRat(l.amount),
+		}
+	}
+
+	return result
+}
+
+// OrderBook returns the top levels of the recorded order book.
+func (b *Backtest) OrderBook(symbol string, depth int) (*OrderBook, error) {
+	name, err := b.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m, ok := b.markets[name]
+	if !ok {
+		return nil, fmt.Errorf("no data recorded for %v", symbol)
+	}
+
+	return &OrderBook{
+		Symbol: symbol,
+		Bids:   bookLevels(m.bids, depth),
+		Asks:   bookLevels(m.asks, depth),
+	}, nil
+}
+
+// fill executes the amount of the order at the price and updates the
+// balances, the funds are taken from the ones locked by the order.
+func (b *Backtest) fill(o *backtestOrder, price, amount *big.Rat,
+	taker bool) {
+
+	feeRate := b.makerFee
+	if taker {
+		feeRate = b.takerFee
+	}
+
+	m := o.market
+	total := new(big.Rat).Mul(price, amount)
+
+	var fee *big.Rat
+	switch o.order.Side {
+	case Buy:
+		// Limit orders lock the funds at the order price, the difference
+		// with the execution price is returned.
+		reserved := total
+		if o.order.Type == Limit {
+			reserved = new(big.Rat).Mul(o.price, amount)
+		}
+		balance(b.locked, m.money).Sub(balance(b.locked, m.money), reserved)
+		balance(b.free, m.money).Add(balance(b.free, m.money),
+			new(big.Rat).Sub(reserved, total))
+		o.spent.Add(o.spent, total)
+
+		fee = new(big.Rat).Mul(amount, feeRate)
+		balance(b.free, m.stock).Add(balance(b.free, m.stock),
+			new(big.Rat).Sub(amount, fee))
+
+	case Sell:
+		balance(b.locked, m.stock).Sub(balance(b.locked, m.stock), amount)
+
+		fee = new(big.Rat).Mul(total, feeRate)
+		balance(b.free, m.money).Add(balance(b.free, m.money),
+			new(big.Rat).Sub(total, fee))
+	}
+
+	o.filled.Add(o.filled, amount)
+	o.order.Filled = formatRat(o.filled)
+
+	b.fills = append(b.fills, Fill{
+		ID:      strconv.Itoa(len(b.fills) + 1),
+		OrderID: o.order.ID,
+		Symbol:  o.order.Symbol,
+		Side:    o.order.Side,
+		Price:   formatRat(price),
+		Amount:  formatRat(amount),
+		Fee:     formatRat(fee),
+		Taker:   taker,
+		Time:    b.now,
+	})
+}
+
+// locked returns the asset and the amount of funds locked by the order
+// which aren't used yet.
+func (o *backtestOrder) locked() (string, *big.Rat) {
+	if o.order.Side == Sell {
+		return o.market.stock, o.left()
+	}
+
+	if o.order.Type == Limit {
+		return o.market.money, new(big.Rat).Mul(o.left(), o.price)
+	}
+
+	return o.market.money, new(big.Rat).Sub(o.amount, o.spent)
+}
+
+// release unlocks the funds of the order which aren't used.
+func (b *Backtest) release(o *backtestOrder) {
+	asset, amount := o.locked()
+	balance(b.locked, asset).Sub(balance(b.locked, asset), amount)
+	balance(b.free, asset).Add(balance(b.free, asset), amount)
+}
+
+// crosses returns true if the order would be executed at the price.
+func (o *backtestOrder) crosses(price *big.Rat) bool {
+	if o.order.Side == Buy {
+		return price.Cmp(o.price) <= 0
+	}
+
+	return price.Cmp(o.price) >= 0
+}
+
+// advance reduces the volume ahead of the order by the traded volume, and
+// returns the rest of the volume which reaches the order.
+func (o *backtestOrder) advance(volume *big.Rat) *big.Rat {
+	if o.ahead.Cmp(volume) >= 0 {
+		o.ahead = new(big.Rat).Sub(o.ahead, volume)
+		return new(big.Rat)
+	}
+
+	rest := new(big.Rat).Sub(volume, o.ahead)
+	o.ahead = new(big.Rat)
+	return rest
+}
+
+// slipped returns the price of the taker execution deteriorated by the
+// slippage of the order.
+func (o *backtestOrder) slipped(price *big.Rat) *big.Rat {
+	if o.slippage.Sign() == 0 {
+		return price
+	}
+
+	ratio := new(big.Rat).Add(big.NewRat(1, 1), o.slippage)
+	if o.order.Side == Sell {
+		ratio.Sub(big.NewRat(1, 1), o.slippage)
+		if ratio.Sign() < 0 {
+			ratio.SetInt64(0)
+		}
+	}
+
+	slipped := new(big.Rat).Mul(price, ratio)
+
+	// Limit orders aren't executed at the price worse than the limit.
+	if o.order.Type == Limit && !o.crosses(slipped) {
+		return o.price
+	}
+
+	return slipped
+}
+
+// take matches the order against the opposite side of the book, taken
+// liquidity is removed from the book. Taker orders are executed at the
+// level price, resting ones at the order price after the volume ahead of
+// them.
+func (b *Backtest) take(o *backtestOrder, taker bool) {
+	levels := &o.market.asks
+	if o.order.Side == Sell {
+		levels = &o.market.bids
+	}
+
+	for len(*levels) != 0 {
+		left := o.left()
+		if left.Sign() <= 0 {
+			return
+		}
+
+		level := (*levels)[0]
+		if o.order.Type == Limit && !o.crosses(level.price) {
+			return
+		}
+
+		price := o.slipped(level.price)
+		if !taker {
+			price = o.price
+			level.amount = o.advance(level.amount)
+		}
+
+		amount := left
+		if level.amount.Cmp(amount) < 0 {
+			amount = level.amount
+		}
+
+		if amount.Sign() > 0 {
+			b.fill(o, price, amount, taker)
+		}
+
+		level.amount = new(big.Rat).Sub(level.amount, amount)
+		if level.amount.Sign() <= 0 {
+			*levels = (*levels)[1:]
+		}
+	}
+}
+
+// takeBuyMarket executes the market buy order, which amount is expressed
+// in quote asset, against the asks level by level.
+func (b *Backtest) takeBuyMarket(o *backtestOrder) {
+	m := o.market
+
+	for len(m.asks) != 0 && o.spent.Cmp(o.amount) < 0 {
+		level := m.asks[0]
+		remaining := new(big.Rat).Sub(o.amount, o.spent)
+		price := o.slipped(level.price)
+
+		stock := level.amount
+		if new(big.Rat).Mul(price, stock).Cmp(remaining) > 0 {
+			stock = new(big.Rat).Quo(remaining, price)
+		}
+
+		b.fill(o, price, stock, true)
+
+		level.amount = new(big.Rat).Sub(level.amount, stock)
+		if level.amount.Sign() <= 0 {
+			m.asks = m.asks[1:]
+		}
+	}
+}
+
+// execute matches the order which has reached the matching engine against
+// the book. The rest of the limit order is kept in the book, the rest of
+// the market order is canceled as it is done by the engine.
+func (b *Backtest) execute(o *backtestOrder) {
+	o.arrived = b.now
+
+	if b.cfg.Execution != nil {
+		// Size of the market buy order is estimated with the best ask.
+		size := o.amount
+		if o.order.Side == Buy && o.order.Type == Market {
+			size = new(big.Rat)
+			if len(o.market.asks) != 0 && o.market.asks[0].price.Sign() > 0 {
+				size.Quo(o.amount, o.market.asks[0].price)
+			}
+		}
+
+		o.slippage = b.cfg.Execution.Slippage(size)
+	}
+
+	if o.order.Type == Market {
+		if o.order.Side == Buy {
+			b.takeBuyMarket(o)
+		} else {
+			b.take(o, true)
+		}
+
+		b.release(o)
+		return
+	}
+
+	b.take(o, true)
+	if o.left().Sign() <= 0 {
+		return
+	}
+
+	// Order which joins the existing level is queued after some of its
+	// volume.
+	own := o.market.bids
+	if o.order.Side == Sell {
+		own = o.market.asks
+	}
+	for _, level := range own {
+		if level.price.Cmp(o.price) == 0 && b.cfg.Execution != nil {
+			o.ahead = new(big.Rat).Mul(level.amount,
+				b.cfg.Execution.QueueAhead())
+		}
+	}
+
+	b.orders = append(b.orders, o)
+}
+
+// activate executes the pending orders which have reached the matching
+// engine by the current time.
+func (b *Backtest) activate() {
+	pending := b.pending[:0]
+	for _, o := range b.pending {
+		if o.arrives.After(b.now) {
+			pending = append(pending, o)
+			continue
+		}
+
+		b.execute(o)
+	}
+
+	b.pending = pending
+}
+
+// PlaceOrder places the order, the funds needed for the order are locked
+// immediately. Once the order reaches the matching engine, limit orders
+// which cross the recorded book are executed against it and the rest is
+// kept until the recorded market data fills it, market orders are executed
+// against the book.
+func (b *Backtest) PlaceOrder(req *OrderRequest) (*Order, error) {
+	if req.Side != Buy && req.Side != Sell {
+		return nil, fmt.Errorf("unknown order side: %q", req.Side)
+	}
+
+	if req.Type != Limit && req.Type != Market {
+		return nil, fmt.Errorf("unknown order type: %q", req.Type)
+	}
+
+	name, err := b.market(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := parseRat(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount should be positive: %v", req.Amount)
+	}
+
+	var price *big.Rat
+	if req.Type == Limit {
+		if price, err = parseRat(req.Price); err != nil {
+			return nil, err
+		}
+		if price.Sign() <= 0 {
+			return nil, fmt.Errorf("price should be positive: %v",
+				req.Price)
+		}
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m, err := b.marketState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &backtestOrder{
+		order: Order{
+			Symbol: req.Symbol,
+			Side:   req.Side,
+			Type:   req.Type,
+			Price:  req.Price,
+			Amount: req.Amount,
+		},
+		market:   m,
+		arrives:  b.now,
+		price:    price,
+		amount:   amount,
+		filled:   new(big.Rat),
+		spent:    new(big.Rat),
+		ahead:    new(big.Rat),
+		slippage: new(big.Rat),
+	}
+	o.order.Filled = formatRat(o.filled)
+
+	asset, required := o.locked()
+	free := balance(b.free, asset)
+	if free.Cmp(required) < 0 {
+		return nil, fmt.Errorf("insufficient balance of %v: %v < %v",
+			asset, formatRat(free), formatRat(required))
+	}
+
+	free.Sub(free, required)
+	balance(b.locked, asset).Add(balance(b.locked, asset), required)
+
+	b.nextID++
+	o.order.ID = strconv.FormatInt(b.nextID, 10)
+
+	if b.cfg.Execution != nil {
+		o.arrives = b.now.Add(b.cfg.Execution.Latency())
+	}
+
+	if o.arrives.After(b.now) {
+		b.pending = append(b.pending, o)
+	} else {
+		b.execute(o)
+
+		if req.Type == Market && o.filled.Sign() == 0 {
+			return nil, fmt.Errorf("no liquidity recorded for %v",
+				req.Symbol)
+		}
+	}
+
+	order := o.order
+	return &order, nil
+}
+
+// CancelOrder cancels the order and unlocks its funds.
+func (b *Backtest) CancelOrder(symbol, id string) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for _, orders := range []*[]*backtestOrder{&b.orders, &b.pending} {
+		for i, o := range *orders {
+			if o.order.ID != id || o.order.Symbol != symbol {
+				continue
+			}
+
+			b.release(o)
+			*orders = append((*orders)[:i], (*orders)[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("order %v of %v isn't found", id, symbol)
+}
+
+// Balances returns the simulated funds of the account in all assets.
+func (b *Backtest) Balances() ([]Balance, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	assets := make(map[string]struct{})
+	for asset := range b.free {
+		assets[asset] = struct{}{}
+	}
+	for asset := range b.locked {
+		assets[asset] = struct{}{}
+	}
+
+	balances := make([]Balance, 0, len(assets))
+	for asset := range assets {
+		balances = append(balances, Balance{
+			Asset:  asset,
+			Free:   formatRat(balance(b.free, asset)),
+			Locked: formatRat(balance(b.locked, asset)),
+		})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Asset < balances[j].Asset
+	})
+
+	return balances, nil
+}
+
+// OpenOrders returns the resting orders and the ones which haven't
+// reached the matching engine yet.
+func (b *Backtest) OpenOrders() []Order {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	orders := make([]Order, 0, len(b.orders)+len(b.pending))
+	for _, o := range b.orders {
+		orders = append(orders, o.order)
+	}
+	for _, o := range b.pending {
+		orders = append(orders, o.order)
+	}
+
+	return orders
+}
+
+// Fills returns the simulated executions in the order they happened.
+func (b *Backtest) Fills() []Fill {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return append([]Fill(nil), b.fills...)
+}
+
+// RecentFills returns the simulated executions on the symbol.
+func (b *Backtest) RecentFills(symbol string) ([]Fill, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	var fills []Fill
+	for _, fill := range b.fills {
+		if fill.Symbol == symbol {
+			fills = append(fills, fill)
+		}
+	}
+
+	return fills, nil
+}
+
+// removeFilled removes the completely filled orders from the resting ones.
+func (b *Backtest) removeFilled() {
+	orders := b.orders[:0]
+	for _, o := range b.orders {
+		if o.left().Sign() > 0 {
+			orders = append(orders, o)
+		}
+	}
+
+	b.orders = orders
+}
+
+func parseLevels(depth []viabtc.Depth) ([]*bookLevel, error) {
+	levels := make([]*bookLevel, 0, len(depth))
+	for _, d := range depth {
+		price, err := parseRat(d.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := parseRat(d.Volume)
+		if err != nil {
+			return nil, err
+		}
+
+		levels = append(levels, &bookLevel{price: price, amount: amount})
+	}
+
+	return levels, nil
+}
+
+// Feed applies the recorded market event. Resting orders are filled at
+// their price by the recorded depth which crosses them, by the deals
+// executed at or through their price and by the klines which reached
+// their price after the order arrived. If queue position is modeled, the
+// volume at the order price is traded first by the orders ahead of it.
+func (b *Backtest) Feed(event *viabtc.WatchEvent) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if event.Time.After(b.now) {
+		b.now = event.Time
+	}
+
+	// Orders which reached the matching engine before the event are
+	// executed against the book preceding it.
+	b.activate()
+
+	m, err := b.marketState(event.Market)
+	if err != nil {
+		return err
+	}
+
+	switch data := event.Data.(type) {
+	case string:
+		m.last = data
+
+	case *viabtc.OrderDepthResponse:
+		bids, err := parseLevels(data.Bids)
+		if err != nil {
+			return err
+		}
+
+		asks, err := parseLevels(data.Asks)
+		if err != nil {
+			return err
+		}
+
+		m.bids, m.asks = bids, asks
+		for _, o := range b.orders {
+			if o.market == m {
+				b.take(o, false)
+			}
+		}
+
+	case viabtc.MarketDealsResponse:
+		// Deals are recorded from newest to oldest.
+		for i := len(data) - 1; i >= 0; i-- {
+			price, err := parseRat(data[i].Price)
+			if err != nil {
+				return err
+			}
+
+			amount, err := parseRat(data[i].Amount)
+			if err != nil {
+				return err
+			}
+
+			m.last = data[i].Price
+
+			for _, o := range b.orders {
+				if o.market != m || amount.Sign() <= 0 ||
+					o.left().Sign() <= 0 || !o.crosses(price) {
+					continue
+				}
+
+				// Deal through the order price means that the level is
+				// traded out, otherwise the orders ahead are filled
+				// first.
+				if price.Cmp(o.price) == 0 {
+					amount = o.advance(amount)
+					if amount.Sign() <= 0 {
+						continue
+					}
+				} else {
+					o.ahead = new(big.Rat)
+				}
+
+				executed := o.left()
+				if amount.Cmp(executed) < 0 {
+					executed = amount
+				}
+
+				b.fill(o, o.price, executed, false)
+				amount = new(big.Rat).Sub(amount, executed)
+			}
+		}
+
+	case viabtc.MarketKLineResponse:
+		for _, kline := range data {
+			low, err := parseRat(kline.LowestPrice)
+			if err != nil {
+				return err
+			}
+
+			high, err := parseRat(kline.HighestPrice)
+			if err != nil {
+				return err
+			}
+
+			m.last = kline.ClosePrice
+
+			for _, o := range b.orders {
+				// Klines which started before the order arrived might
+				// contain the prices it couldn't be executed at.
+				if o.market != m || o.left().Sign() <= 0 ||
+					kline.Time < float64(o.arrived.Unix()) {
+					continue
+				}
+
+				extreme := low
+				if o.order.Side == Sell {
+					extreme = high
+				}
+
+				// Kline which only touched the order price doesn't tell
+				// whether the volume ahead of the order was traded.
+				if !o.crosses(extreme) ||
+					(extreme.Cmp(o.price) == 0 && o.ahead.Sign() > 0) {
+					continue
+				}
+
+				o.ahead = new(big.Rat)
+				b.fill(o, o.price, o.left(), false)
+			}
+		}
+
+	case *viabtc.MarketStatusResponse:
+		m.volume = data.Volume
+		if data.Last != "" {
+			m.last = data.Last
+		}
+
+	default:
+		return fmt.Errorf("unsupported event data %T", event.Data)
+	}
+
+	b.removeFilled()
+	return nil
+}
+
+// Run feeds the recorded events to the backtest one by one, step is called
+// after every event, so that the strategy reacts on the recorded market
+// data as it would react on the live one.
+func (b *Backtest) Run(events *viabtc.WatchEventReader,
+	step func(event *viabtc.WatchEvent) error) error {
+
+	for events.Next() {
+		event := events.Event()
+		if err := b.Feed(event); err != nil {
+			return err
+		}
+
+		if step != nil {
+			if err := step(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return events.Err()
+}
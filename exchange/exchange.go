@@ -0,0 +1,150 @@
+// Package exchange defines the minimal exchange-agnostic interface, which
+// is used by applications supporting multiple trading venues, and its
+// implementation on top of the ViaBTC client.
+package exchange
+
+import "time"
+
+// Side is the side of the order.
+type Side string
+
+const (
+	// Buy is the side of the order which gives quote asset for the base.
+	Buy Side = "buy"
+
+	// Sell is the side of the order which gives base asset for the quote.
+	Sell Side = "sell"
+)
+
+// OrderType is the execution type of the order.
+type OrderType string
+
+const (
+	// Limit order is executed at the given or better price.
+	Limit OrderType = "limit"
+
+	// Market order is executed immediately at the best available prices.
+	Market OrderType = "market"
+)
+
+// Ticker is the summary of the market state.
+type Ticker struct {
+	Symbol string
+
+	// Last is the price of the last trade.
+	Last string
+
+	// Bid is the best price for which someone is willing to buy.
+	Bid string
+
+	// Ask is the best price for which someone is willing to sell.
+	Ask string
+
+	// Volume is the traded volume of base asset within the current day.
+	Volume string
+}
+
+// Level is the aggregated amount available at the price.
+type Level struct {
+	Price  string
+	Amount string
+}
+
+// OrderBook is the snapshot of the market orders.
+type OrderBook struct {
+	Symbol string
+	Bids   []Level
+	Asks   []Level
+}
+
+// OrderRequest describes the order which should be placed.
+type OrderRequest struct {
+	Symbol string
+	Side   Side
+	Type   OrderType
+
+	// Price is ignored for market orders.
+	Price string
+
+	// Amount is expressed in base asset for limit and sell market orders,
+	// and in quote asset for buy market orders.
+	Amount string
+}
+
+// Order is the state of the placed order.
+type Order struct {
+	ID     string
+	Symbol string
+	Side   Side
+	Type   OrderType
+	Price  string
+	Amount string
+
+	// Filled is the amount of base asset which has been executed.
+	Filled string
+}
+
+// Balance is the funds of the account in the single asset.
+type Balance struct {
+	Asset string
+
+	// Free is the funds which might be used in trading.
+	Free string
+
+	// Locked is the funds which are occupied by the orders.
+	Locked string
+}
+
+// Fill is the execution of the account order.
+type Fill struct {
+	// ID is the unique identifier of the execution.
+	ID string
+
+	// OrderID is the executed order, it is empty if the venue doesn't
+	// report it.
+	OrderID string
+	Symbol  string
+	Side    Side
+	Price   string
+
+	// Amount is the executed amount of base asset.
+	Amount string
+
+	// Fee is the fee taken from the received asset.
+	Fee string
+
+	// Taker is true if the order took the liquidity from the book.
+	Taker bool
+
+	Time time.Time
+}
+
+// Exchange is the minimal set of operations supported by every trading
+// venue.
+type Exchange interface {
+	// Name returns the name of the venue.
+	Name() string
+
+	// Ticker returns the summary of the market state.
+	Ticker(symbol string) (*Ticker, error)
+
+	// OrderBook returns the top levels of the market order book.
+	OrderBook(symbol string, depth int) (*OrderBook, error)
+
+	// PlaceOrder places the order on the market.
+	PlaceOrder(req *OrderRequest) (*Order, error)
+
+	// CancelOrder cancels the order placed on the market.
+	CancelOrder(symbol, id string) error
+
+	// Balances returns the funds of the account in all assets.
+	Balances() ([]Balance, error)
+}
+
+// FillSource is implemented by the venues which report the executions of
+// the account orders.
+type FillSource interface {
+	// RecentFills returns the recent executions on the symbol, from oldest
+	// to newest.
+	RecentFills(symbol string) ([]Fill, error)
+}
@@ -0,0 +1,142 @@
+package exchange
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ExecutionModel describes how the simulated orders are executed by the
+// matching engine, it is used by the backtest and the paper trading to
+// bring the simulated results closer to the ones of the real engine.
+type ExecutionModel interface {
+	// Latency returns the delay after which the placed order reaches the
+	// matching engine, the order isn't executed until then.
+	Latency() time.Duration
+
+	// Slippage returns the relative deterioration of the price of the
+	// taker execution of the given amount of base asset, e.g. 0.001 makes
+	// buyer pay 0.1% more and seller receive 0.1% less.
+	Slippage(amount *big.Rat) *big.Rat
+
+	// QueueAhead returns the part of the level volume, from 0 to 1, which
+	// is ahead of the order joining the existing price level. Such order
+	// is executed only after the volume ahead of it is traded.
+	QueueAhead() *big.Rat
+}
+
+// ExecutionConfig is an structure which holds configurable parameters of
+// the simple execution model.
+type ExecutionConfig struct {
+	// Latency is the fixed delay of the order placement.
+	Latency time.Duration
+
+	// Jitter is the maximum random delay which is added to the latency.
+	Jitter time.Duration
+
+	// SlippageRate is the relative slippage per unit of base asset, so
+	// that the slippage grows linearly with the size of the order.
+	SlippageRate string
+
+	// MaxSlippage limits the relative slippage, unlimited if not
+	// specified.
+	MaxSlippage string
+
+	// QueueAhead is the part of the level volume, from 0 to 1, which is
+	// ahead of the order joining the level.
+	QueueAhead string
+
+	// Seed is the seed of the random latency, so that the simulation is
+	// reproducible.
+	Seed int64
+}
+
+// SimpleExecutionModel is the execution model with the fixed or random
+// latency, the slippage which is proportional to the size of the order and
+// the fixed queue position.
+type SimpleExecutionModel struct {
+	cfg ExecutionConfig
+
+	slippageRate *big.Rat
+	maxSlippage  *big.Rat
+	queueAhead   *big.Rat
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+// A compile time check to ensure SimpleExecutionModel implements the
+// ExecutionModel interface.
+var _ ExecutionModel = (*SimpleExecutionModel)(nil)
+
+// NewSimpleExecutionModel creates new execution model.
+func NewSimpleExecutionModel(cfg *ExecutionConfig) (*SimpleExecutionModel,
+	error) {
+
+	if cfg.Latency < 0 || cfg.Jitter < 0 {
+		return nil, errors.New("latency and jitter shouldn't be negative")
+	}
+
+	m := &SimpleExecutionModel{
+		cfg:          *cfg,
+		slippageRate: new(big.Rat),
+		queueAhead:   new(big.Rat),
+		rand:         rand.New(rand.NewSource(cfg.Seed)),
+	}
+
+	var err error
+	if cfg.SlippageRate != "" {
+		if m.slippageRate, err = parseRat(cfg.SlippageRate); err != nil {
+			return nil, err
+		}
+		if m.slippageRate.Sign() < 0 {
+			return nil, errors.New("slippage rate shouldn't be negative")
+		}
+	}
+
+	if cfg.MaxSlippage != "" {
+		if m.maxSlippage, err = parseRat(cfg.MaxSlippage); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.QueueAhead != "" {
+		if m.queueAhead, err = parseRat(cfg.QueueAhead); err != nil {
+			return nil, err
+		}
+		if m.queueAhead.Sign() < 0 || m.queueAhead.Cmp(big.NewRat(1, 1)) > 0 {
+			return nil, errors.New("queue ahead should be from 0 to 1")
+		}
+	}
+
+	return m, nil
+}
+
+// Latency returns the fixed latency with the random jitter.
+func (m *SimpleExecutionModel) Latency() time.Duration {
+	if m.cfg.Jitter <= 0 {
+		return m.cfg.Latency
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.cfg.Latency + time.Duration(m.rand.Int63n(int64(m.cfg.Jitter)))
+}
+
+// Slippage returns the slippage proportional to the amount.
+func (m *SimpleExecutionModel) Slippage(amount *big.Rat) *big.Rat {
+	slippage := new(big.Rat).Mul(m.slippageRate, amount)
+	if m.maxSlippage != nil && slippage.Cmp(m.maxSlippage) > 0 {
+		slippage.Set(m.maxSlippage)
+	}
+
+	return slippage
+}
+
+// QueueAhead returns the fixed queue position.
+func (m *SimpleExecutionModel) QueueAhead() *big.Rat {
+	return new(big.Rat).Set(m.queueAhead)
+}
@@ -0,0 +1,294 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+const (
+	// defaultPaperInterval is the polling interval of the live market data
+	// if not specified.
+	defaultPaperInterval = time.Second
+
+	// defaultPaperDepth is the number of the polled depth levels if not
+	// specified.
+	defaultPaperDepth = 50
+
+	// paperDealsLimit is the maximum number of deals fetched per poll.
+	paperDealsLimit = 100
+
+	// paperStatusPeriod is the period of the market status which volume is
+	// reported by the ticker, in seconds.
+	paperStatusPeriod = 24 * 60 * 60
+)
+
+// PaperConfig is an structure which holds configurable parameters of the
+// paper trading.
+type PaperConfig struct {
+	// Balances is the initial virtual funds of the account by asset.
+	Balances map[string]string
+
+	// TakerFeeRate and MakerFeeRate are the fee coefficients from [0;1)
+	// applied to the simulated fills, zero if not specified.
+	TakerFeeRate string
+	MakerFeeRate string
+
+	// Symbols, if specified, makes paper trading to use unified
+	// "BASE/QUOTE" symbols instead of the engine market names.
+	Symbols *viabtc.SymbolMap
+
+	// Markets is the symbols which live market data is polled.
+	Markets []string
+
+	// Interval is the polling interval of the live market data.
+	Interval time.Duration
+
+	// DepthLimit is the number of the polled depth levels.
+	DepthLimit int
+
+	// Execution, if specified, models the latency, the slippage and the
+	// queue position of the paper orders.
+	Execution ExecutionModel
+}
+
+// Paper implements the exchange interface by simulating orders against the
+// live market data. Depth, last price and deals of the markets are polled
+// from the engine, orders are never sent to it. Balances are the virtual
+// ledger which is updated by the simulated fills, order book and ticker
+// reflect the live book without the liquidity taken by the paper orders.
+// Orders are filled in the same way as during the backtest.
+type Paper struct {
+	*Backtest
+
+	client *viabtc.Client
+	cfg    PaperConfig
+
+	// syncMtx serializes polling of the market data.
+	syncMtx     sync.Mutex
+	lastDealIDs map[string]int32
+
+	mtx     sync.Mutex
+	started bool
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure Paper implements the Exchange interface.
+var _ Exchange = (*Paper)(nil)
+
+// A compile time check to ensure Paper implements the FillSource
+// interface.
+var _ FillSource = (*Paper)(nil)
+
+// NewPaper creates new paper trading with the initial virtual balances.
+func NewPaper(client *viabtc.Client, cfg *PaperConfig) (*Paper, error) {
+	if len(cfg.Markets) == 0 {
+		return nil, errors.New("markets should be specified")
+	}
+
+	c := *cfg
+	if c.Interval <= 0 {
+		c.Interval = defaultPaperInterval
+	}
+	if c.DepthLimit <= 0 {
+		c.DepthLimit = defaultPaperDepth
+	}
+
+	b, err := NewBacktest(&BacktestConfig{
+		Balances:     c.Balances,
+		TakerFeeRate: c.TakerFeeRate,
+		MakerFeeRate: c.MakerFeeRate,
+		Symbols:      c.Symbols,
+		Execution:    c.Execution,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, symbol := range c.Markets {
+		if _, err := b.market(symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Paper{
+		Backtest:    b,
+		client:      client,
+		cfg:         c,
+		lastDealIDs: make(map[string]int32),
+		quit:        make(chan struct{}),
+	}, nil
+}
+
+// Name returns the name of the venue.
+func (p *Paper) Name() string {
+	return "paper"
+}
+
+// Sync polls the live market data once and fills the paper orders which
+// are crossed by it.
+func (p *Paper) Sync() error {
+	p.syncMtx.Lock()
+	defer p.syncMtx.Unlock()
+
+	for _, symbol := range p.cfg.Markets {
+		market, err := p.market(symbol)
+		if err != nil {
+			return err
+		}
+
+		if err := p.syncMarket(market); err != nil {
+			return fmt.Errorf("unable to sync %v: %w", symbol, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Paper) syncMarket(market string) error {
+	lastID, ok := p.lastDealIDs[market]
+
+	deals, err := p.client.MarketDeals(&viabtc.MarketDealsRequest{
+		Market: market,
+		Limit:  paperDealsLimit,
+		LastID: lastID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(deals) != 0 {
+		// Deals are returned from newest to oldest.
+		p.lastDealIDs[market] = deals[0].DealID
+
+		// Deals which happened before the first poll are skipped.
+		if ok {
+			if err := p.Feed(&viabtc.WatchEvent{
+				Market: market,
+				Type:   viabtc.WatchDeals,
+				Time:   p.client.Now(),
+				Data:   deals,
+			}); err != nil {
+				return err
+			}
+		}
+	} else if !ok {
+		p.lastDealIDs[market] = 0
+	}
+
+	last, err := p.client.MarketLast(&viabtc.MarketLastRequest{
+		Market: market,
+	})
+	if err != nil {
+		return err
+	}
+
+	if last != nil {
+		if err := p.Feed(&viabtc.WatchEvent{
+			Market: market,
+			Type:   viabtc.WatchLast,
+			Time:   p.client.Now(),
+			Data:   *last,
+		}); err != nil {
+			return err
+		}
+	}
+
+	status, err := p.client.MarketStatus(&viabtc.MarketStatusRequest{
+		Market: market,
+		Period: paperStatusPeriod,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := p.Feed(&viabtc.WatchEvent{
+		Market: market,
+		Type:   viabtc.WatchStatus,
+		Time:   p.client.Now(),
+		Data:   status,
+	}); err != nil {
+		return err
+	}
+
+	depth, err := p.client.OrderDepth(&viabtc.OrderDepthRequest{
+		Market:   market,
+		Limit:    int32(p.cfg.DepthLimit),
+		Interval: viabtc.DefaultDepthInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.Feed(&viabtc.WatchEvent{
+		Market: market,
+		Type:   viabtc.WatchDepth,
+		Time:   p.client.Now(),
+		Data:   depth,
+	})
+}
+
+// Start starts polling the live market data.
+func (p *Paper) Start() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.started {
+		return errors.New("paper trading is already started")
+	}
+	p.started = true
+
+	p.wg.Add(1)
+	go p.run()
+
+	return nil
+}
+
+// Stop stops polling the live market data.
+func (p *Paper) Stop() {
+	p.mtx.Lock()
+	if !p.started {
+		p.mtx.Unlock()
+		return
+	}
+	p.started = false
+	close(p.quit)
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+}
+
+// Err returns the last error of polling.
+func (p *Paper) Err() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.lastErr
+}
+
+func (p *Paper) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Sync(); err != nil {
+			p.mtx.Lock()
+			p.lastErr = err
+			p.mtx.Unlock()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+const (
+	// defaultRunnerInterval is the polling and timer interval of the
+	// runner if not specified.
+	defaultRunnerInterval = time.Second
+
+	// defaultRunnerDepth is the number of the order book levels delivered
+	// to the strategy if not specified.
+	defaultRunnerDepth = 10
+)
+
+// Strategy is the trading logic hosted by the runner. Callbacks are called
+// sequentially, the venue the strategy runs against is passed to them, so
+// that the same strategy might be run against the live engine, the paper
+// trading or the backtest.
+type Strategy interface {
+	// OnTick is called when the ticker of the symbol has changed.
+	OnTick(ex Exchange, ticker *Ticker) error
+
+	// OnDepth is called when the order book of the symbol has changed.
+	OnDepth(ex Exchange, book *OrderBook) error
+
+	// OnFill is called when the order of the account has been executed.
+	OnFill(ex Exchange, fill *Fill) error
+
+	// OnTimer is called periodically with the current time of the venue,
+	// which is the recorded time during the backtest.
+	OnTimer(ex Exchange, now time.Time) error
+}
+
+// RunnerConfig is an structure which holds configurable parameters of the
+// strategy runner.
+type RunnerConfig struct {
+	// Symbols is the markets which data is delivered to the strategy.
+	Symbols []string
+
+	// Interval is the polling interval of the market data and the
+	// interval of the timer callbacks.
+	Interval time.Duration
+
+	// DepthLimit is the number of the order book levels delivered to the
+	// strategy.
+	DepthLimit int
+}
+
+// Runner executes the strategy against the venue. Market data and fills
+// are polled and only changes are delivered to the strategy. Fills are
+// delivered only if the venue implements FillSource, the fills which
+// happened before the runner was started are skipped.
+type Runner struct {
+	ex       Exchange
+	strategy Strategy
+	cfg      RunnerConfig
+
+	tickers   map[string]*Ticker
+	books     map[string]*OrderBook
+	seen      map[string]map[string]struct{}
+	lastTimer time.Time
+
+	// stepMtx serializes delivery to the strategy.
+	stepMtx sync.Mutex
+
+	mtx     sync.Mutex
+	started bool
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRunner creates new strategy runner.
+func NewRunner(ex Exchange, strategy Strategy, cfg *RunnerConfig) (*Runner,
+	error) {
+
+	if len(cfg.Symbols) == 0 {
+		return nil, errors.New("symbols should be specified")
+	}
+
+	c := *cfg
+	if c.Interval <= 0 {
+		c.Interval = defaultRunnerInterval
+	}
+	if c.DepthLimit <= 0 {
+		c.DepthLimit = defaultRunnerDepth
+	}
+
+	return &Runner{
+		ex:       ex,
+		strategy: strategy,
+		cfg:      c,
+		tickers:  make(map[string]*Ticker),
+		books:    make(map[string]*OrderBook),
+		seen:     make(map[string]map[string]struct{}),
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// prime marks the existing fills as seen, so that they aren't delivered.
+func (r *Runner) prime() error {
+	source, ok := r.ex.(FillSource)
+	if !ok {
+		return nil
+	}
+
+	for _, symbol := range r.cfg.Symbols {
+		fills, err := source.RecentFills(symbol)
+		if err != nil {
+			return fmt.Errorf("unable to fetch fills of %v: %v", symbol,
+				err)
+		}
+
+		seen := make(map[string]struct{}, len(fills))
+		for _, fill := range fills {
+			seen[fill.ID] = struct{}{}
+		}
+		r.seen[symbol] = seen
+	}
+
+	return nil
+}
+
+func (r *Runner) pollTicker(symbol string) error {
+	ticker, err := r.ex.Ticker(symbol)
+	if err != nil {
+		return fmt.Errorf("unable to fetch ticker of %v: %v", symbol, err)
+	}
+
+	if reflect.DeepEqual(ticker, r.tickers[symbol]) {
+		return nil
+	}
+	r.tickers[symbol] = ticker
+
+	return r.strategy.OnTick(r.ex, ticker)
+}
+
+func (r *Runner) pollBook(symbol string) error {
+	book, err := r.ex.OrderBook(symbol, r.cfg.DepthLimit)
+	if err != nil {
+		return fmt.Errorf("unable to fetch order book of %v: %v", symbol,
+			err)
+	}
+
+	if reflect.DeepEqual(book, r.books[symbol]) {
+		return nil
+	}
+	r.books[symbol] = book
+
+	return r.strategy.OnDepth(r.ex, book)
+}
+
+func (r *Runner) pollFills(symbol string) error {
+	source, ok := r.ex.(FillSource)
+	if !ok {
+		return nil
+	}
+
+	fills, err := source.RecentFills(symbol)
+	if err != nil {
+		return fmt.Errorf("unable to fetch fills of %v: %v", symbol, err)
+	}
+
+	seen, ok := r.seen[symbol]
+	if !ok {
+		seen = make(map[string]struct{})
+		r.seen[symbol] = seen
+	}
+
+	for i := range fills {
+		if _, ok := seen[fills[i].ID]; ok {
+			continue
+		}
+		seen[fills[i].ID] = struct{}{}
+
+		if err := r.strategy.OnFill(r.ex, &fills[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) timer(now time.Time) error {
+	if !r.lastTimer.IsZero() && now.Sub(r.lastTimer) < r.cfg.Interval {
+		return nil
+	}
+	r.lastTimer = now
+
+	return r.strategy.OnTimer(r.ex, now)
+}
+
+// Step polls the market data and the fills of all symbols and delivers
+// the changes to the strategy, the timer is fired if the interval has
+// passed since the previous one. It is used by the live runner and might
+// be called directly to drive the strategy manually.
+func (r *Runner) Step(now time.Time) error {
+	r.stepMtx.Lock()
+	defer r.stepMtx.Unlock()
+
+	for _, symbol := range r.cfg.Symbols {
+		if err := r.pollTicker(symbol); err != nil {
+			return err
+		}
+
+		if err := r.pollBook(symbol); err != nil {
+			return err
+		}
+
+		if err := r.pollFills(symbol); err != nil {
+			return err
+		}
+	}
+
+	return r.timer(now)
+}
+
+// Start starts executing the strategy against the live venue, e.g. the
+// engine or the paper trading. Errors of the strategy don't stop the
+// runner, the last one is returned by Err.
+func (r *Runner) Start() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.started {
+		return errors.New("runner is already started")
+	}
+
+	if err := r.prime(); err != nil {
+		return err
+	}
+	r.started = true
+
+	r.wg.Add(1)
+	go r.run()
+
+	return nil
+}
+
+// Stop stops executing the strategy.
+func (r *Runner) Stop() {
+	r.mtx.Lock()
+	if !r.started {
+		r.mtx.Unlock()
+		return
+	}
+	r.started = false
+	close(r.quit)
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+}
+
+// Err returns the last error of the polling or of the strategy.
+func (r *Runner) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.lastErr
+}
+
+func (r *Runner) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Step(time.Now()); err != nil {
+			r.mtx.Lock()
+			r.lastErr = err
+			r.mtx.Unlock()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// Backtest executes the strategy against the backtest, which must be the
+// venue of the runner, feeding it the recorded events. The market data of
+// the symbol is delivered after every its event, and the timer is fired
+// according to the recorded time. The first error of the strategy stops
+// the backtest and is returned.
+func (r *Runner) Backtest(events *viabtc.WatchEventReader) error {
+	b, ok := r.ex.(*Backtest)
+	if !ok {
+		return fmt.Errorf("venue %v isn't backtest", r.ex.Name())
+	}
+
+	symbols := make(map[string]string, len(r.cfg.Symbols))
+	for _, symbol := range r.cfg.Symbols {
+		market, err := b.market(symbol)
+		if err != nil {
+			return err
+		}
+		symbols[market] = symbol
+	}
+
+	if err := r.prime(); err != nil {
+		return err
+	}
+
+	return b.Run(events, func(event *viabtc.WatchEvent) error {
+		r.stepMtx.Lock()
+		defer r.stepMtx.Unlock()
+
+		if symbol, ok := symbols[event.Market]; ok {
+			if err := r.pollTicker(symbol); err != nil {
+				return err
+			}
+
+			if err := r.pollBook(symbol); err != nil {
+				return err
+			}
+		}
+
+		for _, symbol := range r.cfg.Symbols {
+			if err := r.pollFills(symbol); err != nil {
+				return err
+			}
+		}
+
+		return r.timer(b.Now())
+	})
+}
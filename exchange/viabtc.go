@@ -0,0 +1,321 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// ViaBTCConfig is an structure which holds configurable parameters of the
+// ViaBTC exchange adapter.
+type ViaBTCConfig struct {
+	// UserID is the user on behalf of whom orders are placed and balances
+	// are queried.
+	UserID uint32
+
+	// TakerFeeRate and MakerFeeRate are the fee coefficients from [0;1)
+	// applied to placed orders, zero if not specified.
+	TakerFeeRate string
+	MakerFeeRate string
+
+	// Source designate the origin of the orders.
+	Source string
+
+	// Symbols, if specified, makes adapter to use unified "BASE/QUOTE"
+	// symbols instead of the engine market names.
+	Symbols *viabtc.SymbolMap
+}
+
+// ViaBTC implements the exchange interface with ViaBTC client. Symbols are
+// the engine market names, e.g. "BTCETH", unless symbol mapping is
+// configured.
+type ViaBTC struct {
+	client *viabtc.Client
+	cfg    ViaBTCConfig
+}
+
+// A compile time check to ensure ViaBTC implements the Exchange interface.
+var _ Exchange = (*ViaBTC)(nil)
+
+// A compile time check to ensure ViaBTC implements the FillSource
+// interface.
+var _ FillSource = (*ViaBTC)(nil)
+
+// NewViaBTC creates new exchange adapter on top of ViaBTC client.
+func NewViaBTC(client *viabtc.Client, cfg *ViaBTCConfig) *ViaBTC {
+	c := *cfg
+	if c.TakerFeeRate == "" {
+		c.TakerFeeRate = "0"
+	}
+	if c.MakerFeeRate == "" {
+		c.MakerFeeRate = "0"
+	}
+
+	return &ViaBTC{
+		client: client,
+		cfg:    c,
+	}
+}
+
+// market returns the engine market name of the symbol.
+func (v *ViaBTC) market(symbol string) (string, error) {
+	if v.cfg.Symbols == nil {
+		return symbol, nil
+	}
+
+	return v.cfg.Symbols.Engine(symbol)
+}
+
+// Name returns the name of the venue.
+func (v *ViaBTC) Name() string {
+	return "viabtc"
+}
+
+// Ticker returns the summary of the market state.
+func (v *ViaBTC) Ticker(symbol string) (*Ticker, error) {
+	market, err := v.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := v.client.MarketStatusToday(&viabtc.MarketStatusTodayRequest{
+		Market: market,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := v.OrderBook(symbol, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := &Ticker{
+		Symbol: symbol,
+		Last:   status.Last,
+		Volume: status.Volume,
+	}
+
+	if len(book.Bids) != 0 {
+		ticker.Bid = book.Bids[0].Price
+	}
+
+	if len(book.Asks) != 0 {
+		ticker.Ask = book.Asks[0].Price
+	}
+
+	return ticker, nil
+}
+
+func levels(depth []viabtc.Depth) []Level {
+	result := make([]Level, len(depth))
+	for i, d := range depth {
+		result[i] = Level{
+			Price:  d.Price,
+			Amount: d.Volume,
+		}
+	}
+
+	return result
+}
+
+// OrderBook returns the top levels of the market order book.
+func (v *ViaBTC) OrderBook(symbol string, depth int) (*OrderBook, error) {
+	market, err := v.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.OrderDepth(&viabtc.OrderDepthRequest{
+		Market:   market,
+		Limit:    int32(depth),
+		Interval: "0",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderBook{
+		Symbol: symbol,
+		Bids:   levels(resp.Bids),
+		Asks:   levels(resp.Asks),
+	}, nil
+}
+
+func toSide(side Side) (viabtc.MarketOrderSide, error) {
+	switch side {
+	case Buy:
+		return viabtc.MarketOrderSideBid, nil
+	case Sell:
+		return viabtc.MarketOrderSideAsk, nil
+	default:
+		return 0, fmt.Errorf("unknown order side: %q", side)
+	}
+}
+
+func fromSide(side viabtc.MarketOrderSide) Side {
+	if side == viabtc.MarketOrderSideBid {
+		return Buy
+	}
+
+	return Sell
+}
+
+func toOrder(info *viabtc.OrderDetailedInfo, symbol string) *Order {
+	order := &Order{
+		ID:     strconv.FormatInt(int64(info.OrderID), 10),
+		Symbol: symbol,
+		Side:   fromSide(info.Side),
+		Price:  info.Price,
+		Amount: info.Amount,
+		Filled: info.DealStock,
+	}
+
+	switch info.Type {
+	case viabtc.MarketOrderType:
+		order.Type = Market
+	default:
+		order.Type = Limit
+	}
+
+	return order
+}
+
+// PlaceOrder places the order on the market.
+func (v *ViaBTC) PlaceOrder(req *OrderRequest) (*Order, error) {
+	side, err := toSide(req.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	market, err := v.market(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Type {
+	case Limit:
+		resp, err := v.client.OrderPutLimit(&viabtc.OrderPutLimitRequest{
+			UserID:       v.cfg.UserID,
+			Market:       market,
+			Side:         side,
+			Amount:       req.Amount,
+			Price:        req.Price,
+			TakerFeeRate: v.cfg.TakerFeeRate,
+			MakerFeeRate: v.cfg.MakerFeeRate,
+			Source:       v.cfg.Source,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return toOrder((*viabtc.OrderDetailedInfo)(resp), req.Symbol), nil
+
+	case Market:
+		resp, err := v.client.OrderPutMarket(&viabtc.OrderPutMarketRequest{
+			UserID:       v.cfg.UserID,
+			Market:       market,
+			Side:         side,
+			Amount:       req.Amount,
+			TakerFeeRate: v.cfg.TakerFeeRate,
+			Source:       v.cfg.Source,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return toOrder((*viabtc.OrderDetailedInfo)(resp), req.Symbol), nil
+
+	default:
+		return nil, fmt.Errorf("unknown order type: %q", req.Type)
+	}
+}
+
+// CancelOrder cancels the order placed on the market.
+func (v *ViaBTC) CancelOrder(symbol, id string) error {
+	orderID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return fmt.Errorf("wrong order id %q: %v", id, err)
+	}
+
+	market, err := v.market(symbol)
+	if err != nil {
+		return err
+	}
+
+	_, err = v.client.OrderCancel(&viabtc.OrderCancelRequest{
+		UserID:  v.cfg.UserID,
+		Market:  market,
+		OrderID: int32(orderID),
+	})
+	return err
+}
+
+// Balances returns the funds of the user in all assets.
+func (v *ViaBTC) Balances() ([]Balance, error) {
+	resp, err := v.client.BalanceQuery(&viabtc.BalanceQueryRequest{
+		UserID: v.cfg.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]Balance, 0, len(resp))
+	for asset, balance := range resp {
+		balances = append(balances, Balance{
+			Asset:  string(asset),
+			Free:   balance.Available,
+			Locked: balance.Freeze,
+		})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Asset < balances[j].Asset
+	})
+
+	return balances, nil
+}
+
+// recentFillsLimit is the number of the recent user deals fetched to
+// report the fills.
+const recentFillsLimit = 100
+
+// RecentFills returns the recent deals of the user on the market, from
+// oldest to newest. The engine doesn't report which of the user orders was
+// executed, so that order id of the fills is empty.
+func (v *ViaBTC) RecentFills(symbol string) ([]Fill, error) {
+	market, err := v.market(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.MarketUserDeals(&viabtc.MarketUserDealsRequest{
+		UserID: v.cfg.UserID,
+		Market: market,
+		Limit:  recentFillsLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Deals are returned from newest to oldest.
+	fills := make([]Fill, 0, len(resp.Deals))
+	for i := len(resp.Deals) - 1; i >= 0; i-- {
+		deal := resp.Deals[i]
+		fills = append(fills, Fill{
+			ID:     strconv.FormatInt(int64(deal.DealID), 10),
+			Symbol: symbol,
+			Side:   fromSide(deal.Side),
+			Price:  deal.Price,
+			Amount: deal.Amount,
+			Fee:    deal.Fee,
+			Taker:  deal.Role == viabtc.TakerRole,
+			Time:   time.Unix(0, int64(deal.Time*float64(time.Second))),
+		})
+	}
+
+	return fills, nil
+}
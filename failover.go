@@ -0,0 +1,420 @@
+package viabtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailoverThreshold is the number of consecutive failures after
+	// which the endpoint is considered to be dead.
+	DefaultFailoverThreshold = 3
+
+	// DefaultProbeInterval is the interval with which the preferred
+	// endpoints are probed while client is failed over.
+	DefaultProbeInterval = 5 * time.Second
+
+	// defaultProbeTimeout is the timeout of the probe if not specified.
+	defaultProbeTimeout = 2 * time.Second
+)
+
+// FailoverConfig is an structure which holds configurable parameters of the
+// failover between the matchengine hosts.
+type FailoverConfig struct {
+	// Endpoints is the list of the server addresses in "host:port" form in
+	// the order of preference, the first one is the primary. If endpoint
+	// discovery is configured, with SRV name or endpoint source, the
+	// discovered endpoints replace the list in the order returned by the
+	// discovery, and the list might be empty.
+	Endpoints []string
+
+	// Threshold is the number of consecutive transport failures or server
+	// errors after which the endpoint is considered to be dead and client
+	// fails over to the next one, DefaultFailoverThreshold if not
+	// specified.
+	Threshold int
+
+	// ProbeInterval is the interval with which the more preferred
+	// endpoints are probed while client is failed over,
+	// DefaultProbeInterval if not specified. Client returns to the first
+	// endpoint which passes the probe.
+	ProbeInterval time.Duration
+
+	// ProbeTimeout is the timeout of the single probe.
+	ProbeTimeout time.Duration
+
+	// OnFailover, if specified, is called when client switches from one
+	// endpoint to another.
+	OnFailover func(from, to string)
+}
+
+// failover keeps track of the health of the endpoints and selects the one
+// which the requests are sent to.
+type failover struct {
+	cfg FailoverConfig
+
+	mtx      sync.Mutex
+	active   int
+	failures int
+
+	// version is incremented when the endpoints are replaced by the
+	// discovery, so that the probe of the previous set isn't applied.
+	version int
+
+	// resolve, if set, discovers the endpoints, which replace the
+	// configured ones, every resolve interval.
+	resolve         func() ([]string, error)
+	resolveInterval time.Duration
+	lastErr         error
+
+	// probe checks whether the endpoint is able to serve requests.
+	probe func(ctx context.Context, endpoint string) error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newFailover(cfg *FailoverConfig,
+	probe func(ctx context.Context, endpoint string) error) *failover {
+
+	f := &failover{
+		cfg:   *cfg,
+		probe: probe,
+		quit:  make(chan struct{}),
+	}
+
+	if f.cfg.Threshold <= 0 {
+		f.cfg.Threshold = DefaultFailoverThreshold
+	}
+	if f.cfg.ProbeInterval <= 0 {
+		f.cfg.ProbeInterval = DefaultProbeInterval
+	}
+	if f.cfg.ProbeTimeout <= 0 {
+		f.cfg.ProbeTimeout = defaultProbeTimeout
+	}
+
+	return f
+}
+
+// current returns the endpoint which requests should be sent to, empty if
+// failover is disabled or no endpoints have been discovered yet.
+func (f *failover) current() string {
+	if f == nil {
+		return ""
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if len(f.cfg.Endpoints) == 0 {
+		return ""
+	}
+
+	return f.cfg.Endpoints[f.active]
+}
+
+// alternate returns the endpoint which follows the active one, empty if
+// failover is disabled or there is no other endpoint.
+func (f *failover) alternate() string {
+	if f == nil {
+		return ""
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if len(f.cfg.Endpoints) < 2 {
+		return ""
+	}
+
+	return f.cfg.Endpoints[(f.active+1)%len(f.cfg.Endpoints)]
+}
+
+// report records the outcome of the request sent to the endpoint, and
+// fails over to the next endpoint if the threshold is reached.
+func (f *failover) report(endpoint string, failed bool) {
+	if f == nil {
+		return
+	}
+
+	f.mtx.Lock()
+
+	// Outcome of the request which was sent before the switch shouldn't
+	// affect the new endpoint.
+	if len(f.cfg.Endpoints) == 0 || f.cfg.Endpoints[f.active] != endpoint {
+		f.mtx.Unlock()
+		return
+	}
+
+	if !failed {
+		f.failures = 0
+		f.mtx.Unlock()
+		return
+	}
+
+	f.failures++
+	if f.failures < f.cfg.Threshold || len(f.cfg.Endpoints) == 1 {
+		f.mtx.Unlock()
+		return
+	}
+
+	from := f.cfg.Endpoints[f.active]
+	f.active = (f.active + 1) % len(f.cfg.Endpoints)
+	f.failures = 0
+	to := f.cfg.Endpoints[f.active]
+	f.mtx.Unlock()
+
+	if f.cfg.OnFailover != nil {
+		f.cfg.OnFailover(from, to)
+	}
+}
+
+// restore probes the endpoints which are preferred over the active one, and
+// switches to the first healthy of them.
+func (f *failover) restore() {
+	f.mtx.Lock()
+	active := f.active
+	version := f.version
+	endpoints := f.cfg.Endpoints
+	f.mtx.Unlock()
+
+	for i := 0; i < active; i++ {
+		endpoint := endpoints[i]
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			f.cfg.ProbeTimeout)
+		err := f.probe(ctx, endpoint)
+		cancel()
+
+		if err != nil {
+			continue
+		}
+
+		f.mtx.Lock()
+		if f.active != active || f.version != version {
+			// Client has been switched or endpoints have been
+			// replaced while probing.
+			f.mtx.Unlock()
+			return
+		}
+		from := f.cfg.Endpoints[f.active]
+		f.active = i
+		f.failures = 0
+		f.mtx.Unlock()
+
+		if f.cfg.OnFailover != nil {
+			f.cfg.OnFailover(from, endpoint)
+		}
+		return
+	}
+}
+
+// setEndpoints replaces the endpoints with the discovered ones. The active
+// endpoint stays active if it is still present, otherwise client switches
+// to the first of the new endpoints.
+func (f *failover) setEndpoints(endpoints []string) {
+	f.mtx.Lock()
+
+	if reflect.DeepEqual(endpoints, f.cfg.Endpoints) {
+		f.mtx.Unlock()
+		return
+	}
+
+	var from string
+	if len(f.cfg.Endpoints) != 0 {
+		from = f.cfg.Endpoints[f.active]
+	}
+
+	f.cfg.Endpoints = append([]string(nil), endpoints...)
+	f.version++
+	f.active = 0
+	f.failures = 0
+	for i, endpoint := range endpoints {
+		if endpoint == from {
+			f.active = i
+			break
+		}
+	}
+
+	var to string
+	if len(endpoints) != 0 {
+		to = endpoints[f.active]
+	}
+	f.mtx.Unlock()
+
+	if from != to && from != "" && f.cfg.OnFailover != nil {
+		f.cfg.OnFailover(from, to)
+	}
+}
+
+// discover makes the failover to use the endpoints returned by the resolve
+// function, which is called now and then every interval, instead of the
+// configured ones. Endpoints are kept if the function fails.
+func (f *failover) discover(interval time.Duration,
+	resolve func() ([]string, error)) {
+
+	f.resolve = resolve
+	f.resolveInterval = interval
+	f.refresh()
+}
+
+// refresh discovers the endpoints.
+func (f *failover) refresh() {
+	endpoints, err := f.resolve()
+	if err == nil && len(endpoints) == 0 {
+		err = ErrNoEndpoints
+	}
+	if err == nil {
+		f.setEndpoints(endpoints)
+	}
+
+	f.mtx.Lock()
+	f.lastErr = err
+	f.mtx.Unlock()
+}
+
+// discovering returns true if the endpoints are discovered.
+func (f *failover) discovering() bool {
+	return f != nil && f.resolve != nil
+}
+
+// endpoints returns the current endpoints in the order of preference.
+func (f *failover) endpoints() []string {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return append([]string(nil), f.cfg.Endpoints...)
+}
+
+// err returns the error of the last discovery.
+func (f *failover) err() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.lastErr
+}
+
+// run probes the preferred endpoints in the background, and discovers the
+// endpoints if enabled, until failover is stopped.
+func (f *failover) run() {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		ticker := time.NewTicker(f.cfg.ProbeInterval)
+		defer ticker.Stop()
+
+		// Nil channel blocks forever if discovery is disabled.
+		var resolve <-chan time.Time
+		if f.resolve != nil {
+			resolveTicker := time.NewTicker(f.resolveInterval)
+			defer resolveTicker.Stop()
+			resolve = resolveTicker.C
+		}
+
+		for {
+			select {
+			case <-ticker.C:
+				f.restore()
+			case <-resolve:
+				f.refresh()
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the background probing.
+func (f *failover) stop() {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+
+	f.wg.Wait()
+}
+
+// hostFailed returns true if the error of the request means that the host
+// is unable to serve requests, rather than that the caller has given up.
+func hostFailed(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return false
+	}
+
+	return ctx.Err() == nil && isTransient(err)
+}
+
+// probeEndpoint makes the cheap request to the given endpoint, the engine
+// error in the response still means that the endpoint is alive.
+func (e *Client) probeEndpoint(ctx context.Context, endpoint string) error {
+	rpcReq := &request{
+		Method: "market.list",
+		Params: []interface{}{},
+		ID:     e.ids.NextID(),
+	}
+
+	data, err := json.Marshal(rpcReq)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		e.cfg.endpointURL(endpoint), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", e.cfg.userAgent())
+	e.cfg.applyAuthHeaders(req)
+	if err := e.signRequest(req, rpcReq); err != nil {
+		return err
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+	}
+
+	body, err := e.readResponseBody(resp)
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse(rpcReq.Method, rpcReq.ID, body, &baseResponse{})
+}
+
+// ActiveEndpoint returns the endpoint which requests are currently sent
+// to, empty if failover isn't configured.
+func (e *Client) ActiveEndpoint() string {
+	return e.failover.current()
+}
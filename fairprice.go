@@ -0,0 +1,106 @@
+package viabtc
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// sweepPrice returns the average price of filling the given notional,
+// expressed in money, against the depth levels, starting from the best one.
+func sweepPrice(levels []Depth, notional *big.Rat) (*big.Rat, error) {
+	remaining := new(big.Rat).Set(notional)
+	stock := new(big.Rat)
+
+	for _, level := range levels {
+		price, err := parseDecimal(level.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		volume, err := parseDecimal(level.Volume)
+		if err != nil {
+			return nil, err
+		}
+
+		if price.Sign() <= 0 {
+			continue
+		}
+
+		levelNotional := new(big.Rat).Mul(price, volume)
+		if levelNotional.Cmp(remaining) >= 0 {
+			stock.Add(stock, new(big.Rat).Quo(remaining, price))
+			return new(big.Rat).Quo(notional, stock), nil
+		}
+
+		stock.Add(stock, volume)
+		remaining.Sub(remaining, levelNotional)
+	}
+
+	return nil, fmt.Errorf("not enough liquidity, %v of notional left "+
+		"unfilled", remaining.FloatString(priceOutputPrec))
+}
+
+// FairPrice calculates the size-aware fair price of the market from its
+// depth: the average price of filling the given notional, expressed in
+// market money, is calculated on both sides of the book, and the middle of
+// them is returned. It is used to mark positions in thin markets where the
+// last price is unreliable.
+func FairPrice(depth *OrderDepthResponse, notional string) (string, error) {
+	size, err := parseDecimal(notional)
+	if err != nil {
+		return "", err
+	}
+
+	if size.Sign() <= 0 {
+		return "", fmt.Errorf("notional should be positive, got %q",
+			notional)
+	}
+
+	ask, err := sweepPrice(depth.Asks, size)
+	if err != nil {
+		return "", fmt.Errorf("unable to fill asks: %w", err)
+	}
+
+	bid, err := sweepPrice(depth.Bids, size)
+	if err != nil {
+		return "", fmt.Errorf("unable to fill bids: %w", err)
+	}
+
+	fair := new(big.Rat).Add(ask, bid)
+	fair.Quo(fair, big.NewRat(2, 1))
+	return fair.FloatString(priceOutputPrec), nil
+}
+
+// FairPriceSource uses the liquidity-weighted fair price of the market,
+// calculated over the depth with the given notional.
+type FairPriceSource struct {
+	client   *Client
+	notional string
+}
+
+// A compile time check to ensure FairPriceSource implements the
+// PriceSource interface.
+var _ PriceSource = (*FairPriceSource)(nil)
+
+// NewFairPriceSource creates new fair price source, notional is expressed
+// in market money.
+func NewFairPriceSource(client *Client, notional string) *FairPriceSource {
+	return &FairPriceSource{
+		client:   client,
+		notional: notional,
+	}
+}
+
+// Price returns the fair price of the market.
+func (s *FairPriceSource) Price(market MarketType) (string, error) {
+	depth, err := s.client.OrderDepth(&OrderDepthRequest{
+		Market:   market.String(),
+		Limit:    MaxLimit,
+		Interval: "0",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return FairPrice(depth, s.notional)
+}
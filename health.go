@@ -0,0 +1,112 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClientClosed is returned by probes of the closed client.
+	ErrClientClosed = errors.New("client is closed")
+
+	// ErrNoEndpoints is returned by readiness probe if endpoint discovery
+	// is enabled, but no endpoints are known.
+	ErrNoEndpoints = errors.New("no server endpoints available")
+)
+
+// Live reports whether the client itself is operational. It doesn't contact
+// the server, so that unavailability of the exchange doesn't make the
+// embedding service to be restarted.
+func (e *Client) Live(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if atomic.LoadInt32(&e.closed) == 1 {
+		return ErrClientClosed
+	}
+
+	return nil
+}
+
+// Ready reports whether the client is able to serve requests. In addition
+// to the liveness it checks that server endpoints are known, if endpoint
+// discovery is enabled, and makes the cheap request to the server which is
+// bound to the given context.
+func (e *Client) Ready(ctx context.Context) error {
+	if err := e.Live(ctx); err != nil {
+		return err
+	}
+
+	if e.discovering() && len(e.Endpoints()) == 0 {
+		if err := e.EndpointsErr(); err != nil {
+			return fmt.Errorf("%w: %v", ErrNoEndpoints, err)
+		}
+		return ErrNoEndpoints
+	}
+
+	type Response struct {
+		baseResponse
+		Result *MarketListResponse
+	}
+
+	response := &Response{}
+	err := e.makeRPCCallContext(ctx, "market.list", &MarketListRequest{},
+		response)
+	if err != nil {
+		return err
+	}
+
+	// https://golang.org/doc/faq#nil_error
+	if response.Error != nil {
+		return response.Error
+	}
+
+	return nil
+}
+
+// Ping makes the cheap request to the server and returns its round trip
+// time, including the retries if they are enabled. Engine error is
+// returned as error, since the engine which is unable to list the assets
+// isn't able to serve other requests either.
+func (e *Client) Ping(ctx context.Context) (time.Duration, error) {
+	type Response struct {
+		baseResponse
+		Result *AssetListResponse
+	}
+
+	start := e.now()
+
+	response := &Response{}
+	err := e.makeRPCCallContext(ctx, "asset.list", &AssetListRequest{},
+		response)
+	if err != nil {
+		return 0, err
+	}
+
+	// https://golang.org/doc/faq#nil_error
+	if response.Error != nil {
+		return 0, response.Error
+	}
+
+	return e.since(start), nil
+}
+
+// ProbeHandler returns the http handler which responds with 200 status if
+// probe succeeds and with 503 status otherwise, it is intended to be used
+// as kubernetes probe endpoint, e.g. ProbeHandler(client.Ready).
+func ProbeHandler(probe func(ctx context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := probe(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
@@ -0,0 +1,146 @@
+package viabtc
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// DefaultHedgeMethods is the read-only methods which are hedged if methods
+// aren't specified.
+var DefaultHedgeMethods = []string{
+	"order.book",
+	"order.depth",
+	"market.last",
+}
+
+// HedgeConfig is an structure which holds configurable parameters of the
+// hedged requests.
+type HedgeConfig struct {
+	// Delay is the time after which the second request is sent if the
+	// first one hasn't been answered yet, it should be about the 95th
+	// percentile of the latency of the hedged methods.
+	Delay time.Duration
+
+	// Methods is the idempotent methods which are hedged,
+	// DefaultHedgeMethods if not specified.
+	Methods []string
+}
+
+// hedger holds the hedge config in the form which is convenient to check.
+type hedger struct {
+	delay   time.Duration
+	methods map[string]struct{}
+}
+
+func newHedger(cfg *HedgeConfig) *hedger {
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = DefaultHedgeMethods
+	}
+
+	h := &hedger{
+		delay:   cfg.Delay,
+		methods: make(map[string]struct{}, len(methods)),
+	}
+	for _, method := range methods {
+		h.methods[method] = struct{}{}
+	}
+
+	return h
+}
+
+// hedged returns true if the requests of the method should be hedged.
+func (h *hedger) hedged(method string) bool {
+	if h == nil {
+		return false
+	}
+
+	_, ok := h.methods[method]
+	return ok
+}
+
+type endpointKey struct{}
+
+// requestEndpoint returns the endpoint which the request should be sent
+// to, empty if the default url should be used.
+func (e *Client) requestEndpoint(ctx context.Context) string {
+	if endpoint, ok := ctx.Value(endpointKey{}).(string); ok {
+		return endpoint
+	}
+
+	return e.failover.current()
+}
+
+// hedgedCall executes the remote procedure call, and if the method is
+// hedged and response doesn't arrive within the delay, sends the second
+// request to the alternate endpoint and takes the first successful
+// response.
+func (e *Client) hedgedCall(ctx context.Context, method string,
+	params interface{}, rpcResp interface{}) error {
+
+	respType := reflect.TypeOf(rpcResp)
+	if !e.hedger.hedged(method) || respType.Kind() != reflect.Ptr {
+		return e.doRPCCall(ctx, method, params, rpcResp)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp interface{}
+		info *CallInfo
+		err  error
+	}
+
+	// Results are buffered, so that the goroutine of the losing request
+	// doesn't block when nobody waits for it.
+	results := make(chan result, 2)
+	launch := func(endpoint string) {
+		ctx, info := withNewCallInfo(ctx)
+		if endpoint != "" {
+			ctx = context.WithValue(ctx, endpointKey{}, endpoint)
+		}
+
+		resp := reflect.New(respType.Elem()).Interface()
+		err := e.doRPCCall(ctx, method, params, resp)
+		results <- result{resp: resp, info: info, err: err}
+	}
+
+	go launch(e.requestEndpoint(ctx))
+
+	timer := time.NewTimer(e.hedger.delay)
+	defer timer.Stop()
+
+	hedged, pending := false, 1
+
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			pending++
+			e.counters.hedge()
+			go launch(e.failover.alternate())
+
+		case r := <-results:
+			pending--
+
+			// The failed request is answered by the other one, if it
+			// is still in flight.
+			if r.err != nil && hedged && pending > 0 {
+				continue
+			}
+
+			reflect.ValueOf(rpcResp).Elem().Set(
+				reflect.ValueOf(r.resp).Elem())
+
+			info := callInfo(ctx)
+			info.RequestID = r.info.RequestID
+			info.RequestSize = r.info.RequestSize
+			info.ResponseSize = r.info.ResponseSize
+			info.Attempts = r.info.Attempts
+			info.EngineError = r.info.EngineError
+			return r.err
+		}
+	}
+}
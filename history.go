@@ -0,0 +1,270 @@
+package viabtc
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryIssueType is the type of the problem found in the historical data.
+type HistoryIssueType string
+
+const (
+	// HistoryGap is the range of the missing klines or deals.
+	HistoryGap HistoryIssueType = "gap"
+
+	// HistoryDuplicate is the kline or deal which occurred more than once.
+	HistoryDuplicate HistoryIssueType = "duplicate"
+
+	// HistoryNonMonotonic is the kline or deal which goes before the
+	// previous one.
+	HistoryNonMonotonic HistoryIssueType = "non_monotonic"
+)
+
+// HistoryIssue describes the problem found in the historical data.
+type HistoryIssue struct {
+	Market string           `json:"market"`
+	Data   WatchDataType    `json:"data"`
+	Issue  HistoryIssueType `json:"issue"`
+
+	// Source and Line is the file and the line of the event where the
+	// problem was found.
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+
+	// From and To is the range of the missing klines, the end is
+	// exclusive. For other issues of klines both are the time of the
+	// kline, for deals both are the time of the deal.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// FromID and ToID is the inclusive range of the missing deal ids,
+	// which are reported only if the checker expects contiguous ids. For
+	// other issues of deals both are the id of the deal.
+	FromID int32 `json:"from_id,omitempty"`
+	ToID   int32 `json:"to_id,omitempty"`
+}
+
+// HistoryStream is the summary of the klines or deals of the market.
+type HistoryStream struct {
+	Market string        `json:"market"`
+	Data   WatchDataType `json:"data"`
+
+	// Count is the number of unique klines or deals.
+	Count int `json:"count"`
+
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+
+	// FirstID and LastID is the range of the deal ids.
+	FirstID int32 `json:"first_id,omitempty"`
+	LastID  int32 `json:"last_id,omitempty"`
+}
+
+// HistoryReport is the machine-readable result of the history check.
+type HistoryReport struct {
+	Streams []HistoryStream `json:"streams"`
+	Issues  []HistoryIssue  `json:"issues"`
+}
+
+type historyKey struct {
+	market string
+	data   WatchDataType
+}
+
+// historyState is the state of the single stream.
+type historyState struct {
+	stream   HistoryStream
+	lastTime float64
+	lastID   int32
+}
+
+// HistoryChecker scans the downloaded or recorded klines and deals for
+// gaps, duplicates and non-monotonic timestamps. Events of every market and
+// data type are expected in the chronological order, so files should be
+// checked from oldest to newest. Overlapping kline windows, which are
+// written by the recorder, aren't reported as duplicates.
+type HistoryChecker struct {
+	// ContiguousDealIDs, if set, makes the checker to report the missing
+	// deal ids as gaps. Deal ids are assigned by the engine across all
+	// markets, so they are contiguous within the market only if the
+	// engine runs the single market, otherwise gaps of deals aren't
+	// detectable.
+	ContiguousDealIDs bool
+
+	// interval is the kline interval in seconds.
+	interval int32
+
+	states map[historyKey]*historyState
+	issues []HistoryIssue
+}
+
+// NewHistoryChecker creates new checker of the data with klines of the
+// given interval in seconds.
+func NewHistoryChecker(klineInterval int32) *HistoryChecker {
+	return &HistoryChecker{
+		interval: klineInterval,
+		states:   make(map[historyKey]*historyState),
+	}
+}
+
+func (c *HistoryChecker) state(market string,
+	data WatchDataType) *historyState {
+
+	key := historyKey{market: market, data: data}
+	s, ok := c.states[key]
+	if !ok {
+		s = &historyState{
+			stream: HistoryStream{
+				Market: market,
+				Data:   data,
+			},
+		}
+		c.states[key] = s
+	}
+
+	return s
+}
+
+// Check reads the events from the source, source is the name which is used
+// in the issues, e.g. the path of the file. Events other than klines and
+// deals are skipped.
+func (c *HistoryChecker) Check(source string, r *WatchEventReader) error {
+	line := 0
+	for r.Next() {
+		line++
+		event := r.Event()
+
+		issue := HistoryIssue{
+			Market: event.Market,
+			Data:   event.Type,
+			Source: source,
+			Line:   line,
+		}
+
+		switch data := event.Data.(type) {
+		case MarketKLineResponse:
+			c.checkKLines(c.state(event.Market, event.Type), data, issue)
+
+		case MarketDealsResponse:
+			c.checkDeals(c.state(event.Market, event.Type), data, issue)
+		}
+	}
+
+	return r.Err()
+}
+
+func (c *HistoryChecker) report(issue HistoryIssue, t HistoryIssueType,
+	from, to time.Time, fromID, toID int32) {
+
+	issue.Issue = t
+	issue.From = from
+	issue.To = to
+	issue.FromID = fromID
+	issue.ToID = toID
+	c.issues = append(c.issues, issue)
+}
+
+func (c *HistoryChecker) checkKLines(s *historyState, klines MarketKLineResponse,
+	issue HistoryIssue) {
+
+	for i, kline := range klines {
+		t := timeFromUnix(kline.Time)
+
+		if s.stream.Count != 0 && kline.Time <= s.lastTime {
+			// Window which overlaps the previous one repeats its klines.
+			if len(klines) > 1 && i == 0 {
+				continue
+			}
+			if len(klines) > 1 && klines[i-1].Time < kline.Time {
+				continue
+			}
+
+			issueType := HistoryNonMonotonic
+			if kline.Time == s.lastTime {
+				issueType = HistoryDuplicate
+			}
+			c.report(issue, issueType, t, t, 0, 0)
+			continue
+		}
+
+		if s.stream.Count != 0 && c.interval > 0 &&
+			kline.Time > s.lastTime+float64(c.interval) {
+
+			c.report(issue, HistoryGap,
+				timeFromUnix(s.lastTime+float64(c.interval)), t, 0, 0)
+		}
+
+		if s.stream.Count == 0 {
+			s.stream.First = t
+		}
+		s.stream.Last = t
+		s.stream.Count++
+		s.lastTime = kline.Time
+	}
+}
+
+func (c *HistoryChecker) checkDeals(s *historyState, deals MarketDealsResponse,
+	issue HistoryIssue) {
+
+	// Recorded events have deals from newest to oldest.
+	deals = append(MarketDealsResponse(nil), deals...)
+	sort.SliceStable(deals, func(i, j int) bool {
+		return deals[i].DealID < deals[j].DealID
+	})
+
+	for _, deal := range deals {
+		t := timeFromUnix(deal.Time)
+
+		if s.stream.Count != 0 && deal.DealID <= s.lastID {
+			issueType := HistoryNonMonotonic
+			if deal.DealID == s.lastID {
+				issueType = HistoryDuplicate
+			}
+			c.report(issue, issueType, t, t, deal.DealID, deal.DealID)
+			continue
+		}
+
+		if c.ContiguousDealIDs && s.stream.Count != 0 &&
+			deal.DealID > s.lastID+1 {
+
+			c.report(issue, HistoryGap, timeFromUnix(s.lastTime), t,
+				s.lastID+1, deal.DealID-1)
+		}
+
+		if s.stream.Count != 0 && deal.Time < s.lastTime {
+			c.report(issue, HistoryNonMonotonic, t, t, deal.DealID,
+				deal.DealID)
+		}
+
+		if s.stream.Count == 0 {
+			s.stream.First = t
+			s.stream.FirstID = deal.DealID
+		}
+		s.stream.Last = t
+		s.stream.LastID = deal.DealID
+		s.stream.Count++
+		s.lastTime = deal.Time
+		s.lastID = deal.DealID
+	}
+}
+
+// Report returns the summary of the checked streams and the found issues.
+func (c *HistoryChecker) Report() *HistoryReport {
+	report := &HistoryReport{
+		Streams: make([]HistoryStream, 0, len(c.states)),
+		Issues:  append([]HistoryIssue{}, c.issues...),
+	}
+
+	for _, s := range c.states {
+		report.Streams = append(report.Streams, s.stream)
+	}
+
+	sort.Slice(report.Streams, func(i, j int) bool {
+		if report.Streams[i].Market != report.Streams[j].Market {
+			return report.Streams[i].Market < report.Streams[j].Market
+		}
+		return report.Streams[i].Data < report.Streams[j].Data
+	})
+
+	return report
+}
@@ -0,0 +1,118 @@
+package viabtc
+
+import (
+	"context"
+	"net/http"
+)
+
+// Invoker executes the rpc call of the method with the given params and
+// decodes the result into the response.
+type Invoker func(ctx context.Context, method string, params interface{},
+	rpcResp interface{}) error
+
+// Interceptor runs around every rpc call made by the client, in the same
+// way as the unary interceptors of gRPC. It might inspect or replace the
+// params before calling the invoker, inspect the response and the error
+// after it, or not call the invoker at all. Interceptor wraps the whole
+// call, including the retries and the circuit breaker. Headers of the http
+// request might be added with WithRequestHeader.
+type Interceptor func(ctx context.Context, method string, params interface{},
+	rpcResp interface{}, invoker Invoker) error
+
+// chainInterceptors returns the invoker which calls the interceptors in the
+// given order, the first one is the outermost.
+func chainInterceptors(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, method string,
+			params interface{}, rpcResp interface{}) error {
+
+			return interceptor(ctx, method, params, rpcResp, next)
+		}
+	}
+
+	return invoker
+}
+
+type requestHeaderKey struct{}
+
+// WithRequestHeader returns the context which makes client to add the
+// header to the http request, e.g. the authorization header required by
+// the reverse proxy in front of the engine.
+func WithRequestHeader(ctx context.Context, key, value string) context.Context {
+	header := http.Header{}
+	if parent, ok := ctx.Value(requestHeaderKey{}).(http.Header); ok {
+		header = parent.Clone()
+	}
+	header.Add(key, value)
+
+	return context.WithValue(ctx, requestHeaderKey{}, header)
+}
+
+// applyRequestHeaders adds the headers of the context to the request.
+func applyRequestHeaders(ctx context.Context, req *http.Request) {
+	header, ok := ctx.Value(requestHeaderKey{}).(http.Header)
+	if !ok {
+		return
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+// CallInfo is the details of the rpc call which aren't visible through
+// the params and the response, it is filled by the client if the context
+// of the call is created with WithCallInfo. Fields describe the last
+// attempt of the call.
+type CallInfo struct {
+	// RequestID is the id of the json-rpc request.
+	RequestID int32
+
+	// RequestSize and ResponseSize is the size of the request and
+	// response bodies in bytes.
+	RequestSize  int
+	ResponseSize int
+
+	// Attempts is the number of made attempts, including the retries.
+	Attempts int
+
+	// EngineError is the error returned by the engine in the response, if
+	// any.
+	EngineError *RPCError
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns the context which makes client to fill the details
+// of the call made with it, it is intended to be used by the interceptors,
+// e.g. for metrics and tracing. If the context already carries the call
+// info, e.g. requested by the outer interceptor, it is reused, so that the
+// chained interceptors see the same filled details.
+func WithCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	if info, ok := ctx.Value(callInfoKey{}).(*CallInfo); ok {
+		return ctx, info
+	}
+
+	return withNewCallInfo(ctx)
+}
+
+// withNewCallInfo returns the context with the new call info, which hides
+// the call info of the parent context. It is used for the concurrent
+// requests of the same call, which shouldn't fill the same details.
+func withNewCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	info := &CallInfo{}
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
+
+// callInfo returns the call info of the context, or the dummy one if it
+// isn't requested.
+func callInfo(ctx context.Context) *CallInfo {
+	if info, ok := ctx.Value(callInfoKey{}).(*CallInfo); ok {
+		return info
+	}
+
+	return &CallInfo{}
+}
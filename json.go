@@ -1,15 +1,15 @@
 package viabtc
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
-	"fmt"
-
 	"encoding/json"
-
-	"github.com/pkg/errors"
 )
 
 func (t UnixTime) MarshalJSON() ([]byte, error) {
@@ -41,7 +41,20 @@ func (t UnixTime) String() string {
 	return time.Time(t).String()
 }
 
+// isJSONObject returns true if the data is the json object.
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) != 0 && data[0] == '{'
+}
+
 func (k *Kline) UnmarshalJSON(s []byte) (err error) {
+	// Object form is produced by the default marshalling, e.g. in the
+	// recorded market data.
+	if isJSONObject(s) {
+		type kline Kline
+		return json.Unmarshal(s, (*kline)(k))
+	}
+
 	var values []interface{}
 	if err := json.Unmarshal(s, &values); err != nil {
 		return err
@@ -51,18 +64,44 @@ func (k *Kline) UnmarshalJSON(s []byte) (err error) {
 		return errors.New("unable to decode kline, wrong elements number")
 	}
 
-	k.Time = values[0].(float64)
-	k.OpenPrice = values[1].(string)
-	k.ClosePrice = values[2].(string)
-	k.HighestPrice = values[3].(string)
-	k.LowestPrice = values[4].(string)
-	k.Volume = values[5].(string)
-	k.Amount = values[6].(string)
-	k.Market = NewMarket(values[7].(string))
+	t, ok := values[0].(float64)
+	if !ok {
+		return fmt.Errorf("unable to decode kline, time should be "+
+			"number, got %T", values[0])
+	}
+
+	strs := make([]string, len(values)-1)
+	for i, v := range values[1:] {
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("unable to decode kline, element %v "+
+				"should be string, got %T", i+1, v)
+		}
+		strs[i] = str
+	}
+
+	market, err := ParseMarket(strs[6])
+	if err != nil {
+		return fmt.Errorf("unable to decode kline: %w", err)
+	}
+
+	k.Time = t
+	k.OpenPrice = strs[0]
+	k.ClosePrice = strs[1]
+	k.HighestPrice = strs[2]
+	k.LowestPrice = strs[3]
+	k.Volume = strs[4]
+	k.Amount = strs[5]
+	k.Market = market
 	return nil
 }
 
 func (a *Depth) UnmarshalJSON(s []byte) (err error) {
+	if isJSONObject(s) {
+		type depth Depth
+		return json.Unmarshal(s, (*depth)(a))
+	}
+
 	values := make([]string, 0)
 	if err := json.Unmarshal(s, &values); err != nil {
 		return err
@@ -80,3 +119,100 @@ func (a *Depth) UnmarshalJSON(s []byte) (err error) {
 func (a Depth) String() string {
 	return fmt.Sprintf("\n\tVolume: %v \n\tPrice: %v", a.Volume, a.Price)
 }
+
+// snippetLength is the maximum length of the payload which is attached to
+// the decode error.
+const snippetLength = 128
+
+// snippet returns the beginning of the payload, which is used to give a
+// hint about the content of undecodable response.
+func snippet(body []byte) string {
+	if len(body) > snippetLength {
+		return string(body[:snippetLength]) + "..."
+	}
+
+	return string(body)
+}
+
+// decodeResponse verifies that the body is a well-formed rpc response and
+// populates the rpc response object with it, the id of the response should
+// match the id of the request. On failure descriptive DecodeError is
+// returned.
+func decodeResponse(method string, id int32, body []byte,
+	rpcResp interface{}) error {
+
+	fail := func(reason string, err error) error {
+		return &DecodeError{
+			Method:  method,
+			Reason:  reason,
+			Snippet: snippet(body),
+			Err:     err,
+		}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0:
+		return fail("empty response body", nil)
+
+	case trimmed[0] == '<':
+		return fail("got html page instead of json", nil)
+
+	case trimmed[0] != '{':
+		return fail("response is not a json object", nil)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &envelope); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok && !json.Valid(trimmed) {
+			var v interface{}
+			d := json.NewDecoder(bytes.NewReader(trimmed))
+			if err := d.Decode(&v); err == io.ErrUnexpectedEOF {
+				return fail("response body is truncated", err)
+			}
+		}
+
+		return fail("malformed json", err)
+	}
+
+	// Result might be null if nothing was found, but either result or
+	// error should be present in the response.
+	rpcErr, hasErr := envelope["error"]
+	if hasErr && string(rpcErr) == "null" {
+		hasErr = false
+	}
+
+	if _, hasResult := envelope["result"]; !hasResult && !hasErr {
+		return fail("response contains neither result nor error", nil)
+	}
+
+	// Response id might be omitted or null if engine couldn't parse the
+	// request, otherwise it should be the same as the request one.
+	if rawID, ok := envelope["id"]; ok && string(rawID) != "null" {
+		var respID int64
+		if err := json.Unmarshal(rawID, &respID); err != nil {
+			return fail("response id isn't a number", err)
+		}
+
+		if respID != int64(id) {
+			return fail(fmt.Sprintf("response id %v doesn't match "+
+				"request id %v", respID, id), nil)
+		}
+	}
+
+	if err := json.Unmarshal(trimmed, rpcResp); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return fail(fmt.Sprintf("field %q has unexpected type %v, "+
+				"expected %v", typeErr.Field, typeErr.Value, typeErr.Type),
+				err)
+		}
+
+		return fail(err.Error(), err)
+	}
+
+	if r, ok := rpcResp.(engineErrorer); ok && r.engineError() != nil {
+		r.engineError().Method = method
+	}
+
+	return nil
+}
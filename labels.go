@@ -0,0 +1,14 @@
+package viabtc
+
+import "runtime/pprof"
+
+// rpcLabels returns the profiler labels of the goroutine which makes the
+// rpc request, so that cpu and goroutine profiles of the services attribute
+// the time spent inside the client to the method and market.
+func rpcLabels(method string, params interface{}) pprof.LabelSet {
+	if market := marketField(params); market != "" {
+		return pprof.Labels("viabtc_method", method, "viabtc_market", market)
+	}
+
+	return pprof.Labels("viabtc_method", method)
+}
@@ -0,0 +1,331 @@
+package viabtc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotLeader is returned by mutating methods if the leader lease is
+// configured, but the process doesn't hold it.
+var ErrNotLeader = errors.New("process doesn't hold the leader lease, " +
+	"mutating requests are forbidden")
+
+// mutatingMethods is the set of methods which change the state of the
+// engine, and which are allowed only for the leader.
+var mutatingMethods = map[string]struct{}{
+	"balance.update":        {},
+	"asset.update_batch":    {},
+	"order.put_limit":       {},
+	"order.put_market":      {},
+	"order.put_stop_market": {},
+	"order.cancel":          {},
+	"order.cancel_all":      {},
+	"order.cancel_stop":     {},
+}
+
+// isMutating returns true if the method is the known mutating method or the
+// custom method which isn't registered as read-only.
+func isMutating(method string) bool {
+	if _, ok := mutatingMethods[method]; ok {
+		return true
+	}
+
+	if m, ok := lookupCustomMethod(method); ok {
+		return !m.readOnly
+	}
+
+	return false
+}
+
+// LeaderLease reports whether the process holds the leadership, it is used
+// to guard the mutating requests in active-passive deployments, so that
+// both instances can't place orders during the failover overlap.
+type LeaderLease interface {
+	// IsLeader returns true if the lease is held. It should be cheap,
+	// because it is called before every mutating request, and it should
+	// return false as soon as lease might have been expired.
+	IsLeader() bool
+}
+
+// checkLeader returns ErrNotLeader if the method is mutating and the
+// process doesn't hold the leader lease.
+func (e *Client) checkLeader(method string) error {
+	if e.cfg.LeaderLease == nil {
+		return nil
+	}
+
+	if !isMutating(method) {
+		return nil
+	}
+
+	if !e.cfg.LeaderLease.IsLeader() {
+		return ErrNotLeader
+	}
+
+	return nil
+}
+
+// EtcdLeaseConfig is an structure which holds configurable parameters of
+// the etcd leader lease.
+type EtcdLeaseConfig struct {
+	// Address is the url of the etcd v3 http gateway, e.g.
+	// "http://127.0.0.1:2379".
+	Address string
+
+	// Key is the election key, all candidates should use the same key.
+	Key string
+
+	// ID identifies the candidate, it is stored as the value of the key.
+	ID string
+
+	// TTL is the time to live of the lease, it is rounded to seconds.
+	TTL time.Duration
+
+	// Token, if set, is the auth token of the requests.
+	Token string
+}
+
+// EtcdLease campaigns for the leadership by creating the key attached to
+// the etcd lease, and keeps the lease alive while it holds the key. The
+// leadership is considered lost if the lease wasn't renewed within the
+// two-thirds of the time to live.
+type EtcdLease struct {
+	cfg        EtcdLeaseConfig
+	httpClient *http.Client
+
+	mtx     sync.Mutex
+	leaseID string
+	leader  bool
+	renewed time.Time
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure EtcdLease implements the LeaderLease
+// interface.
+var _ LeaderLease = (*EtcdLease)(nil)
+
+// NewEtcdLease creates the etcd leader lease and starts campaigning.
+func NewEtcdLease(cfg *EtcdLeaseConfig) (*EtcdLease, error) {
+	c := *cfg
+
+	if c.Key == "" || c.ID == "" {
+		return nil, errors.New("election key and candidate id should be " +
+			"specified")
+	}
+
+	if c.TTL < 2*time.Second {
+		return nil, errors.New("lease ttl should be at least two seconds")
+	}
+
+	l := &EtcdLease{
+		cfg:        c,
+		httpClient: &http.Client{Timeout: c.TTL / 3},
+		quit:       make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.campaign()
+
+	return l, nil
+}
+
+// IsLeader returns true if the lease is held and was renewed recently.
+func (l *EtcdLease) IsLeader() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.leader && time.Since(l.renewed) < l.cfg.TTL*2/3
+}
+
+// Err returns the error of the last campaign attempt.
+func (l *EtcdLease) Err() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.lastErr
+}
+
+// Close stops campaigning and releases the leadership.
+func (l *EtcdLease) Close() {
+	close(l.quit)
+	l.wg.Wait()
+
+	l.mtx.Lock()
+	leaseID := l.leaseID
+	l.leader = false
+	l.mtx.Unlock()
+
+	if leaseID != "" {
+		// Revoking the lease deletes the key, so that the other candidate
+		// doesn't need to wait for expiration.
+		l.call("/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, nil)
+	}
+}
+
+func (l *EtcdLease) campaign() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		leader, err := l.step()
+
+		l.mtx.Lock()
+		l.lastErr = err
+		l.leader = leader
+		if leader {
+			// Expiration is counted from the moment the keepalive was
+			// sent, because the lease might be renewed at any point of
+			// the step.
+			l.renewed = start
+		}
+		l.mtx.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// step renews the lease and tries to acquire the election key, it returns
+// true if the key is held by this candidate.
+func (l *EtcdLease) step() (bool, error) {
+	l.mtx.Lock()
+	leaseID := l.leaseID
+	l.mtx.Unlock()
+
+	if leaseID != "" {
+		var resp struct {
+			Result struct {
+				TTL string `json:"TTL"`
+			} `json:"result"`
+		}
+
+		err := l.call("/v3/lease/keepalive",
+			map[string]interface{}{"ID": leaseID}, &resp)
+		if err != nil {
+			return false, err
+		}
+
+		// Zero or absent ttl means that the lease has expired.
+		if ttl, _ := strconv.Atoi(resp.Result.TTL); ttl <= 0 {
+			leaseID = ""
+		}
+	}
+
+	if leaseID == "" {
+		var resp struct {
+			ID string `json:"ID"`
+		}
+
+		err := l.call("/v3/lease/grant", map[string]interface{}{
+			"TTL": int64(l.cfg.TTL.Seconds()),
+		}, &resp)
+		if err != nil {
+			return false, err
+		}
+		leaseID = resp.ID
+
+		l.mtx.Lock()
+		l.leaseID = leaseID
+		l.mtx.Unlock()
+	}
+
+	key := base64.StdEncoding.EncodeToString([]byte(l.cfg.Key))
+	value := base64.StdEncoding.EncodeToString([]byte(l.cfg.ID))
+
+	// Create the key only if it doesn't exist, otherwise read it to check
+	// whether it was created by this candidate.
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			ResponseRange struct {
+				KVs []struct {
+					Value string `json:"value"`
+					Lease string `json:"lease"`
+				} `json:"kvs"`
+			} `json:"response_range"`
+		} `json:"responses"`
+	}
+
+	err := l.call("/v3/kv/txn", map[string]interface{}{
+		"compare": []interface{}{map[string]interface{}{
+			"key":             key,
+			"target":          "CREATE",
+			"result":          "EQUAL",
+			"create_revision": "0",
+		}},
+		"success": []interface{}{map[string]interface{}{
+			"request_put": map[string]interface{}{
+				"key":   key,
+				"value": value,
+				"lease": leaseID,
+			},
+		}},
+		"failure": []interface{}{map[string]interface{}{
+			"request_range": map[string]interface{}{
+				"key": key,
+			},
+		}},
+	}, &resp)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.Succeeded {
+		return true, nil
+	}
+
+	for _, r := range resp.Responses {
+		for _, kv := range r.ResponseRange.KVs {
+			if kv.Value == value && kv.Lease == leaseID {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// call makes the request to the etcd http gateway.
+func (l *EtcdLease) call(path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimSuffix(l.cfg.Address, "/") + path
+	req, err := http.NewRequest("POST", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.cfg.Token != "" {
+		req.Header.Set("Authorization", l.cfg.Token)
+	}
+
+	if result == nil {
+		result = &json.RawMessage{}
+	}
+
+	if err := registryRequest(l.httpClient, req, result); err != nil {
+		return fmt.Errorf("etcd request %v failed: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package viabtc
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// reservationKey identifies the funds of the specific user in the specific
+// asset.
+type reservationKey struct {
+	UserID uint32
+	Asset  AssetType
+}
+
+// reservationLedger is the local ledger which keeps track of the funds
+// which are about to be frozen by the order placement, but which are not yet
+// reflected in the balance returned by the exchange client. It is used to
+// prevent racing strategies from putting the orders which engine will
+// reject with "balance not enough" error.
+type reservationLedger struct {
+	sync.Mutex
+
+	// userLocks is used to serialize the balance check and reservation of
+	// funds of the same user.
+	userLocks map[uint32]*sync.Mutex
+
+	reserved map[reservationKey]*big.Rat
+}
+
+func newReservationLedger() *reservationLedger {
+	return &reservationLedger{
+		userLocks: make(map[uint32]*sync.Mutex),
+		reserved:  make(map[reservationKey]*big.Rat),
+	}
+}
+
+// userLock returns the lock which guards the balance check of the user.
+func (l *reservationLedger) userLock(userID uint32) *sync.Mutex {
+	l.Lock()
+	defer l.Unlock()
+
+	m, ok := l.userLocks[userID]
+	if !ok {
+		m = &sync.Mutex{}
+		l.userLocks[userID] = m
+	}
+
+	return m
+}
+
+// reserve occupies the given amount of funds, if the available balance
+// minus already reserved funds is enough for that.
+func (l *reservationLedger) reserve(key reservationKey, available,
+	amount *big.Rat) bool {
+
+	l.Lock()
+	defer l.Unlock()
+
+	reserved, ok := l.reserved[key]
+	if !ok {
+		reserved = new(big.Rat)
+	}
+
+	free := new(big.Rat).Sub(available, reserved)
+	if free.Cmp(amount) < 0 {
+		return false
+	}
+
+	l.reserved[key] = new(big.Rat).Add(reserved, amount)
+	return true
+}
+
+// release frees the funds which were previously reserved.
+func (l *reservationLedger) release(key reservationKey, amount *big.Rat) {
+	l.Lock()
+	defer l.Unlock()
+
+	reserved, ok := l.reserved[key]
+	if !ok {
+		return
+	}
+
+	reserved = new(big.Rat).Sub(reserved, amount)
+	if reserved.Sign() <= 0 {
+		delete(l.reserved, key)
+		return
+	}
+
+	l.reserved[key] = reserved
+}
+
+// requiredFunds returns the asset and amount of funds which will be frozen by
+// the engine on the limit order placement. On ask side user sells the stock,
+// on bid side user gives money for the stock.
+func requiredFunds(params *OrderPutLimitRequest) (AssetType, *big.Rat, error) {
+	market, err := ParseMarket(params.Market)
+	if err != nil {
+		return "", nil, err
+	}
+
+	amount, err := parseDecimal(params.Amount)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch params.Side {
+	case MarketOrderSideAsk:
+		return market.Stock, amount, nil
+
+	case MarketOrderSideBid:
+		price, err := parseDecimal(params.Price)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return market.Money, new(big.Rat).Mul(amount, price), nil
+
+	default:
+		return "", nil, fmt.Errorf("unknown order side: %v", params.Side)
+	}
+}
+
+// PlaceIfAffordable puts the limit order on the market only if the user has
+// enough available funds for it. The balance is fetched from the exchange
+// client and the required funds are reserved in the local ledger until the
+// order is handled by the engine, so that concurrent calls for the same user
+// don't spend the same funds twice.
+//
+// NOTE: The check is best-effort, engine still might reject the order if the
+// balance has been changed by someone else in the meantime.
+func (e *Client) PlaceIfAffordable(params *OrderPutLimitRequest) (
+	*OrderPutLimitResponse, error) {
+
+	// Order is placed with the original request, so that the market of
+	// the response is translated back to the unified symbol.
+	order := params
+	if e.cfg.SymbolMap != nil {
+		translated, err := e.cfg.SymbolMap.translateRequest(params)
+		if err != nil {
+			return nil, err
+		}
+		params = translated.(*OrderPutLimitRequest)
+	}
+
+	asset, required, err := requiredFunds(params)
+	if err != nil {
+		return nil, err
+	}
+
+	key := reservationKey{
+		UserID: params.UserID,
+		Asset:  asset,
+	}
+
+	m := e.ledger.userLock(params.UserID)
+	m.Lock()
+
+	balances, err := e.BalanceQuery(&BalanceQueryRequest{
+		UserID: params.UserID,
+		Assets: []AssetType{asset},
+	})
+	if err != nil {
+		m.Unlock()
+		return nil, err
+	}
+
+	available := new(big.Rat)
+	if balance, ok := balances[asset]; ok {
+		available, err = parseDecimal(balance.Available)
+		if err != nil {
+			m.Unlock()
+			return nil, err
+		}
+	}
+
+	reserved := e.ledger.reserve(key, available, required)
+	m.Unlock()
+
+	if !reserved {
+		return nil, &RPCError{
+			Code: CodeOrderBalanceNotEnough,
+			Message: "balance not enough, required " +
+				required.FloatString(8) + " " + string(asset),
+			Method: "order.put_limit",
+		}
+	}
+
+	// Once the order is handled by the engine the funds are either frozen
+	// within the order or the order was rejected, in both cases the local
+	// reservation is no longer needed.
+	defer e.ledger.release(key, required)
+
+	return e.OrderPutLimit(order)
+}
@@ -0,0 +1,113 @@
+package viabtc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// callTracker keeps track of the calls which are in flight, so that client
+// might wait for them to finish on shutdown.
+type callTracker struct {
+	mtx    sync.Mutex
+	closed bool
+	active int
+
+	// idle is closed when there are no active calls after the tracker is
+	// closed.
+	idle chan struct{}
+
+	// quit is closed when the tracker is closed, it interrupts the waits
+	// before the retries.
+	quit chan struct{}
+}
+
+func newCallTracker() *callTracker {
+	return &callTracker{
+		idle: make(chan struct{}),
+		quit: make(chan struct{}),
+	}
+}
+
+// acquire registers the new call, false is returned if the tracker is
+// closed.
+func (t *callTracker) acquire() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.active++
+	return true
+}
+
+// release unregisters the finished call.
+func (t *callTracker) release() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.active--
+	if t.closed && t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// close makes tracker to reject the new calls.
+func (t *callTracker) close() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	t.closed = true
+	close(t.quit)
+	if t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// wait waits for the active calls to finish.
+func (t *callTracker) wait(ctx context.Context) error {
+	select {
+	case <-t.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown gracefully stops the client: new calls are rejected with
+// ErrClientClosed, pending retries are abandoned, and the calls which are
+// in flight are waited for until the context is done. After that the
+// background activity of the client is stopped and idle connections are
+// closed. Error of the context is returned if it is done before the calls
+// are finished.
+func (e *Client) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&e.closed, 1)
+	e.calls.close()
+
+	err := e.calls.wait(ctx)
+
+	e.registry.Stop()
+
+	if e.pool != nil {
+		e.pool.stop()
+	}
+
+	if e.failover != nil {
+		e.failover.stop()
+	}
+
+	e.httpClient.CloseIdleConnections()
+	return err
+}
+
+// Close stops the client, waiting for the calls which are in flight to
+// finish.
+func (e *Client) Close() {
+	e.Shutdown(context.Background())
+}
@@ -0,0 +1,281 @@
+package viabtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces the values of the redacted fields.
+const redactedValue = "[redacted]"
+
+// DefaultRedactFields is the fields which are redacted if redaction is
+// enabled and fields aren't specified: user ids and balances.
+var DefaultRedactFields = []string{
+	"user", "user_id", "available", "freeze", "balance", "change",
+}
+
+// LogConfig is an structure which holds configurable parameters of the
+// logging of the rpc calls.
+type LogConfig struct {
+	// Logger is the structured logger of the calls.
+	Logger *slog.Logger
+
+	// RequestLevel and ResponseLevel is the level of the sent requests and
+	// received responses, debug if not specified.
+	RequestLevel  slog.Leveler
+	ResponseLevel slog.Leveler
+
+	// RetryLevel is the level of the repeated attempts, warn if not
+	// specified.
+	RetryLevel slog.Leveler
+
+	// ErrorLevel is the level of the failed calls, including the ones
+	// rejected by the engine, warn if not specified.
+	ErrorLevel slog.Leveler
+
+	// DecodeErrorLevel is the level of the responses which couldn't be
+	// decoded, error if not specified.
+	DecodeErrorLevel slog.Leveler
+
+	// Payloads, if set, makes client to log the params of the requests
+	// and the bodies of the responses.
+	Payloads bool
+
+	// Redact, if set, makes client to replace the values of the redact
+	// fields in the logged payloads.
+	Redact bool
+
+	// RedactFields is the names of the redacted fields, matched against
+	// both json keys and names of the request fields ignoring case and
+	// underscores, DefaultRedactFields if not specified.
+	RedactFields []string
+}
+
+// rpcLogger logs the rpc calls according to the log config.
+type rpcLogger struct {
+	cfg    LogConfig
+	redact map[string]struct{}
+}
+
+func newRPCLogger(cfg *LogConfig) *rpcLogger {
+	l := &rpcLogger{
+		cfg:    *cfg,
+		redact: make(map[string]struct{}),
+	}
+
+	if l.cfg.RequestLevel == nil {
+		l.cfg.RequestLevel = slog.LevelDebug
+	}
+	if l.cfg.ResponseLevel == nil {
+		l.cfg.ResponseLevel = slog.LevelDebug
+	}
+	if l.cfg.RetryLevel == nil {
+		l.cfg.RetryLevel = slog.LevelWarn
+	}
+	if l.cfg.ErrorLevel == nil {
+		l.cfg.ErrorLevel = slog.LevelWarn
+	}
+	if l.cfg.DecodeErrorLevel == nil {
+		l.cfg.DecodeErrorLevel = slog.LevelError
+	}
+
+	fields := l.cfg.RedactFields
+	if len(fields) == 0 {
+		fields = DefaultRedactFields
+	}
+	for _, field := range fields {
+		l.redact[normalizeField(field)] = struct{}{}
+	}
+
+	return l
+}
+
+// normalizeField makes "user_id", "UserID" and "userid" the same.
+func normalizeField(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+func (l *rpcLogger) redacted(name string) bool {
+	if !l.cfg.Redact {
+		return false
+	}
+
+	_, ok := l.redact[normalizeField(name)]
+	return ok
+}
+
+// redactJSON replaces the values of the redacted keys of the decoded json.
+func (l *rpcLogger) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if l.redacted(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = l.redactJSON(value)
+			}
+		}
+		return v
+
+	case []interface{}:
+		for i := range v {
+			v[i] = l.redactJSON(v[i])
+		}
+		return v
+
+	default:
+		return v
+	}
+}
+
+// params returns the loggable representation of the request params.
+func (l *rpcLogger) params(params interface{}) interface{} {
+	v := reflect.ValueOf(params)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		// Positional params don't have names, so they can't be
+		// redacted selectively.
+		if l.cfg.Redact {
+			return redactedValue
+		}
+		return params
+	}
+
+	fields := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if l.redacted(field.Name) {
+			fields[field.Name] = redactedValue
+		} else {
+			fields[field.Name] = v.Field(i).Interface()
+		}
+	}
+
+	return fields
+}
+
+// body returns the loggable representation of the response body.
+func (l *rpcLogger) body(body []byte) string {
+	if l.cfg.Redact {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return redactedValue
+		}
+
+		data, err := json.Marshal(l.redactJSON(v))
+		if err != nil {
+			return redactedValue
+		}
+		body = data
+	}
+
+	return snippet(body)
+}
+
+func (l *rpcLogger) log(ctx context.Context, level slog.Leveler,
+	msg string, attrs ...slog.Attr) {
+
+	if l == nil || l.cfg.Logger == nil {
+		return
+	}
+
+	l.cfg.Logger.LogAttrs(ctx, level.Level(), msg, attrs...)
+}
+
+// request logs the request which is about to be sent.
+func (l *rpcLogger) request(ctx context.Context, method string, id int32,
+	params interface{}) {
+
+	if l == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Int64("id", int64(id)),
+	}
+	if l.cfg.Payloads {
+		attrs = append(attrs, slog.Any("params", l.params(params)))
+	}
+
+	l.log(ctx, l.cfg.RequestLevel, "rpc request", attrs...)
+}
+
+// response logs the received response.
+func (l *rpcLogger) response(ctx context.Context, method string, id int32,
+	latency time.Duration, body []byte) {
+
+	if l == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Int64("id", int64(id)),
+		slog.Duration("latency", latency),
+		slog.Int("size", len(body)),
+	}
+	if l.cfg.Payloads {
+		attrs = append(attrs, slog.String("body", l.body(body)))
+	}
+
+	l.log(ctx, l.cfg.ResponseLevel, "rpc response", attrs...)
+}
+
+// failure logs the failed attempt of the call.
+func (l *rpcLogger) failure(ctx context.Context, method string, err error,
+	rpcResp interface{}) {
+
+	if l == nil {
+		return
+	}
+
+	if err != nil {
+		level := l.cfg.ErrorLevel
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			level = l.cfg.DecodeErrorLevel
+		}
+
+		l.log(ctx, level, "rpc call failed",
+			slog.String("method", method),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	if r, ok := rpcResp.(engineErrorer); ok && r.engineError() != nil {
+		l.log(ctx, l.cfg.ErrorLevel, "rpc call rejected by engine",
+			slog.String("method", method),
+			slog.Int("code", int(r.engineError().Code)),
+			slog.String("error", r.engineError().Message),
+		)
+	}
+}
+
+// retry logs the repeated attempt of the call.
+func (l *rpcLogger) retry(ctx context.Context, method string, attempt int) {
+	if l == nil {
+		return
+	}
+
+	l.log(ctx, l.cfg.RetryLevel, "rpc call is repeated",
+		slog.String("method", method),
+		slog.Int("attempt", attempt+1),
+	)
+}
@@ -0,0 +1,82 @@
+// Package natssink implements the sink of the watch events which publishes
+// them to NATS. Importing the package registers the "nats" sink type, so
+// that it might be used in watch specs:
+//
+//	{"name": "bus", "type": "nats", "options": {
+//		"url": "nats://127.0.0.1:4222",
+//		"subject": "viabtc.{market}.{type}"
+//	}}
+package natssink
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/nats-io/nats.go"
+)
+
+// defaultSubject is the subject template if not specified, "{market}" and
+// "{type}" placeholders are replaced with the market and type of the event.
+const defaultSubject = "viabtc.{market}.{type}"
+
+func init() {
+	viabtc.RegisterWatchSink("nats", New)
+}
+
+// Sink publishes the watch events as json messages.
+type Sink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// A compile time check to ensure Sink implements the viabtc.WatchSink
+// interface.
+var _ viabtc.WatchSink = (*Sink)(nil)
+
+// New connects to NATS using the "url" option of the spec, "subject" option
+// is the subject template.
+func New(spec *viabtc.SinkSpec) (viabtc.WatchSink, error) {
+	url := spec.Options["url"]
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	subject := spec.Options["subject"]
+	if subject == "" {
+		subject = defaultSubject
+	}
+
+	conn, err := nats.Connect(url, nats.Name(spec.Name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to nats: %v", err)
+	}
+
+	return &Sink{
+		conn:    conn,
+		subject: subject,
+	}, nil
+}
+
+// Emit publishes the event.
+func (s *Sink) Emit(event *viabtc.WatchEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	subject := strings.NewReplacer(
+		"{market}", event.Market,
+		"{type}", string(event.Type),
+	).Replace(s.subject)
+
+	return s.conn.Publish(subject, data)
+}
+
+// Close flushes the pending messages and closes the connection.
+func (s *Sink) Close() error {
+	err := s.conn.Flush()
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,127 @@
+// Package oteltrace creates the OpenTelemetry spans of the rpc calls of the
+// ViaBTC client and propagates the trace context to the engine side in the
+// headers of the http requests, so that the exchange latency might be
+// correlated with the upstream services. Tracer is the interceptor of the
+// client:
+//
+//	tracer := oteltrace.NewTracer(&oteltrace.Config{})
+//
+//	client := viabtc.NewClient(&viabtc.Config{
+//		Interceptors: []viabtc.Interceptor{tracer.Interceptor},
+//	})
+package oteltrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer.
+const instrumentationName = "github.com/bitlum/viabtc_rpc_client/oteltrace"
+
+// Config is an structure which holds configurable parameters of the
+// tracer.
+type Config struct {
+	// TracerProvider is the provider of the tracer, the global one is
+	// used if not specified.
+	TracerProvider trace.TracerProvider
+
+	// Propagator is used to inject the trace context into the headers of
+	// the requests, the global one is used if not specified.
+	Propagator propagation.TextMapPropagator
+
+	// Attributes is added to all spans, e.g. the name of the engine
+	// instance.
+	Attributes []attribute.KeyValue
+}
+
+// Tracer creates the span of every rpc call.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	attributes []attribute.KeyValue
+}
+
+// NewTracer creates new tracer of the rpc calls.
+func NewTracer(cfg *Config) *Tracer {
+	provider := cfg.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	propagator := cfg.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+
+	return &Tracer{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagator,
+		attributes: cfg.Attributes,
+	}
+}
+
+// Interceptor wraps the rpc call into the client span, it should be added
+// to the interceptors of the client.
+func (t *Tracer) Interceptor(ctx context.Context, method string,
+	params interface{}, rpcResp interface{}, invoker viabtc.Invoker) error {
+
+	attrs := append([]attribute.KeyValue{
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method),
+	}, t.attributes...)
+
+	ctx, span := t.tracer.Start(ctx, "viabtc "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	header := http.Header{}
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	for key, values := range header {
+		for _, value := range values {
+			ctx = viabtc.WithRequestHeader(ctx, key, value)
+		}
+	}
+
+	ctx, info := viabtc.WithCallInfo(ctx)
+	err := invoker(ctx, method, params, rpcResp)
+
+	span.SetAttributes(
+		attribute.Int64("rpc.jsonrpc.request_id", int64(info.RequestID)),
+		attribute.Int("viabtc.attempts", info.Attempts),
+		attribute.Int("viabtc.request_size", info.RequestSize),
+		attribute.Int("viabtc.response_size", info.ResponseSize),
+	)
+
+	switch {
+	case err != nil:
+		var httpErr *viabtc.HTTPError
+		if errors.As(err, &httpErr) {
+			span.SetAttributes(attribute.Int("http.response.status_code",
+				httpErr.StatusCode))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+	case info.EngineError != nil:
+		span.SetAttributes(
+			attribute.Int("rpc.jsonrpc.error_code",
+				int(info.EngineError.Code)),
+			attribute.String("rpc.jsonrpc.error_message",
+				info.EngineError.Message),
+		)
+		span.SetStatus(codes.Error, info.EngineError.Message)
+	}
+
+	return err
+}
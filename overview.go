@@ -0,0 +1,107 @@
+package viabtc
+
+import "fmt"
+
+// overviewDealsLimit is the number of the recent deals included in the
+// market overview.
+const overviewDealsLimit int32 = 20
+
+// MarketOverviewResponse is the consolidated state of the market which is
+// needed by most of the user interfaces and monitoring pages.
+type MarketOverviewResponse struct {
+	Market MarketType `json:"market"`
+
+	// Last is the price of the last deal.
+	Last string `json:"last"`
+
+	// Today is the statistic of the market for the current day.
+	Today *MarketStatusTodayResponse `json:"today"`
+
+	// BestBid and BestAsk are the top levels of the order book, nil if
+	// the side of the book is empty.
+	BestBid *Depth `json:"best_bid"`
+	BestAsk *Depth `json:"best_ask"`
+
+	// Deals is the recent deals of the market, from newest to oldest.
+	Deals MarketDealsResponse `json:"deals"`
+}
+
+// MarketOverview concurrently fetches the last price, today's status, the
+// best bid and ask and the recent deals of the market, and returns them as
+// the single structure.
+func (e *Client) MarketOverview(market MarketType) (*MarketOverviewResponse,
+	error) {
+
+	overview := &MarketOverviewResponse{
+		Market: market,
+	}
+
+	fetchers := []func() error{
+		func() error {
+			last, err := e.MarketLast(&MarketLastRequest{
+				Market: market.String(),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to fetch last price: %w", err)
+			}
+
+			if last != nil {
+				overview.Last = *last
+			}
+			return nil
+		},
+		func() error {
+			today, err := e.MarketStatusToday(&MarketStatusTodayRequest{
+				Market: market.String(),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to fetch today's status: %w",
+					err)
+			}
+
+			overview.Today = today
+			return nil
+		},
+		func() error {
+			depth, err := e.OrderDepth(&OrderDepthRequest{
+				Market:   market.String(),
+				Limit:    1,
+				Interval: DefaultDepthInterval,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to fetch depth: %w", err)
+			}
+
+			if len(depth.Bids) != 0 {
+				overview.BestBid = &depth.Bids[0]
+			}
+			if len(depth.Asks) != 0 {
+				overview.BestAsk = &depth.Asks[0]
+			}
+			return nil
+		},
+		func() error {
+			deals, err := e.MarketDeals(&MarketDealsRequest{
+				Market: market.String(),
+				Limit:  overviewDealsLimit,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to fetch deals: %w", err)
+			}
+
+			overview.Deals = deals
+			return nil
+		},
+	}
+
+	// Every fetcher sets its own field, so no synchronisation is needed.
+	err := e.fanOut(len(fetchers), func(i int) error {
+		return fetchers[i]()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch overview of %v: %w",
+			market, err)
+	}
+
+	return overview, nil
+}
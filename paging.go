@@ -0,0 +1,33 @@
+package viabtc
+
+import "fmt"
+
+// checkPagination validates the offset and limit of paginated request
+// against the maximums of the engine. If client is configured to clamp the
+// pagination parameters, they are adjusted in place instead of returning
+// the error.
+func (e *Client) checkPagination(method string, offset, limit *int32) error {
+	if e.cfg.ClampPagination {
+		if *offset < 0 {
+			*offset = 0
+		}
+
+		if *limit <= 0 || *limit > MaxLimit {
+			*limit = MaxLimit
+		}
+
+		return nil
+	}
+
+	if *offset < 0 {
+		return fmt.Errorf("%v: offset should be non-negative, got %v",
+			method, *offset)
+	}
+
+	if *limit <= 0 || *limit > MaxLimit {
+		return fmt.Errorf("%v: limit should be within [1, %v], got %v",
+			method, MaxLimit, *limit)
+	}
+
+	return nil
+}
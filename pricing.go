@@ -0,0 +1,158 @@
+package viabtc
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// priceOutputPrec is the number of decimal places of the calculated prices.
+const priceOutputPrec = 12
+
+// PriceSource provides the price of the market which is used by conversion
+// and valuation helpers, so that different conventions, such as last trade
+// or mid price, might be used over the same client. Price is expressed in
+// market money for one stock.
+type PriceSource interface {
+	Price(market MarketType) (string, error)
+}
+
+// LastPriceSource uses the price of the last trade on the market.
+type LastPriceSource struct {
+	client *Client
+}
+
+// A compile time check to ensure LastPriceSource implements the PriceSource
+// interface.
+var _ PriceSource = (*LastPriceSource)(nil)
+
+// NewLastPriceSource creates new last trade price source.
+func NewLastPriceSource(client *Client) *LastPriceSource {
+	return &LastPriceSource{client: client}
+}
+
+// Price returns the price of the last trade on the market.
+func (s *LastPriceSource) Price(market MarketType) (string, error) {
+	last, err := s.client.MarketLast(&MarketLastRequest{
+		Market: market.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if last == nil {
+		return "", fmt.Errorf("market %v has no last price", market)
+	}
+
+	return *last, nil
+}
+
+// MidPriceSource uses the middle of the best bid and best ask of the market.
+type MidPriceSource struct {
+	client *Client
+}
+
+// A compile time check to ensure MidPriceSource implements the PriceSource
+// interface.
+var _ PriceSource = (*MidPriceSource)(nil)
+
+// NewMidPriceSource creates new mid price source.
+func NewMidPriceSource(client *Client) *MidPriceSource {
+	return &MidPriceSource{client: client}
+}
+
+// Price returns the middle of the best bid and best ask of the market.
+func (s *MidPriceSource) Price(market MarketType) (string, error) {
+	depth, err := s.client.OrderDepth(&OrderDepthRequest{
+		Market:   market.String(),
+		Limit:    1,
+		Interval: "0",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(depth.Asks) == 0 || len(depth.Bids) == 0 {
+		return "", fmt.Errorf("market %v has no bids or asks to "+
+			"calculate mid price", market)
+	}
+
+	ask, err := parseDecimal(depth.Asks[0].Price)
+	if err != nil {
+		return "", err
+	}
+
+	bid, err := parseDecimal(depth.Bids[0].Price)
+	if err != nil {
+		return "", err
+	}
+
+	mid := new(big.Rat).Add(ask, bid)
+	mid.Quo(mid, big.NewRat(2, 1))
+	return mid.FloatString(priceOutputPrec), nil
+}
+
+// VWAPPriceSource uses the volume weighted average price of the deals which
+// occurred on the market within the time window.
+//
+// NOTE: Engine returns only the latest deals, so only the last MaxLimit
+// deals of the market are taken into account.
+type VWAPPriceSource struct {
+	client *Client
+	window time.Duration
+}
+
+// A compile time check to ensure VWAPPriceSource implements the PriceSource
+// interface.
+var _ PriceSource = (*VWAPPriceSource)(nil)
+
+// NewVWAPPriceSource creates new volume weighted average price source over
+// the given time window.
+func NewVWAPPriceSource(client *Client, window time.Duration) *VWAPPriceSource {
+	return &VWAPPriceSource{
+		client: client,
+		window: window,
+	}
+}
+
+// Price returns the volume weighted average price of the deals within the
+// time window.
+func (s *VWAPPriceSource) Price(market MarketType) (string, error) {
+	deals, err := s.client.MarketDeals(&MarketDealsRequest{
+		Market: market.String(),
+		Limit:  MaxLimit,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	since := float64(s.client.now().Add(-s.window).Unix())
+	notional := new(big.Rat)
+	volume := new(big.Rat)
+
+	for _, deal := range deals {
+		if deal.Time < since {
+			continue
+		}
+
+		price, err := parseDecimal(deal.Price)
+		if err != nil {
+			return "", err
+		}
+
+		amount, err := parseDecimal(deal.Amount)
+		if err != nil {
+			return "", err
+		}
+
+		notional.Add(notional, new(big.Rat).Mul(price, amount))
+		volume.Add(volume, amount)
+	}
+
+	if volume.Sign() == 0 {
+		return "", fmt.Errorf("market %v has no deals within last %v",
+			market, s.window)
+	}
+
+	return notional.Quo(notional, volume).FloatString(priceOutputPrec), nil
+}
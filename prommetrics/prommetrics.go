@@ -0,0 +1,220 @@
+// Package prommetrics exports the metrics of the rpc calls of the ViaBTC
+// client to Prometheus. Collector is the interceptor of the client, so it
+// should be added to the client config and registered in the registry:
+//
+//	collector := prommetrics.NewCollector(&prommetrics.Config{})
+//	prometheus.MustRegister(collector)
+//
+//	client := viabtc.NewClient(&viabtc.Config{
+//		Interceptors: []viabtc.Interceptor{collector.Interceptor},
+//	})
+package prommetrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultNamespace is the namespace of the metrics if not specified.
+const defaultNamespace = "viabtc"
+
+// Config is an structure which holds configurable parameters of the
+// collector.
+type Config struct {
+	// Namespace is the namespace of the metrics.
+	Namespace string
+
+	// ConstLabels is the labels added to all metrics, e.g. the name of
+	// the engine instance.
+	ConstLabels prometheus.Labels
+
+	// LatencyBuckets is the buckets of the latency histogram in seconds,
+	// prometheus.DefBuckets if not specified.
+	LatencyBuckets []float64
+
+	// SizeBuckets is the buckets of the payload size histograms in bytes.
+	SizeBuckets []float64
+}
+
+// Collector collects the request count, the error count by code, the
+// latency and the payload sizes of the rpc calls per method.
+type Collector struct {
+	requests      *prometheus.CounterVec
+	errors        *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	requestSizes  *prometheus.HistogramVec
+	responseSizes *prometheus.HistogramVec
+}
+
+// A compile time check to ensure Collector implements the
+// prometheus.Collector interface.
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector creates new collector of the rpc metrics.
+func NewCollector(cfg *Config) *Collector {
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	latencyBuckets := cfg.LatencyBuckets
+	if len(latencyBuckets) == 0 {
+		latencyBuckets = prometheus.DefBuckets
+	}
+
+	sizeBuckets := cfg.SizeBuckets
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "requests_total",
+			Help:        "Number of rpc calls.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"method"}),
+
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "errors_total",
+			Help:        "Number of failed rpc calls by error code.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"method", "code"}),
+
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "retries_total",
+			Help:        "Number of repeated attempts of rpc calls.",
+			ConstLabels: cfg.ConstLabels,
+		}, []string{"method"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "duration_seconds",
+			Help:        "Duration of rpc calls, including retries.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     latencyBuckets,
+		}, []string{"method"}),
+
+		requestSizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "request_size_bytes",
+			Help:        "Size of rpc request bodies.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     sizeBuckets,
+		}, []string{"method"}),
+
+		responseSizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "rpc",
+			Name:        "response_size_bytes",
+			Help:        "Size of rpc response bodies.",
+			ConstLabels: cfg.ConstLabels,
+			Buckets:     sizeBuckets,
+		}, []string{"method"}),
+	}
+}
+
+// errorCode returns the label of the error of the call, empty if the call
+// has succeeded.
+func errorCode(err error, info *viabtc.CallInfo) string {
+	if err == nil {
+		if info.EngineError != nil {
+			return strconv.Itoa(int(info.EngineError.Code))
+		}
+		return ""
+	}
+
+	var httpErr *viabtc.HTTPError
+	if errors.As(err, &httpErr) {
+		return "http_" + strconv.Itoa(httpErr.StatusCode)
+	}
+
+	var decodeErr *viabtc.DecodeError
+	if errors.As(err, &decodeErr) {
+		return "decode"
+	}
+
+	switch {
+	case errors.Is(err, viabtc.ErrBreakerOpen):
+		return "breaker_open"
+
+	case errors.Is(err, viabtc.ErrRateLimited):
+		return "rate_limited"
+
+	case errors.Is(err, viabtc.ErrResponseTooLarge):
+		return "response_too_large"
+
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+
+	return "transport"
+}
+
+// Interceptor observes the rpc call, it should be added to the
+// interceptors of the client.
+func (c *Collector) Interceptor(ctx context.Context, method string,
+	params interface{}, rpcResp interface{}, invoker viabtc.Invoker) error {
+
+	ctx, info := viabtc.WithCallInfo(ctx)
+
+	start := time.Now()
+	err := invoker(ctx, method, params, rpcResp)
+
+	c.requests.WithLabelValues(method).Inc()
+	c.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	if code := errorCode(err, info); code != "" {
+		c.errors.WithLabelValues(method, code).Inc()
+	}
+
+	if info.Attempts > 1 {
+		c.retries.WithLabelValues(method).Add(float64(info.Attempts - 1))
+	}
+
+	if info.RequestSize > 0 {
+		c.requestSizes.WithLabelValues(method).Observe(
+			float64(info.RequestSize))
+	}
+
+	if info.ResponseSize > 0 {
+		c.responseSizes.WithLabelValues(method).Observe(
+			float64(info.ResponseSize))
+	}
+
+	return err
+}
+
+// Describe sends the descriptors of the metrics.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.errors.Describe(ch)
+	c.retries.Describe(ch)
+	c.latency.Describe(ch)
+	c.requestSizes.Describe(ch)
+	c.responseSizes.Describe(ch)
+}
+
+// Collect sends the current values of the metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.errors.Collect(ch)
+	c.retries.Collect(ch)
+	c.latency.Collect(ch)
+	c.requestSizes.Collect(ch)
+	c.responseSizes.Collect(ch)
+}
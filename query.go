@@ -0,0 +1,432 @@
+package viabtc
+
+import "time"
+
+// unixTime converts the time into the format used by the engine, zero time
+// is converted into zero, which means that the bound isn't specified.
+func unixTime(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// pageSize returns the page size which is actually requested by the
+// iterator, so that the short page is detected against it.
+func pageSize(limit int32) int32 {
+	if limit <= 0 || limit > MaxLimit {
+		return MaxLimit
+	}
+
+	return limit
+}
+
+// lastPage returns true if the page of the given length is the last one,
+// i.e. it is empty or shorter than the limit. The limit returned by the
+// engine is preferred over the requested one.
+func lastPage(n int, reqLimit, respLimit int32) bool {
+	limit := reqLimit
+	if respLimit > 0 {
+		limit = respLimit
+	}
+
+	return n == 0 || int32(n) < limit
+}
+
+// FinishedOrdersQuery is used to construct the request of user's finished
+// orders, for example:
+//
+//	it := NewFinishedOrdersQuery(1).Market(MarketBTCETH).
+//		Since(time.Now().Add(-time.Hour)).PageSize(50).Iter(client)
+type FinishedOrdersQuery struct {
+	req OrderFinishedRequest
+}
+
+// NewFinishedOrdersQuery starts building the query of finished orders of the
+// user.
+func NewFinishedOrdersQuery(userID uint32) *FinishedOrdersQuery {
+	return &FinishedOrdersQuery{
+		req: OrderFinishedRequest{
+			UserID: userID,
+			Limit:  MaxLimit,
+		},
+	}
+}
+
+// Market sets the market of the orders.
+func (q *FinishedOrdersQuery) Market(market MarketType) *FinishedOrdersQuery {
+	q.req.Market = market.String()
+	return q
+}
+
+// Side filters the orders by side, zero side means any.
+func (q *FinishedOrdersQuery) Side(side MarketOrderSide) *FinishedOrdersQuery {
+	q.req.Side = side
+	return q
+}
+
+// Since sets the start of the time range.
+func (q *FinishedOrdersQuery) Since(t time.Time) *FinishedOrdersQuery {
+	q.req.StartTime = unixTime(t)
+	return q
+}
+
+// Until sets the end of the time range.
+func (q *FinishedOrdersQuery) Until(t time.Time) *FinishedOrdersQuery {
+	q.req.EndTime = unixTime(t)
+	return q
+}
+
+// Offset sets the offset of the first page.
+func (q *FinishedOrdersQuery) Offset(offset int32) *FinishedOrdersQuery {
+	q.req.Offset = offset
+	return q
+}
+
+// PageSize sets the number of orders requested at once.
+func (q *FinishedOrdersQuery) PageSize(size int32) *FinishedOrdersQuery {
+	q.req.Limit = size
+	return q
+}
+
+// Request returns the request of the single page.
+func (q *FinishedOrdersQuery) Request() *OrderFinishedRequest {
+	req := q.req
+	return &req
+}
+
+// Iter returns the iterator which fetches all pages of the query.
+func (q *FinishedOrdersQuery) Iter(client *Client) *FinishedOrdersIterator {
+	it := &FinishedOrdersIterator{
+		client: client,
+		req:    q.req,
+	}
+	it.req.Limit = pageSize(it.req.Limit)
+
+	return it
+}
+
+// FinishedOrdersIterator iterates over the finished orders, fetching the
+// pages on demand:
+//
+//	for it.Next() {
+//		order := it.Order()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type FinishedOrdersIterator struct {
+	client *Client
+	req    OrderFinishedRequest
+
+	page []*OrderDetailedInfo
+	cur  *OrderDetailedInfo
+	done bool
+	err  error
+}
+
+// Next advances the iterator, it returns false when all orders were
+// iterated or error occurred.
+func (it *FinishedOrdersIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		resp, err := it.client.OrderFinished(&it.req)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if resp == nil {
+			it.done = true
+			continue
+		}
+
+		it.page = resp.Orders
+		it.req.Offset += int32(len(resp.Orders))
+		if lastPage(len(resp.Orders), it.req.Limit, resp.Limit) {
+			it.done = true
+		}
+	}
+
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Order returns the current order.
+func (it *FinishedOrdersIterator) Order() *OrderDetailedInfo {
+	return it.cur
+}
+
+// Err returns the error which stopped the iteration.
+func (it *FinishedOrdersIterator) Err() error {
+	return it.err
+}
+
+// UserDealsQuery is used to construct the request of user's deals. Engine
+// doesn't support time filtering of deals, so time range is applied by the
+// iterator on the client side.
+type UserDealsQuery struct {
+	req   MarketUserDealsRequest
+	since float64
+	until float64
+}
+
+// NewUserDealsQuery starts building the query of user's deals.
+func NewUserDealsQuery(userID uint32) *UserDealsQuery {
+	return &UserDealsQuery{
+		req: MarketUserDealsRequest{
+			UserID: userID,
+			Limit:  MaxLimit,
+		},
+	}
+}
+
+// Market sets the market of the deals.
+func (q *UserDealsQuery) Market(market MarketType) *UserDealsQuery {
+	q.req.Market = market.String()
+	return q
+}
+
+// Since skips the deals which occurred before the given time.
+func (q *UserDealsQuery) Since(t time.Time) *UserDealsQuery {
+	q.since = unixTime(t)
+	return q
+}
+
+// Until skips the deals which occurred after the given time.
+func (q *UserDealsQuery) Until(t time.Time) *UserDealsQuery {
+	q.until = unixTime(t)
+	return q
+}
+
+// Offset sets the offset of the first page.
+func (q *UserDealsQuery) Offset(offset int32) *UserDealsQuery {
+	q.req.Offset = offset
+	return q
+}
+
+// PageSize sets the number of deals requested at once.
+func (q *UserDealsQuery) PageSize(size int32) *UserDealsQuery {
+	q.req.Limit = size
+	return q
+}
+
+// Request returns the request of the single page, time range isn't
+// included in it.
+func (q *UserDealsQuery) Request() *MarketUserDealsRequest {
+	req := q.req
+	return &req
+}
+
+// Iter returns the iterator which fetches all pages of the query.
+func (q *UserDealsQuery) Iter(client *Client) *UserDealsIterator {
+	it := &UserDealsIterator{
+		client: client,
+		req:    q.req,
+		since:  q.since,
+		until:  q.until,
+		dedup:  NewDealDeduplicator(0),
+	}
+	it.req.Limit = pageSize(it.req.Limit)
+
+	return it
+}
+
+// UserDealsIterator iterates over the user's deals, fetching the pages on
+// demand. Deals are returned by engine from newest to oldest, so iteration
+// stops as soon as deal older than the start of time range is met. New
+// deals shift the offsets of the older ones, the deals repeated on the
+// next page because of that are skipped.
+type UserDealsIterator struct {
+	client *Client
+	req    MarketUserDealsRequest
+	since  float64
+	until  float64
+	dedup  *DealDeduplicator
+
+	page []DealDetail
+	cur  DealDetail
+	done bool
+	err  error
+}
+
+// Next advances the iterator, it returns false when all deals were iterated
+// or error occurred.
+func (it *UserDealsIterator) Next() bool {
+	for {
+		for len(it.page) == 0 {
+			if it.done || it.err != nil {
+				return false
+			}
+
+			resp, err := it.client.MarketUserDeals(&it.req)
+			if err != nil {
+				it.err = err
+				return false
+			}
+
+			if resp == nil {
+				it.done = true
+				continue
+			}
+
+			it.page = it.dedup.DealDetails(resp.Deals)
+			it.req.Offset += int32(len(resp.Deals))
+			if lastPage(len(resp.Deals), it.req.Limit, resp.Limit) {
+				it.done = true
+			}
+		}
+
+		deal := it.page[0]
+		it.page = it.page[1:]
+
+		if it.until != 0 && deal.Time > it.until {
+			continue
+		}
+
+		if it.since != 0 && deal.Time < it.since {
+			it.page = nil
+			it.done = true
+			return false
+		}
+
+		it.cur = deal
+		return true
+	}
+}
+
+// Deal returns the current deal.
+func (it *UserDealsIterator) Deal() DealDetail {
+	return it.cur
+}
+
+// Err returns the error which stopped the iteration.
+func (it *UserDealsIterator) Err() error {
+	return it.err
+}
+
+// BalanceHistoryQuery is used to construct the request of user's balance
+// history.
+type BalanceHistoryQuery struct {
+	req BalanceHistoryRequest
+}
+
+// NewBalanceHistoryQuery starts building the query of user's balance
+// history.
+func NewBalanceHistoryQuery(userID uint32) *BalanceHistoryQuery {
+	return &BalanceHistoryQuery{
+		req: BalanceHistoryRequest{
+			UserID: userID,
+			Limit:  MaxLimit,
+		},
+	}
+}
+
+// Asset filters the records by asset, empty asset means any.
+func (q *BalanceHistoryQuery) Asset(asset AssetType) *BalanceHistoryQuery {
+	q.req.Asset = asset
+	return q
+}
+
+// Action filters the records by the reason of balance change, empty action
+// means any.
+func (q *BalanceHistoryQuery) Action(action ActionType) *BalanceHistoryQuery {
+	q.req.ActionType = action
+	return q
+}
+
+// Since sets the start of the time range.
+func (q *BalanceHistoryQuery) Since(t time.Time) *BalanceHistoryQuery {
+	q.req.StartTime = unixTime(t)
+	return q
+}
+
+// Until sets the end of the time range.
+func (q *BalanceHistoryQuery) Until(t time.Time) *BalanceHistoryQuery {
+	q.req.EndTime = unixTime(t)
+	return q
+}
+
+// Offset sets the offset of the first page.
+func (q *BalanceHistoryQuery) Offset(offset int32) *BalanceHistoryQuery {
+	q.req.Offset = offset
+	return q
+}
+
+// PageSize sets the number of records requested at once.
+func (q *BalanceHistoryQuery) PageSize(size int32) *BalanceHistoryQuery {
+	q.req.Limit = size
+	return q
+}
+
+// Request returns the request of the single page.
+func (q *BalanceHistoryQuery) Request() *BalanceHistoryRequest {
+	req := q.req
+	return &req
+}
+
+// Iter returns the iterator which fetches all pages of the query.
+func (q *BalanceHistoryQuery) Iter(client *Client) *BalanceHistoryIterator {
+	it := &BalanceHistoryIterator{
+		client: client,
+		req:    q.req,
+	}
+	it.req.Limit = pageSize(it.req.Limit)
+
+	return it
+}
+
+// BalanceHistoryIterator iterates over the balance history records,
+// fetching the pages on demand.
+type BalanceHistoryIterator struct {
+	client *Client
+	req    BalanceHistoryRequest
+
+	page []*BalanceHistoryRecord
+	cur  *BalanceHistoryRecord
+	done bool
+	err  error
+}
+
+// Next advances the iterator, it returns false when all records were
+// iterated or error occurred.
+func (it *BalanceHistoryIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		resp, err := it.client.BalanceHistory(&it.req)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if resp == nil {
+			it.done = true
+			continue
+		}
+
+		it.page = resp.Records
+		it.req.Offset += int32(len(resp.Records))
+		if lastPage(len(resp.Records), it.req.Limit, resp.Limit) {
+			it.done = true
+		}
+	}
+
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Record returns the current record.
+func (it *BalanceHistoryIterator) Record() *BalanceHistoryRecord {
+	return it.cur
+}
+
+// Err returns the error which stopped the iteration.
+func (it *BalanceHistoryIterator) Err() error {
+	return it.err
+}
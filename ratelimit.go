@@ -0,0 +1,197 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of the requests made by the client. It might
+// be backed by the shared store, so that many instances of the service
+// collectively respect the global request budget toward the exchange.
+type RateLimiter interface {
+	// Wait blocks until the request of the method is allowed to be made,
+	// or returns error if context is done or limiter is unavailable.
+	Wait(ctx context.Context, method string) error
+}
+
+// ErrRateLimited is returned by the token bucket limiter which is
+// configured not to wait, if the request would exceed the rate.
+var ErrRateLimited = errors.New("request rate limit is exceeded")
+
+// TokenBucketRate is the rate of the token bucket.
+type TokenBucketRate struct {
+	// Rate is the number of requests per second, zero means unlimited.
+	Rate float64
+
+	// Burst is the number of requests which might be made at once, it is
+	// one if not specified.
+	Burst int
+}
+
+// TokenBucketConfig is an structure which holds configurable parameters of
+// the token bucket rate limiter.
+type TokenBucketConfig struct {
+	// Global is the rate of all requests of the client.
+	Global TokenBucketRate
+
+	// Methods is the rate of the requests of the single method, e.g. of
+	// the heavy "order.depth" or "market.kline", it is applied in
+	// addition to the global one.
+	Methods map[string]TokenBucketRate
+
+	// NoWait, if set, makes limiter to return ErrRateLimited instead of
+	// blocking until the request is allowed.
+	NoWait bool
+}
+
+// tokenBucket is the single bucket of the limiter.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate TokenBucketRate) *tokenBucket {
+	if rate.Rate <= 0 {
+		return nil
+	}
+
+	burst := float64(rate.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   rate.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// delay refills the bucket and returns the time until the token is
+// available.
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// TokenBucketLimiter is the in-process rate limiter which limits both the
+// overall rate of the requests and the rate of the particular methods.
+type TokenBucketLimiter struct {
+	noWait bool
+
+	mtx     sync.Mutex
+	global  *tokenBucket
+	methods map[string]*tokenBucket
+}
+
+// A compile time check to ensure TokenBucketLimiter implements the
+// RateLimiter interface.
+var _ RateLimiter = (*TokenBucketLimiter)(nil)
+
+// NewTokenBucketLimiter creates new token bucket rate limiter.
+func NewTokenBucketLimiter(cfg *TokenBucketConfig) (*TokenBucketLimiter,
+	error) {
+
+	if cfg.Global.Rate < 0 {
+		return nil, errors.New("global rate shouldn't be negative")
+	}
+
+	l := &TokenBucketLimiter{
+		noWait:  cfg.NoWait,
+		global:  newTokenBucket(cfg.Global),
+		methods: make(map[string]*tokenBucket, len(cfg.Methods)),
+	}
+
+	for method, rate := range cfg.Methods {
+		if rate.Rate < 0 {
+			return nil, fmt.Errorf("rate of %v shouldn't be negative",
+				method)
+		}
+
+		if b := newTokenBucket(rate); b != nil {
+			l.methods[method] = b
+		}
+	}
+
+	return l, nil
+}
+
+// Wait takes the tokens of the request from the global and method buckets,
+// blocking until they are available. Tokens are taken from both buckets at
+// once, so that the waiting request doesn't hold the tokens of the other
+// bucket.
+func (l *TokenBucketLimiter) Wait(ctx context.Context, method string) error {
+	var buckets []*tokenBucket
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if b, ok := l.methods[method]; ok {
+		buckets = append(buckets, b)
+	}
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	l.mtx.Lock()
+
+	var delay time.Duration
+	now := time.Now()
+	for _, b := range buckets {
+		if d := b.delay(now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 && l.noWait {
+		l.mtx.Unlock()
+		return ErrRateLimited
+	}
+
+	// Tokens are reserved in advance, the bucket goes into debt which is
+	// paid off by the refill during the delay.
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.mtx.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-ctx.Done():
+		// Request isn't made, so its tokens are returned.
+		l.mtx.Lock()
+		for _, b := range buckets {
+			b.tokens++
+			if b.tokens > b.burst {
+				b.tokens = b.burst
+			}
+		}
+		l.mtx.Unlock()
+
+		return ctx.Err()
+	}
+}
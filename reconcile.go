@@ -0,0 +1,308 @@
+package viabtc
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// DefaultReferenceKey is the key of the balance update detail which is
+// expected to hold the reference of the external transfer, if not
+// specified otherwise.
+const DefaultReferenceKey = "ref"
+
+// FundingTransfer is the deposit or withdrawal registered by the external
+// funding source, such as blockchain node or bank ledger.
+type FundingTransfer struct {
+	// Reference identifies the transfer within the source, e.g. transaction
+	// id. It should be stored in the detail of the corresponding balance
+	// update of the engine.
+	Reference string
+
+	UserID uint32
+	Asset  AssetType
+
+	// Action is either deposit or withdrawal.
+	Action ActionType
+
+	// Amount is the positive amount of the transfer.
+	Amount string
+
+	Time time.Time
+}
+
+// FundingSource is the external source of deposits and withdrawals which
+// should be reflected in the engine balances.
+type FundingSource interface {
+	// Name returns the name of the source used in reports.
+	Name() string
+
+	// Transfers returns the transfers of the asset made within the time
+	// range.
+	Transfers(asset AssetType, start, end time.Time) ([]FundingTransfer,
+		error)
+}
+
+// ReconcileIssueKind is the type of the discrepancy found by reconciliation.
+type ReconcileIssueKind string
+
+const (
+	// ReconcileMissing means that transfer of the source isn't reflected
+	// in the engine balance history.
+	ReconcileMissing ReconcileIssueKind = "missing"
+
+	// ReconcileUnexpected means that balance change of the engine doesn't
+	// correspond to any transfer of the source.
+	ReconcileUnexpected ReconcileIssueKind = "unexpected"
+
+	// ReconcileMismatch means that balance change of the engine differs
+	// from the transfer of the source with the same reference.
+	ReconcileMismatch ReconcileIssueKind = "mismatch"
+
+	// ReconcileDuplicate means that transfer of the source is reflected in
+	// the engine balance history more than once, e.g. deposit is credited
+	// twice.
+	ReconcileDuplicate ReconcileIssueKind = "duplicate"
+)
+
+// ReconcileIssue is the single discrepancy between the source and the
+// engine.
+type ReconcileIssue struct {
+	Kind      ReconcileIssueKind    `json:"kind"`
+	Reference string                `json:"reference"`
+	UserID    uint32                `json:"user_id"`
+	Transfer  *FundingTransfer      `json:"transfer,omitempty"`
+	Record    *BalanceHistoryRecord `json:"record,omitempty"`
+	Details   string                `json:"details"`
+}
+
+// ReconcileReport is the result of the reconciliation.
+type ReconcileReport struct {
+	Source  string           `json:"source"`
+	Asset   AssetType        `json:"asset"`
+	Matched int              `json:"matched"`
+	Issues  []ReconcileIssue `json:"issues"`
+}
+
+// ReconcileConfig is an structure which holds parameters of the
+// reconciliation.
+type ReconcileConfig struct {
+	Asset AssetType
+	Start time.Time
+	End   time.Time
+
+	// Users whose balance history is checked, in addition to the users
+	// found in the transfers of the source.
+	Users []uint32
+
+	// ReferenceKey is the key of the balance update detail which holds the
+	// reference of the external transfer.
+	ReferenceKey string
+}
+
+// fundingRecord is the engine balance change which corresponds to the
+// external transfer.
+type fundingRecord struct {
+	userID uint32
+	record *BalanceHistoryRecord
+}
+
+// fundingRecords returns the deposits and withdrawals of the user found in
+// the engine balance history.
+func (e *Client) fundingRecords(userID uint32, cfg *ReconcileConfig) (
+	[]fundingRecord, error) {
+
+	var records []fundingRecord
+	for _, action := range []ActionType{ActionDeposit, ActionWithdrawal} {
+		it := NewBalanceHistoryQuery(userID).Asset(cfg.Asset).Action(action).
+			Since(cfg.Start).Until(cfg.End).Iter(e)
+
+		for it.Next() {
+			records = append(records, fundingRecord{
+				userID: userID,
+				record: it.Record(),
+			})
+		}
+
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// Reconcile cross-checks the transfers of the external funding source with
+// the deposit and withdrawal records of the engine balance history, and
+// reports missing, unexpected, mismatched and duplicated entries. Entries
+// are matched by the reference stored in the balance update detail, every
+// engine record with the reference which is already matched is reported as
+// the duplicate.
+func Reconcile(client *Client, source FundingSource, cfg *ReconcileConfig) (
+	*ReconcileReport, error) {
+
+	key := cfg.ReferenceKey
+	if key == "" {
+		key = DefaultReferenceKey
+	}
+
+	transfers, err := source.Transfers(cfg.Asset, cfg.Start, cfg.End)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch transfers of %v: %w",
+			source.Name(), err)
+	}
+
+	users := make(map[uint32]struct{})
+	for _, userID := range cfg.Users {
+		users[userID] = struct{}{}
+	}
+	for _, transfer := range transfers {
+		users[transfer.UserID] = struct{}{}
+	}
+
+	// Engine might have several records with the same reference, they are
+	// kept in the order of the history, so that none of them is lost.
+	engine := make(map[string][]fundingRecord)
+	var unreferenced []fundingRecord
+	for userID := range users {
+		records, err := client.fundingRecords(userID, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch balance history of "+
+				"user %v: %w", userID, err)
+		}
+
+		for _, r := range records {
+			ref, ok := r.record.Detail[key]
+			if !ok {
+				unreferenced = append(unreferenced, r)
+				continue
+			}
+			reference := fmt.Sprint(ref)
+			engine[reference] = append(engine[reference], r)
+		}
+	}
+
+	report := &ReconcileReport{
+		Source: source.Name(),
+		Asset:  cfg.Asset,
+	}
+
+	matched := make(map[string]struct{})
+	for i := range transfers {
+		transfer := &transfers[i]
+
+		records := engine[transfer.Reference]
+		if len(records) == 0 {
+			report.Issues = append(report.Issues, ReconcileIssue{
+				Kind:      ReconcileMissing,
+				Reference: transfer.Reference,
+				UserID:    transfer.UserID,
+				Transfer:  transfer,
+				Details: fmt.Sprintf("%v of %v %v isn't found in the "+
+					"engine", transfer.Action, transfer.Amount,
+					transfer.Asset),
+			})
+			continue
+		}
+		r := records[0]
+		engine[transfer.Reference] = records[1:]
+		matched[transfer.Reference] = struct{}{}
+
+		if details, ok := compareTransfer(transfer, r); !ok {
+			report.Issues = append(report.Issues, ReconcileIssue{
+				Kind:      ReconcileMismatch,
+				Reference: transfer.Reference,
+				UserID:    transfer.UserID,
+				Transfer:  transfer,
+				Record:    r.record,
+				Details:   details,
+			})
+			continue
+		}
+
+		report.Matched++
+	}
+
+	for _, r := range unreferenced {
+		report.Issues = append(report.Issues, ReconcileIssue{
+			Kind:   ReconcileUnexpected,
+			UserID: r.userID,
+			Record: r.record,
+			Details: fmt.Sprintf("%v of %v %v has no %q reference in "+
+				"detail", r.record.ActionType, r.record.Change,
+				r.record.Asset, key),
+		})
+	}
+
+	for ref, records := range engine {
+		for _, r := range records {
+			if _, ok := matched[ref]; ok {
+				report.Issues = append(report.Issues, ReconcileIssue{
+					Kind:      ReconcileDuplicate,
+					Reference: ref,
+					UserID:    r.userID,
+					Record:    r.record,
+					Details: fmt.Sprintf("%v of %v %v duplicates the "+
+						"already matched transfer of %v",
+						r.record.ActionType, r.record.Change,
+						r.record.Asset, source.Name()),
+				})
+				continue
+			}
+
+			report.Issues = append(report.Issues, ReconcileIssue{
+				Kind:      ReconcileUnexpected,
+				Reference: ref,
+				UserID:    r.userID,
+				Record:    r.record,
+				Details: fmt.Sprintf("%v of %v %v doesn't correspond to "+
+					"any transfer of %v", r.record.ActionType,
+					r.record.Change, r.record.Asset, source.Name()),
+			})
+		}
+	}
+
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Reference < b.Reference
+	})
+
+	return report, nil
+}
+
+// compareTransfer checks that the engine record corresponds to the
+// transfer, and if not returns the description of the difference.
+func compareTransfer(transfer *FundingTransfer, r fundingRecord) (string,
+	bool) {
+
+	if r.userID != transfer.UserID {
+		return fmt.Sprintf("user differs: source %v, engine %v",
+			transfer.UserID, r.userID), false
+	}
+
+	if r.record.ActionType != transfer.Action {
+		return fmt.Sprintf("action differs: source %v, engine %v",
+			transfer.Action, r.record.ActionType), false
+	}
+
+	amount, err := parseDecimal(transfer.Amount)
+	if err != nil {
+		return fmt.Sprintf("wrong source amount %q", transfer.Amount), false
+	}
+
+	change, err := parseDecimal(r.record.Change)
+	if err != nil {
+		return fmt.Sprintf("wrong engine change %q", r.record.Change), false
+	}
+
+	if new(big.Rat).Abs(change).Cmp(new(big.Rat).Abs(amount)) != 0 {
+		return fmt.Sprintf("amount differs: source %v, engine %v",
+			transfer.Amount, r.record.Change), false
+	}
+
+	return "", true
+}
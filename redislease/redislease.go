@@ -0,0 +1,162 @@
+// Package redislease implements the leader lease of the ViaBTC client which
+// is held as the Redis key with expiration, so that only one instance of
+// the active-passive deployment is allowed to make mutating requests.
+package redislease
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/redis/go-redis/v9"
+)
+
+// renewScript prolongs the key if it is held by the candidate, or acquires
+// it if it is free. It returns one if key is held by the candidate after
+// the call.
+var renewScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not owner then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseScript deletes the key if it is held by the candidate.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Config is an structure which holds configurable parameters of the Redis
+// leader lease.
+type Config struct {
+	// Key is the election key, all candidates should use the same key.
+	Key string
+
+	// ID identifies the candidate, it is stored as the value of the key.
+	ID string
+
+	// TTL is the expiration of the key, it is renewed every third of it.
+	TTL time.Duration
+}
+
+// Lease campaigns for the leadership by holding the Redis key. The
+// leadership is considered lost if the key wasn't renewed within the
+// two-thirds of the time to live, so that the process stops mutating
+// before the key expires and other candidate acquires it.
+type Lease struct {
+	client redis.UniversalClient
+	cfg    Config
+
+	mtx     sync.Mutex
+	leader  bool
+	renewed time.Time
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure Lease implements the viabtc.LeaderLease
+// interface.
+var _ viabtc.LeaderLease = (*Lease)(nil)
+
+// New creates the Redis leader lease and starts campaigning.
+func New(client redis.UniversalClient, cfg *Config) (*Lease, error) {
+	c := *cfg
+
+	if c.Key == "" || c.ID == "" {
+		return nil, fmt.Errorf("election key and candidate id should be " +
+			"specified")
+	}
+
+	if c.TTL < 30*time.Millisecond {
+		return nil, fmt.Errorf("lease ttl is too small")
+	}
+
+	l := &Lease{
+		client: client,
+		cfg:    c,
+		quit:   make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.campaign()
+
+	return l, nil
+}
+
+// IsLeader returns true if the key is held and was renewed recently.
+func (l *Lease) IsLeader() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.leader && time.Since(l.renewed) < l.cfg.TTL*2/3
+}
+
+// Err returns the error of the last campaign attempt.
+func (l *Lease) Err() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.lastErr
+}
+
+// Close stops campaigning and releases the key, so that other candidate
+// doesn't need to wait for expiration.
+func (l *Lease) Close() error {
+	close(l.quit)
+	l.wg.Wait()
+
+	l.mtx.Lock()
+	l.leader = false
+	l.mtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.TTL/3)
+	defer cancel()
+
+	return releaseScript.Run(ctx, l.client, []string{l.cfg.Key},
+		l.cfg.ID).Err()
+}
+
+func (l *Lease) campaign() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.cfg.TTL/3)
+		held, err := renewScript.Run(ctx, l.client, []string{l.cfg.Key},
+			l.cfg.ID, l.cfg.TTL.Milliseconds()).Int()
+		cancel()
+
+		l.mtx.Lock()
+		l.lastErr = err
+		l.leader = err == nil && held == 1
+		if l.leader {
+			// Expiration is counted from the moment the request was
+			// sent, because the key might be set at any point of it.
+			l.renewed = start
+		}
+		l.mtx.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-l.quit:
+			return
+		}
+	}
+}
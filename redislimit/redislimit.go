@@ -0,0 +1,118 @@
+// Package redislimit implements the rate limiter of the ViaBTC client which
+// keeps the request budget in Redis, so that it is shared by all instances
+// of the service.
+package redislimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultKey is the redis key of the request counter if not specified
+	// otherwise.
+	defaultKey = "viabtc:ratelimit"
+
+	// minRetryDelay is the minimal delay before the next attempt, it is
+	// used if the window is about to expire.
+	minRetryDelay = time.Millisecond
+)
+
+// acquireScript increments the request counter of the current window, the
+// window is started by the first request. It returns zero if request is
+// allowed, or number of milliseconds until the window ends otherwise.
+var acquireScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if n > tonumber(ARGV[2]) then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl < 0 then
+		redis.call('PEXPIRE', KEYS[1], ARGV[1])
+		ttl = tonumber(ARGV[1])
+	end
+	return ttl
+end
+return 0
+`)
+
+// Config is an structure which holds configurable parameters of the Redis
+// rate limiter.
+type Config struct {
+	// Key is the redis key which holds the request counter, all instances
+	// which share the budget should use the same key.
+	Key string
+
+	// Limit is the number of requests which are allowed within interval.
+	Limit int
+
+	// Interval is the duration of the budget window.
+	Interval time.Duration
+}
+
+// Limiter is the fixed window rate limiter which keeps the request counter
+// in Redis.
+type Limiter struct {
+	client redis.UniversalClient
+	cfg    Config
+}
+
+// A compile time check to ensure Limiter implements the viabtc.RateLimiter
+// interface.
+var _ viabtc.RateLimiter = (*Limiter)(nil)
+
+// New creates new instance of Redis rate limiter.
+func New(client redis.UniversalClient, cfg *Config) (*Limiter, error) {
+	c := *cfg
+
+	if c.Key == "" {
+		c.Key = defaultKey
+	}
+
+	if c.Limit <= 0 {
+		return nil, fmt.Errorf("limit should be positive")
+	}
+
+	if c.Interval < time.Millisecond {
+		return nil, fmt.Errorf("interval should be at least one millisecond")
+	}
+
+	return &Limiter{
+		client: client,
+		cfg:    c,
+	}, nil
+}
+
+// Wait blocks until the request is allowed by the shared budget.
+func (l *Limiter) Wait(ctx context.Context, method string) error {
+	for {
+		wait, err := acquireScript.Run(ctx, l.client, []string{l.cfg.Key},
+			l.cfg.Interval.Milliseconds(), l.cfg.Limit).Int64()
+		if err != nil {
+			return fmt.Errorf("unable to acquire rate limit for %v: %v",
+				method, err)
+		}
+
+		if wait == 0 {
+			return nil
+		}
+
+		delay := time.Duration(wait) * time.Millisecond
+		if delay < minRetryDelay {
+			delay = minRetryDelay
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package viabtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry holds the metadata of markets and assets supported by the
+// engine, such as precisions and minimum amounts. It is loaded from
+// market.list and asset.list and might be refreshed periodically, so that
+// validation, normalization and symbol helpers use the same data.
+//
+// NOTE: Lookups on the registry which hasn't been loaded with Refresh or
+// Start report that nothing exists.
+type Registry struct {
+	client *Client
+
+	mtx     sync.RWMutex
+	markets map[string]MarketInfo
+	assets  map[AssetType]AssetInfo
+	updated time.Time
+	lastErr error
+
+	started bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewRegistry creates new registry backed by the given client.
+func NewRegistry(client *Client) *Registry {
+	return &Registry{
+		client:  client,
+		markets: make(map[string]MarketInfo),
+		assets:  make(map[AssetType]AssetInfo),
+	}
+}
+
+// Registry returns the metadata registry attached to the client.
+func (e *Client) Registry() *Registry {
+	return e.registry
+}
+
+// Refresh loads the markets and assets from the engine, replacing the
+// previously loaded data.
+func (r *Registry) Refresh() error {
+	return r.RefreshContext(context.Background())
+}
+
+// RefreshContext is the same as Refresh, but the requests are made with
+// the given context.
+func (r *Registry) RefreshContext(ctx context.Context) error {
+	marketList, err := r.client.MarketListContext(ctx, &MarketListRequest{})
+	if err != nil {
+		r.setErr(err)
+		return fmt.Errorf("unable to list markets: %w", err)
+	}
+
+	assetList, err := r.client.AssetListContext(ctx, &AssetListRequest{})
+	if err != nil {
+		r.setErr(err)
+		return fmt.Errorf("unable to list assets: %w", err)
+	}
+
+	markets := make(map[string]MarketInfo)
+	if marketList != nil {
+		for _, market := range *marketList {
+			markets[market.MarketName.String()] = market
+		}
+	}
+
+	assets := make(map[AssetType]AssetInfo)
+	if assetList != nil {
+		for _, asset := range *assetList {
+			assets[AssetType(asset.Name)] = asset
+		}
+	}
+
+	r.mtx.Lock()
+	r.markets = markets
+	r.assets = assets
+	r.updated = r.client.now()
+	r.lastErr = nil
+	r.mtx.Unlock()
+
+	return nil
+}
+
+func (r *Registry) setErr(err error) {
+	r.mtx.Lock()
+	r.lastErr = err
+	r.mtx.Unlock()
+}
+
+// Start loads the registry and refreshes it with the given interval until
+// Stop is called. Failed refreshes keep the previously loaded data, the
+// error is available with Err.
+func (r *Registry) Start(interval time.Duration) error {
+	r.mtx.Lock()
+	if r.started {
+		r.mtx.Unlock()
+		return errors.New("registry is already started")
+	}
+	r.started = true
+	r.quit = make(chan struct{})
+	r.mtx.Unlock()
+
+	if err := r.Refresh(); err != nil {
+		r.mtx.Lock()
+		r.started = false
+		r.mtx.Unlock()
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Refresh()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the periodic refresh of the registry.
+func (r *Registry) Stop() {
+	r.mtx.Lock()
+	if !r.started {
+		r.mtx.Unlock()
+		return
+	}
+	r.started = false
+	close(r.quit)
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+}
+
+// Updated returns the time of the last successful refresh.
+func (r *Registry) Updated() time.Time {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.updated
+}
+
+// Err returns the error of the last refresh, nil if it was successful.
+func (r *Registry) Err() error {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return r.lastErr
+}
+
+// Market returns the metadata of the market.
+func (r *Registry) Market(market MarketType) (MarketInfo, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	info, ok := r.markets[market.String()]
+	return info, ok
+}
+
+// MarketExists returns true if market is supported by the engine.
+func (r *Registry) MarketExists(market MarketType) bool {
+	_, ok := r.Market(market)
+	return ok
+}
+
+// Markets returns the metadata of all markets sorted by name.
+func (r *Registry) Markets() []MarketInfo {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	markets := make([]MarketInfo, 0, len(r.markets))
+	for _, market := range r.markets {
+		markets = append(markets, market)
+	}
+
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].MarketName.String() < markets[j].MarketName.String()
+	})
+
+	return markets
+}
+
+// Asset returns the metadata of the asset.
+func (r *Registry) Asset(asset AssetType) (AssetInfo, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	info, ok := r.assets[asset]
+	return info, ok
+}
+
+// AssetExists returns true if asset is supported by the engine.
+func (r *Registry) AssetExists(asset AssetType) bool {
+	_, ok := r.Asset(asset)
+	return ok
+}
+
+// AssetList returns the metadata of all assets sorted by name.
+func (r *Registry) AssetList() []AssetInfo {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	assets := make([]AssetInfo, 0, len(r.assets))
+	for _, asset := range r.assets {
+		assets = append(assets, asset)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+
+	return assets
+}
+
+// marketInfo returns the metadata of the market or descriptive error.
+func (r *Registry) marketInfo(market MarketType) (MarketInfo, error) {
+	info, ok := r.Market(market)
+	if !ok {
+		return MarketInfo{}, fmt.Errorf("market %v isn't supported by "+
+			"the engine", market)
+	}
+
+	return info, nil
+}
+
+// MinAmount returns the minimum amount of the order on the market.
+func (r *Registry) MinAmount(market MarketType) (string, error) {
+	info, err := r.marketInfo(market)
+	if err != nil {
+		return "", err
+	}
+
+	return info.MinAmount, nil
+}
+
+// Precisions returns the number of decimal places of stock, money and fee
+// used on the market.
+func (r *Registry) Precisions(market MarketType) (stock, money, fee int,
+	err error) {
+
+	info, err := r.marketInfo(market)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return info.StockPrec, info.MoneyPrec, info.FeePrec, nil
+}
+
+// BaseQuote returns the base (stock) and quote (money) assets of the
+// market, as they are reported by the engine.
+func (r *Registry) BaseQuote(market MarketType) (base, quote AssetType,
+	err error) {
+
+	info, err := r.marketInfo(market)
+	if err != nil {
+		return "", "", err
+	}
+
+	return info.Stock, info.Money, nil
+}
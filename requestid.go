@@ -0,0 +1,35 @@
+package viabtc
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// IDGenerator generates the ids of the json-rpc requests. Ids of the
+// requests which are in flight at the same time should be distinct, so that
+// the response might be matched with the request.
+type IDGenerator interface {
+	// NextID returns the id of the next request.
+	NextID() int32
+}
+
+// CounterIDGenerator is the default generator of the request ids, it
+// returns the monotonically increasing ids starting from one, and wraps
+// around after math.MaxInt32.
+type CounterIDGenerator struct {
+	last uint32
+}
+
+// A compile time check to ensure CounterIDGenerator implements the
+// IDGenerator interface.
+var _ IDGenerator = (*CounterIDGenerator)(nil)
+
+// NextID returns the id of the next request.
+func (g *CounterIDGenerator) NextID() int32 {
+	for {
+		id := int32(atomic.AddUint32(&g.last, 1) & math.MaxInt32)
+		if id != 0 {
+			return id
+		}
+	}
+}
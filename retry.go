@@ -0,0 +1,248 @@
+package viabtc
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is the number of attempts of the request if
+	// not specified in the retry policy.
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBackoff is the delay before the first repeated attempt
+	// if not specified in the retry policy.
+	DefaultRetryBackoff = 100 * time.Millisecond
+
+	// DefaultRetryMaxBackoff is the maximum delay between the attempts if
+	// not specified in the retry policy.
+	DefaultRetryMaxBackoff = 5 * time.Second
+
+	// defaultRetryMultiplier is the growth factor of the delay if not
+	// specified in the retry policy.
+	defaultRetryMultiplier = 2
+)
+
+// RetryPolicy describes how the requests which failed with transient
+// errors, such as transport errors, 5xx responses and engine overload, are
+// repeated. Known read-only methods are always repeated, other methods,
+// including the mutating ones, such as order placement, and the unknown
+// ones, are repeated only if they are marked as safe, either in the policy
+// or per call with WithRetrySafe.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of the request,
+	// including the first one.
+	MaxAttempts int
+
+	// Backoff is the delay before the first repeated attempt, it grows
+	// exponentially with the multiplier up to the max backoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+
+	// Jitter is the part of the delay, from 0 to 1, which is randomized,
+	// so that clients don't repeat the requests simultaneously.
+	Jitter float64
+
+	// SafeMethods is the methods which aren't known to be read-only, but
+	// which are safe to repeat, e.g. "order.cancel" or "balance.update",
+	// which is deduplicated by the engine using the action id.
+	SafeMethods []string
+
+	// Budget, if specified, bounds the total time of the call across all
+	// attempts and delays between them, while the request timeout of the
+	// config bounds the single attempt. Attempt isn't repeated if the
+	// delay before it doesn't fit into the rest of the budget. Budget
+	// might be overridden per call with WithCallBudget.
+	Budget time.Duration
+}
+
+// readOnlyMethods is the methods which don't change the state of the
+// engine, and which are safe to repeat.
+var readOnlyMethods = map[string]struct{}{
+	"asset.list":            {},
+	"asset.summary":         {},
+	"balance.query":         {},
+	"balance.history":       {},
+	"order.book":            {},
+	"order.depth":           {},
+	"order.deals":           {},
+	"order.pending":         {},
+	"order.pending_detail":  {},
+	"order.pending_stop":    {},
+	"order.finished":        {},
+	"order.finished_detail": {},
+	"order.finished_stop":   {},
+	"market.list":           {},
+	"market.summary":        {},
+	"market.last":           {},
+	"market.deals":          {},
+	"market.deals_ext":      {},
+	"market.user_deals":     {},
+	"market.kline":          {},
+	"market.status":         {},
+	"market.status_today":   {},
+}
+
+// retrier repeats the requests according to the retry policy.
+type retrier struct {
+	policy RetryPolicy
+	safe   map[string]struct{}
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+func newRetrier(policy *RetryPolicy) *retrier {
+	r := &retrier{
+		policy: *policy,
+		safe:   make(map[string]struct{}, len(policy.SafeMethods)),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if r.policy.MaxAttempts <= 0 {
+		r.policy.MaxAttempts = DefaultRetryAttempts
+	}
+	if r.policy.Backoff <= 0 {
+		r.policy.Backoff = DefaultRetryBackoff
+	}
+	if r.policy.MaxBackoff <= 0 {
+		r.policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if r.policy.Multiplier < 1 {
+		r.policy.Multiplier = defaultRetryMultiplier
+	}
+
+	for _, method := range policy.SafeMethods {
+		r.safe[method] = struct{}{}
+	}
+
+	return r
+}
+
+type retrySafeKey struct{}
+
+// WithRetrySafe marks the request made with the context as safe to repeat,
+// so that it is repeated by the retry policy even if it is mutating.
+func WithRetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+type callBudgetKey struct{}
+
+// WithCallBudget returns the context which bounds the total time of the
+// call made with it across all attempts, overriding the budget of the
+// retry policy.
+func WithCallBudget(ctx context.Context, budget time.Duration) context.Context {
+	return context.WithValue(ctx, callBudgetKey{}, budget)
+}
+
+// budget returns the total time budget of the call, zero if it isn't
+// bounded.
+func (r *retrier) budget(ctx context.Context) time.Duration {
+	if budget, ok := ctx.Value(callBudgetKey{}).(time.Duration); ok {
+		return budget
+	}
+
+	if r == nil {
+		return 0
+	}
+
+	return r.policy.Budget
+}
+
+// attempts returns the number of attempts of the request.
+func (r *retrier) attempts(ctx context.Context, method string) int {
+	if r == nil {
+		return 1
+	}
+
+	if _, ok := readOnlyMethods[method]; ok {
+		return r.policy.MaxAttempts
+	}
+
+	if m, ok := lookupCustomMethod(method); ok && (m.readOnly || m.idempotent) {
+		return r.policy.MaxAttempts
+	}
+
+	if _, ok := r.safe[method]; ok || ctx.Value(retrySafeKey{}) != nil {
+		return r.policy.MaxAttempts
+	}
+
+	return 1
+}
+
+// delay returns the delay before the repeated attempt, attempt is the
+// number of the made attempts.
+func (r *retrier) delay(attempt int) time.Duration {
+	delay := float64(r.policy.Backoff)
+	for i := 1; i < attempt; i++ {
+		delay *= r.policy.Multiplier
+		if delay >= float64(r.policy.MaxBackoff) {
+			break
+		}
+	}
+
+	if delay > float64(r.policy.MaxBackoff) {
+		delay = float64(r.policy.MaxBackoff)
+	}
+
+	if r.policy.Jitter > 0 {
+		r.mtx.Lock()
+		delay -= delay * r.policy.Jitter * r.rand.Float64()
+		r.mtx.Unlock()
+	}
+
+	return time.Duration(delay)
+}
+
+// wait sleeps before the repeated attempt, false is returned if the
+// context is done or client is closed earlier, or if the delay exceeds the
+// deadline of the context.
+func (r *retrier) wait(ctx context.Context, quit <-chan struct{},
+	attempt int) bool {
+
+	delay := r.delay(attempt)
+
+	// Attempt which would start after the deadline would fail anyway.
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-quit:
+		return false
+	}
+}
+
+// retryable returns true if the request failed with the transient error,
+// either of the transport or of the engine.
+func retryable(err error, rpcResp interface{}) bool {
+	if err != nil {
+		return isTransient(err)
+	}
+
+	if r, ok := rpcResp.(engineErrorer); ok && r.engineError() != nil {
+		return isTransient(r.engineError())
+	}
+
+	return false
+}
+
+// resetResponse clears the response decoded by the failed attempt.
+func resetResponse(rpcResp interface{}) {
+	v := reflect.ValueOf(rpcResp)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
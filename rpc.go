@@ -8,8 +8,8 @@ import (
 )
 
 type baseResponse struct {
-	Error *Error `json:"error"`
-	ID    int32  `json:"id"`
+	Error *RPCError `json:"error"`
+	ID    int32     `json:"id"`
 }
 
 type request struct {
@@ -110,6 +110,9 @@ type MarketType struct {
 }
 
 // TODO(andrew.shvv) make it better
+//
+// NewMarket panics if the name is shorter than three letters, ParseMarket
+// should be used for the names which aren't known to be valid.
 func NewMarket(market string) MarketType {
 	return MarketType{
 		Stock: AssetType(market[3:]),
@@ -117,6 +120,19 @@ func NewMarket(market string) MarketType {
 	}
 }
 
+// ParseMarket parses the name of the market, which is the money asset of
+// three letters followed by the stock asset. Error is returned if the name
+// doesn't contain both assets.
+func ParseMarket(market string) (MarketType, error) {
+	if len(market) <= 3 {
+		return MarketType{}, fmt.Errorf("invalid market name %q, it "+
+			"should be money asset of three letters followed by the "+
+			"stock asset", market)
+	}
+
+	return NewMarket(market), nil
+}
+
 func (t MarketType) String() string {
 	return fmt.Sprintf("%v%v", t.Money, t.Stock)
 }
@@ -127,7 +143,17 @@ func (t *MarketType) UnmarshalJSON(data []byte) (err error) {
 		return err
 	}
 
-	market := NewMarket(s)
+	// Market is omitted in some responses.
+	if s == "" {
+		*t = MarketType{}
+		return nil
+	}
+
+	market, err := ParseMarket(s)
+	if err != nil {
+		return err
+	}
+
 	*t = market
 	return nil
 }
@@ -272,12 +298,13 @@ type UnixTime time.Time
 // DealDetail represent the detailed information about the deal. Deal is an
 // result of execution of two orders.
 type DealDetail struct {
-	DealID int32        `json:"id"`
-	Time   float64      `json:"time"`
-	Role   ExchangeRole `json:"role"`
-	Amount string       `json:"amount"`
-	UserID uint32       `json:"user"`
-	Fee    string       `json:"fee"`
+	DealID int32           `json:"id"`
+	Time   float64         `json:"time"`
+	Side   MarketOrderSide `json:"side"`
+	Role   ExchangeRole    `json:"role"`
+	Amount string          `json:"amount"`
+	UserID uint32          `json:"user"`
+	Fee    string          `json:"fee"`
 
 	// Price corresponds to deal price, if this it the limit order than
 	// the price should be the same for all orders, if it it market order
@@ -325,10 +352,10 @@ type OrderDetailedInfo struct {
 	// MTime is the time when order have been updated last time. By
 	// update we mean that it has been matched with another order and its left
 	// amount has been changed.
-	MTime float64 `json:"mtime, omitempty"`
+	MTime float64 `json:"mtime,omitempty"`
 
 	// FTime is the time when order have been finished
-	FTime float64 `json:"ftime, omitempty"`
+	FTime float64 `json:"ftime,omitempty"`
 
 	// Left the amount of funds left in the market without being handled.
 	Left string `json:"left"`
@@ -405,13 +432,22 @@ type BalanceHistoryResponse struct {
 
 type AssetListRequest struct{}
 
-type AssetListResponse []struct {
+// AssetInfo is the information about asset supported by the engine.
+type AssetInfo struct {
 	Name string `json:"name"`
 
 	// Prec is the precious of calculation specified for this asset.
 	Prec float64 `json:"prec"`
+
+	// PrecSave and PrecShow are the number of decimal places with which
+	// the balances are stored and displayed. They are reported only by
+	// some builds of the engine, Prec is used instead if they aren't.
+	PrecSave float64 `json:"prec_save,omitempty"`
+	PrecShow float64 `json:"prec_show,omitempty"`
 }
 
+type AssetListResponse []AssetInfo
+
 type AssetSummaryRequest []AssetType
 
 type AssetSummaryResponse []struct {
@@ -490,6 +526,64 @@ type OrderPutMarketRequest struct {
 
 type OrderPutMarketResponse OrderDetailedInfo
 
+type OrderPutStopMarketRequest struct {
+	UserID uint32
+	Market string
+	Side   MarketOrderSide
+
+	// Amount depending on the side this either the number of stock which user
+	// wants to sell (ask) or money which user wants to spend (bid), the same
+	// as for the market order.
+	Amount string
+
+	// StopPrice is the last price of the market, expressed in market money,
+	// on reaching which the market order is placed.
+	StopPrice string
+
+	// TakerFeeRate is an coefficient from [0;1) which is used to determine
+	// the percentage of money which will be taken as a fee from total amount
+	// of the market order placed by the trigger.
+	TakerFeeRate string
+
+	// Source designate the origin of the order requests. It is needed to
+	// analyze statistics.
+	Source string
+}
+
+type OrderPutStopMarketResponse StopOrderInfo
+
+// StopOrderInfo represent the detailed information about the stop order,
+// which is waiting for the last price of the market to reach the stop price
+// to place the order.
+type StopOrderInfo struct {
+	OrderID      int32           `json:"id"`
+	UserID       uint32          `json:"user"`
+	Amount       string          `json:"amount"`
+	StopPrice    string          `json:"stop_price"`
+	Side         MarketOrderSide `json:"side"`
+	Type         OrderType       `json:"type"`
+	Market       MarketType      `json:"market"`
+	Source       string          `json:"source"`
+	TakerFeeRate string          `json:"taker_fee"`
+
+	// Price is the price of the limit order placed by the trigger, it is
+	// empty for the stop market order.
+	Price string `json:"price,omitempty"`
+
+	// MakerFeeRate is the maker fee of the limit order placed by the
+	// trigger, it is empty for the stop market order.
+	MakerFeeRate string `json:"maker_fee,omitempty"`
+
+	// CTime is the time of stop order creation.
+	CTime float64 `json:"ctime"`
+
+	// MTime is the time when stop order have been updated last time.
+	MTime float64 `json:"mtime,omitempty"`
+
+	// FTime is the time when stop order have been triggered or canceled.
+	FTime float64 `json:"ftime,omitempty"`
+}
+
 type OrderCancelRequest struct {
 	UserID  uint32
 	Market  string
@@ -498,6 +592,15 @@ type OrderCancelRequest struct {
 
 type OrderCancelResponse OrderDetailedInfo
 
+type OrderCancelAllRequest struct {
+	UserID uint32
+	Market string
+}
+
+type OrderCancelAllResponse struct {
+	Status string `json:"status"`
+}
+
 type OrderDealsRequest struct {
 	OrderID int32
 	Offset  int32
@@ -584,10 +687,50 @@ type OrderFinishedDetailRequest struct {
 
 type OrderFinishedDetailResponse OrderDetailedInfo
 
+type OrderPendingStopRequest struct {
+	UserID uint32
+	Market string
+	Offset int32
+	Limit  int32
+}
+
+type OrderPendingStopResponse struct {
+	Offset int32            `json:"offset"`
+	Limit  int32            `json:"limit"`
+	Total  int32            `json:"total"`
+	Orders []*StopOrderInfo `json:"records"`
+}
+
+type OrderFinishedStopRequest struct {
+	UserID    uint32
+	Market    string
+	StartTime float64
+	EndTime   float64
+	Offset    int32
+	Limit     int32
+	Side      MarketOrderSide
+}
+
+type OrderFinishedStopResponse struct {
+	Offset int32            `json:"offset"`
+	Limit  int32            `json:"limit"`
+	Total  int32            `json:"total"`
+	Orders []*StopOrderInfo `json:"records"`
+}
+
+type OrderCancelStopRequest struct {
+	UserID  uint32
+	Market  string
+	OrderID int32
+}
+
+type OrderCancelStopResponse StopOrderInfo
+
 type MarketListRequest struct{}
 
-type MarketListResponse []struct {
-	Money      AssetType  `json:"name"`
+// MarketInfo is the information about market supported by the engine.
+type MarketInfo struct {
+	Money      AssetType  `json:"money"`
 	Stock      AssetType  `json:"stock"`
 	FeePrec    int        `json:"fee_prec"`
 	StockPrec  int        `json:"stock_prec"`
@@ -596,6 +739,8 @@ type MarketListResponse []struct {
 	MarketName MarketType `json:"name"`
 }
 
+type MarketListResponse []MarketInfo
+
 type MarketSummaryRequest []MarketType
 
 type MarketSummaryResponse []struct {
@@ -627,6 +772,105 @@ type MarketDealsResponse []struct {
 	Price  string  `json:"price"`
 }
 
+type MarketDealsExtRequest struct {
+	Market string
+	Limit  int32
+
+	// LastID is used to specify an id till which the deals will be
+	// filtered.
+	LastID int32
+}
+
+// MarketDealExt is the market deal with the information about both orders
+// which have been matched, as it is returned by the extended deals request.
+type MarketDealExt struct {
+	DealID int32   `json:"id"`
+	Time   float64 `json:"time"`
+	Amount string  `json:"amount"`
+	Price  string  `json:"price"`
+
+	// Type is the side of the taker, "buy" or "sell".
+	Type string `json:"type"`
+
+	// Deal is the amount of money which has been exchanged in the deal.
+	Deal string `json:"deal"`
+
+	AskUserID  uint32 `json:"ask_user_id"`
+	AskOrderID int32  `json:"ask_order_id"`
+	BidUserID  uint32 `json:"bid_user_id"`
+	BidOrderID int32  `json:"bid_order_id"`
+
+	// AskFee is the fee of the seller, which is taken in money, BidFee is
+	// the fee of the buyer, which is taken in stock.
+	AskFee string `json:"ask_fee"`
+	BidFee string `json:"bid_fee"`
+}
+
+// DealParty is the order of one side of the deal.
+type DealParty struct {
+	UserID  uint32
+	OrderID int32
+	Side    MarketOrderSide
+	Role    ExchangeRole
+
+	// Fee is the fee of the party, in money for the ask and in stock for
+	// the bid.
+	Fee string
+}
+
+// TakerSide returns the side of the order which has been matched with the
+// one in the order book.
+func (d *MarketDealExt) TakerSide() MarketOrderSide {
+	if d.Type == "buy" {
+		return MarketOrderSideBid
+	}
+
+	return MarketOrderSideAsk
+}
+
+// party returns the party of the deal of the given side.
+func (d *MarketDealExt) party(side MarketOrderSide) DealParty {
+	role := MakerRole
+	if side == d.TakerSide() {
+		role = TakerRole
+	}
+
+	if side == MarketOrderSideAsk {
+		return DealParty{
+			UserID:  d.AskUserID,
+			OrderID: d.AskOrderID,
+			Side:    side,
+			Role:    role,
+			Fee:     d.AskFee,
+		}
+	}
+
+	return DealParty{
+		UserID:  d.BidUserID,
+		OrderID: d.BidOrderID,
+		Side:    side,
+		Role:    role,
+		Fee:     d.BidFee,
+	}
+}
+
+// Maker returns the order of the deal which has been in the order book.
+func (d *MarketDealExt) Maker() DealParty {
+	if d.TakerSide() == MarketOrderSideBid {
+		return d.party(MarketOrderSideAsk)
+	}
+
+	return d.party(MarketOrderSideBid)
+}
+
+// Taker returns the order of the deal which has been matched with the
+// order book.
+func (d *MarketDealExt) Taker() DealParty {
+	return d.party(d.TakerSide())
+}
+
+type MarketDealsExtResponse []MarketDealExt
+
 type MarketUserDealsRequest struct {
 	UserID uint32
 	Market string
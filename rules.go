@@ -0,0 +1,927 @@
+package viabtc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RuleMetric is the value observed by the rule condition.
+type RuleMetric string
+
+const (
+	// RuleLast is the last price of the market.
+	RuleLast RuleMetric = "last"
+
+	// RuleBid is the best bid price of the market, zero if there are no
+	// bids.
+	RuleBid RuleMetric = "bid"
+
+	// RuleAsk is the best ask price of the market, zero if there are no
+	// asks.
+	RuleAsk RuleMetric = "ask"
+
+	// RuleSpread is the difference between the best ask and the best bid
+	// of the market, the condition isn't satisfied if either side of the
+	// book is empty.
+	RuleSpread RuleMetric = "spread"
+
+	// RuleBalance is the available balance of the user asset.
+	RuleBalance RuleMetric = "balance"
+
+	// RulePendingOrders is the number of the user pending orders on the
+	// market.
+	RulePendingOrders RuleMetric = "pending_orders"
+
+	// RuleOrderLeft is the amount left of the pending order, zero if the
+	// order isn't pending anymore.
+	RuleOrderLeft RuleMetric = "order_left"
+)
+
+// RuleActionType is the type of the reaction on the rule firing.
+type RuleActionType string
+
+const (
+	// RuleCancelAll cancels all pending orders of the user.
+	RuleCancelAll RuleActionType = "cancel_all"
+
+	// RulePlaceOrder places the limit order.
+	RulePlaceOrder RuleActionType = "place_order"
+
+	// RuleWebhook posts the firing as json to the url.
+	RuleWebhook RuleActionType = "webhook"
+
+	// RuleAlert delivers the firing to the alert handler of the engine.
+	RuleAlert RuleActionType = "alert"
+)
+
+const (
+	// defaultRuleInterval is the evaluation interval if not specified.
+	defaultRuleInterval = time.Second
+
+	// defaultWebhookTimeout is the timeout of the webhook request if http
+	// client isn't specified.
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// RuleCondition is the condition of the rule. It is either the combination
+// of the nested conditions, if All or Any is specified, or the comparison
+// of the metric with the value.
+type RuleCondition struct {
+	// All is satisfied if all nested conditions are satisfied.
+	All []RuleCondition `json:"all,omitempty"`
+
+	// Any is satisfied if any of the nested conditions is satisfied.
+	Any []RuleCondition `json:"any,omitempty"`
+
+	Metric RuleMetric `json:"metric,omitempty"`
+
+	// Market is the market of the price, spread and order metrics.
+	Market string `json:"market,omitempty"`
+
+	// Asset is the asset of the balance metric.
+	Asset AssetType `json:"asset,omitempty"`
+
+	// UserID is the user of the balance and order metrics.
+	UserID uint32 `json:"user_id,omitempty"`
+
+	// OrderID is the order of the order left metric.
+	OrderID int32 `json:"order_id,omitempty"`
+
+	// Op is the comparison operator, one of ">", ">=", "<", "<=", "==" and
+	// "!=".
+	Op string `json:"op,omitempty"`
+
+	// Value is the decimal number the metric is compared with.
+	Value string `json:"value,omitempty"`
+}
+
+// RuleAction is the reaction on the rule firing.
+type RuleAction struct {
+	Type RuleActionType `json:"type"`
+
+	// UserID is the user whose orders are canceled or placed.
+	UserID uint32 `json:"user_id,omitempty"`
+
+	// Markets is the markets on which orders are canceled, all markets
+	// if empty.
+	Markets []string `json:"markets,omitempty"`
+
+	// Market, Side, Amount, Price and fee rates describe the placed
+	// order, side is either "ask" or "bid".
+	Market       string `json:"market,omitempty"`
+	Side         string `json:"side,omitempty"`
+	Amount       string `json:"amount,omitempty"`
+	Price        string `json:"price,omitempty"`
+	TakerFeeRate string `json:"taker_fee_rate,omitempty"`
+	MakerFeeRate string `json:"maker_fee_rate,omitempty"`
+	Source       string `json:"source,omitempty"`
+
+	// URL is the url of the webhook.
+	URL string `json:"url,omitempty"`
+
+	// Message is the text which is attached to the webhook and alert.
+	Message string `json:"message,omitempty"`
+}
+
+// Rule is the condition and the actions which are executed once the
+// condition becomes satisfied.
+type Rule struct {
+	Name string        `json:"name"`
+	When RuleCondition `json:"when"`
+	Then []RuleAction  `json:"then"`
+
+	// Cooldown is the minimum time between the firings of the rule.
+	Cooldown SpecDuration `json:"cooldown,omitempty"`
+}
+
+// RuleSpec is the declarative description of the rules.
+type RuleSpec struct {
+	// Interval is the evaluation interval.
+	Interval SpecDuration `json:"interval,omitempty"`
+
+	Rules []Rule `json:"rules"`
+}
+
+// ParseRuleSpec parses the json spec.
+func ParseRuleSpec(data []byte) (*RuleSpec, error) {
+	spec := &RuleSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("unable to parse rule spec: %w", err)
+	}
+
+	return spec, nil
+}
+
+// ParseRuleSpecYAML parses the yaml spec, it has the same structure as the
+// json one.
+func ParseRuleSpecYAML(data []byte) (*RuleSpec, error) {
+	data, err := yamlToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rule spec: %w", err)
+	}
+
+	return ParseRuleSpec(data)
+}
+
+// LoadRuleSpec reads the spec from the file, files with ".yaml" and ".yml"
+// extensions are parsed as yaml, others as json.
+func LoadRuleSpec(path string) (*RuleSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseRuleSpecYAML(data)
+	default:
+		return ParseRuleSpec(data)
+	}
+}
+
+// RuleActionResult is the outcome of the single action of the firing.
+type RuleActionResult struct {
+	Action RuleAction `json:"action"`
+
+	// DryRun is true if the action wasn't executed because the engine
+	// works in the dry-run mode.
+	DryRun bool `json:"dry_run"`
+
+	// Result is the outcome of the executed action, e.g. the placed order
+	// or the results of cancellation.
+	Result interface{} `json:"result,omitempty"`
+
+	// Err is the error of the action execution.
+	Err error `json:"-"`
+}
+
+// RuleFiring describes the rule which condition became satisfied.
+type RuleFiring struct {
+	Rule string    `json:"rule"`
+	Time time.Time `json:"time"`
+
+	// Values is the observed values of the metrics of the condition, keys
+	// describe the metric, e.g. "last BTCETH".
+	Values map[string]string `json:"values"`
+
+	Actions []RuleActionResult `json:"actions"`
+}
+
+// RuleEngineConfig is an structure which holds configurable parameters of
+// the rule engine.
+type RuleEngineConfig struct {
+	// DryRun makes the engine evaluate the rules and report the firings
+	// without executing any of the actions, it is used to check the rules
+	// against the live market before enabling them.
+	DryRun bool
+
+	// Alert is the handler of the alert actions, it is required if any of
+	// the rules raises alerts.
+	Alert func(firing *RuleFiring, message string)
+
+	// OnFiring is called with every firing after its actions are
+	// executed.
+	OnFiring func(firing *RuleFiring)
+
+	// HTTPClient is used to send webhooks, client with default timeout is
+	// used if not specified.
+	HTTPClient *http.Client
+}
+
+// ruleState is the evaluation state of the rule.
+type ruleState struct {
+	// satisfied is set when the rule fires, and cleared when its condition
+	// is no longer satisfied.
+	satisfied bool
+	lastFired time.Time
+}
+
+// RuleEngine periodically evaluates the rules against the market data and
+// reacts on them. Rules are edge-triggered: the rule fires when its
+// condition becomes satisfied, and doesn't fire again until the condition
+// is unsatisfied and satisfied again. Rule which becomes satisfied during
+// the cooldown fires as soon as the cooldown passes, if its condition is
+// still satisfied.
+type RuleEngine struct {
+	client   *Client
+	cfg      *RuleEngineConfig
+	interval time.Duration
+	rules    []Rule
+
+	// evalMtx serializes evaluations.
+	evalMtx sync.Mutex
+	states  []ruleState
+
+	mtx     sync.Mutex
+	started bool
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRuleEngine validates the spec and creates the rule engine.
+func NewRuleEngine(client *Client, spec *RuleSpec,
+	cfg *RuleEngineConfig) (*RuleEngine, error) {
+
+	if cfg == nil {
+		cfg = &RuleEngineConfig{}
+	}
+
+	names := make(map[string]struct{})
+	for i := range spec.Rules {
+		rule := &spec.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule #%v has no name", i)
+		}
+
+		if _, ok := names[rule.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule %v", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		if err := validateRuleCondition(&rule.When); err != nil {
+			return nil, fmt.Errorf("rule %v: %w", rule.Name, err)
+		}
+
+		if len(rule.Then) == 0 {
+			return nil, fmt.Errorf("rule %v has no actions", rule.Name)
+		}
+
+		for j := range rule.Then {
+			if err := validateRuleAction(&rule.Then[j], cfg); err != nil {
+				return nil, fmt.Errorf("rule %v: %w", rule.Name, err)
+			}
+		}
+	}
+
+	interval := time.Duration(spec.Interval)
+	if interval <= 0 {
+		interval = defaultRuleInterval
+	}
+
+	return &RuleEngine{
+		client:   client,
+		cfg:      cfg,
+		interval: interval,
+		rules:    spec.Rules,
+		states:   make([]ruleState, len(spec.Rules)),
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+func validateRuleCondition(c *RuleCondition) error {
+	if len(c.All) != 0 || len(c.Any) != 0 {
+		if c.Metric != "" {
+			return errors.New("condition with nested conditions " +
+				"shouldn't have metric")
+		}
+
+		for i := range c.All {
+			if err := validateRuleCondition(&c.All[i]); err != nil {
+				return err
+			}
+		}
+
+		for i := range c.Any {
+			if err := validateRuleCondition(&c.Any[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	switch c.Metric {
+	case RuleLast, RuleBid, RuleAsk, RuleSpread, RulePendingOrders:
+		if c.Market == "" {
+			return fmt.Errorf("market of %v should be specified",
+				c.Metric)
+		}
+
+	case RuleBalance:
+		if c.Asset == "" {
+			return errors.New("asset of balance should be specified")
+		}
+
+	case RuleOrderLeft:
+		if c.Market == "" || c.OrderID == 0 {
+			return errors.New("market and order id of order_left " +
+				"should be specified")
+		}
+
+	default:
+		return fmt.Errorf("unknown metric %q", c.Metric)
+	}
+
+	switch c.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return fmt.Errorf("unknown operator %q", c.Op)
+	}
+
+	if _, err := parseDecimal(c.Value); err != nil {
+		return fmt.Errorf("invalid value %q: %w", c.Value, err)
+	}
+
+	return nil
+}
+
+func validateRuleAction(a *RuleAction, cfg *RuleEngineConfig) error {
+	switch a.Type {
+	case RuleCancelAll:
+		for _, market := range a.Markets {
+			if _, err := ParseMarket(market); err != nil {
+				return err
+			}
+		}
+
+	case RulePlaceOrder:
+		if a.Market == "" || a.Amount == "" || a.Price == "" {
+			return errors.New("market, amount and price of the order " +
+				"should be specified")
+		}
+
+		if _, err := ParseMarket(a.Market); err != nil {
+			return err
+		}
+
+		if NewMarketSideFromString(a.Side) == 0 {
+			return fmt.Errorf("unknown order side %q", a.Side)
+		}
+
+	case RuleWebhook:
+		if a.URL == "" {
+			return errors.New("url of webhook should be specified")
+		}
+
+	case RuleAlert:
+		if cfg.Alert == nil {
+			return errors.New("alert handler isn't configured")
+		}
+
+	default:
+		return fmt.Errorf("unknown action %q", a.Type)
+	}
+
+	return nil
+}
+
+// ruleSnapshot is the market data fetched during the single evaluation,
+// so that the metric used by several rules is fetched only once.
+type ruleSnapshot struct {
+	client *Client
+
+	lasts    map[string]*big.Rat
+	depths   map[string]*OrderDepthResponse
+	balances map[uint32]BalanceQueryResponse
+	pending  map[string]int
+	left     map[string]*big.Rat
+}
+
+func newRuleSnapshot(client *Client) *ruleSnapshot {
+	return &ruleSnapshot{
+		client:   client,
+		lasts:    make(map[string]*big.Rat),
+		depths:   make(map[string]*OrderDepthResponse),
+		balances: make(map[uint32]BalanceQueryResponse),
+		pending:  make(map[string]int),
+		left:     make(map[string]*big.Rat),
+	}
+}
+
+func (s *ruleSnapshot) depth(market string) (*OrderDepthResponse, error) {
+	if depth, ok := s.depths[market]; ok {
+		return depth, nil
+	}
+
+	depth, err := s.client.OrderDepth(&OrderDepthRequest{
+		Market:   market,
+		Limit:    1,
+		Interval: DefaultDepthInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.depths[market] = depth
+	return depth, nil
+}
+
+// bestPrice returns the price of the best level, nil if there are no
+// levels.
+func bestPrice(levels []Depth) (*big.Rat, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	return parseDecimal(levels[0].Price)
+}
+
+// metric returns the value of the condition metric, nil value means that
+// the metric is undefined, e.g. the spread of the empty book.
+func (s *ruleSnapshot) metric(c *RuleCondition) (*big.Rat, error) {
+	switch c.Metric {
+	case RuleLast:
+		if last, ok := s.lasts[c.Market]; ok {
+			return last, nil
+		}
+
+		resp, err := s.client.MarketLast(&MarketLastRequest{
+			Market: c.Market,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, fmt.Errorf("empty last price of %v", c.Market)
+		}
+
+		last, err := parseDecimal(*resp)
+		if err != nil {
+			return nil, err
+		}
+
+		s.lasts[c.Market] = last
+		return last, nil
+
+	case RuleBid, RuleAsk, RuleSpread:
+		depth, err := s.depth(c.Market)
+		if err != nil {
+			return nil, err
+		}
+
+		bid, err := bestPrice(depth.Bids)
+		if err != nil {
+			return nil, err
+		}
+
+		ask, err := bestPrice(depth.Asks)
+		if err != nil {
+			return nil, err
+		}
+
+		switch c.Metric {
+		case RuleBid:
+			if bid == nil {
+				return new(big.Rat), nil
+			}
+			return bid, nil
+
+		case RuleAsk:
+			if ask == nil {
+				return new(big.Rat), nil
+			}
+			return ask, nil
+
+		default:
+			if bid == nil || ask == nil {
+				return nil, nil
+			}
+			return new(big.Rat).Sub(ask, bid), nil
+		}
+
+	case RuleBalance:
+		balances, ok := s.balances[c.UserID]
+		if !ok {
+			var err error
+			balances, err = s.client.BalanceQuery(&BalanceQueryRequest{
+				UserID: c.UserID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.balances[c.UserID] = balances
+		}
+
+		balance, ok := balances[c.Asset]
+		if !ok {
+			return new(big.Rat), nil
+		}
+
+		return parseDecimal(balance.Available)
+
+	case RulePendingOrders:
+		key := fmt.Sprintf("%v/%v", c.UserID, c.Market)
+		count, ok := s.pending[key]
+		if !ok {
+			orders, err := s.client.allPending(c.UserID, c.Market)
+			if err != nil {
+				return nil, err
+			}
+
+			count = len(orders)
+			s.pending[key] = count
+		}
+
+		return new(big.Rat).SetInt64(int64(count)), nil
+
+	case RuleOrderLeft:
+		key := fmt.Sprintf("%v/%v", c.Market, c.OrderID)
+		if left, ok := s.left[key]; ok {
+			return left, nil
+		}
+
+		order, err := s.client.OrderPendingDetail(&OrderPendingDetailRequest{
+			Market:  c.Market,
+			OrderID: c.OrderID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		left := new(big.Rat)
+		if order != nil {
+			left, err = parseDecimal(order.Left)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		s.left[key] = left
+		return left, nil
+	}
+
+	return nil, fmt.Errorf("unknown metric %q", c.Metric)
+}
+
+// metricName describes the metric of the condition.
+func metricName(c *RuleCondition) string {
+	switch c.Metric {
+	case RuleBalance:
+		return fmt.Sprintf("balance %v/%v", c.UserID, c.Asset)
+	case RulePendingOrders:
+		return fmt.Sprintf("pending_orders %v/%v", c.UserID, c.Market)
+	case RuleOrderLeft:
+		return fmt.Sprintf("order_left %v/%v", c.Market, c.OrderID)
+	default:
+		return fmt.Sprintf("%v %v", c.Metric, c.Market)
+	}
+}
+
+// compare applies the operator of the condition.
+func compare(value, threshold *big.Rat, op string) bool {
+	cmp := value.Cmp(threshold)
+
+	switch op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	}
+
+	return false
+}
+
+// evaluate returns whether the condition is satisfied, the observed values
+// of the metrics are added to the values.
+func (s *ruleSnapshot) evaluate(c *RuleCondition,
+	values map[string]string) (bool, error) {
+
+	if len(c.All) != 0 || len(c.Any) != 0 {
+		for i := range c.All {
+			ok, err := s.evaluate(&c.All[i], values)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		if len(c.Any) == 0 {
+			return true, nil
+		}
+
+		for i := range c.Any {
+			ok, err := s.evaluate(&c.Any[i], values)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	value, err := s.metric(c)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch %v: %w",
+			metricName(c), err)
+	}
+
+	if value == nil {
+		values[metricName(c)] = ""
+		return false, nil
+	}
+	values[metricName(c)] = value.FloatString(lotReportPrec)
+
+	threshold, err := parseDecimal(c.Value)
+	if err != nil {
+		return false, err
+	}
+
+	return compare(value, threshold, c.Op), nil
+}
+
+// Evaluate evaluates all rules once and executes the actions of the rules
+// which fired, unless the engine works in the dry-run mode. Rules which
+// couldn't be evaluated are skipped and the last of their errors is
+// returned.
+func (r *RuleEngine) Evaluate() ([]*RuleFiring, error) {
+	r.evalMtx.Lock()
+	defer r.evalMtx.Unlock()
+
+	snapshot := newRuleSnapshot(r.client)
+
+	var (
+		firings []*RuleFiring
+		lastErr error
+	)
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		state := &r.states[i]
+
+		values := make(map[string]string)
+		ok, err := snapshot.evaluate(&rule.When, values)
+		if err != nil {
+			lastErr = fmt.Errorf("unable to evaluate rule %v: %w",
+				rule.Name, err)
+			continue
+		}
+
+		if !ok {
+			state.satisfied = false
+			continue
+		}
+
+		if state.satisfied {
+			continue
+		}
+
+		// Rule which became satisfied during the cooldown isn't marked as
+		// satisfied, so that it fires once the cooldown passes.
+		now := r.client.now()
+		cooldown := time.Duration(rule.Cooldown)
+		if !state.lastFired.IsZero() && now.Sub(state.lastFired) < cooldown {
+			continue
+		}
+		state.satisfied = true
+		state.lastFired = now
+
+		firing := &RuleFiring{
+			Rule:   rule.Name,
+			Time:   now,
+			Values: values,
+		}
+
+		for j := range rule.Then {
+			firing.Actions = append(firing.Actions,
+				r.execute(firing, &rule.Then[j]))
+		}
+
+		if r.cfg.OnFiring != nil {
+			r.cfg.OnFiring(firing)
+		}
+
+		firings = append(firings, firing)
+	}
+
+	return firings, lastErr
+}
+
+// execute runs the action of the firing, in dry-run mode the action is
+// only reported.
+func (r *RuleEngine) execute(firing *RuleFiring,
+	action *RuleAction) RuleActionResult {
+
+	result := RuleActionResult{
+		Action: *action,
+		DryRun: r.cfg.DryRun,
+	}
+
+	if r.cfg.DryRun {
+		return result
+	}
+
+	switch action.Type {
+	case RuleCancelAll:
+		var markets []MarketType
+		for _, market := range action.Markets {
+			m, err := ParseMarket(market)
+			if err != nil {
+				result.Err = err
+				return result
+			}
+			markets = append(markets, m)
+		}
+
+		results, err := r.client.CancelAllPending(action.UserID, markets...)
+		result.Result = results
+		result.Err = err
+		if err == nil {
+			for _, res := range results {
+				if res.Err != nil {
+					result.Err = fmt.Errorf("unable to cancel order %v "+
+						"of %v: %w", res.OrderID, res.Market, res.Err)
+					break
+				}
+			}
+		}
+
+	case RulePlaceOrder:
+		order, err := r.client.OrderPutLimit(&OrderPutLimitRequest{
+			UserID:       action.UserID,
+			Market:       action.Market,
+			Side:         NewMarketSideFromString(action.Side),
+			Amount:       action.Amount,
+			Price:        action.Price,
+			TakerFeeRate: action.TakerFeeRate,
+			MakerFeeRate: action.MakerFeeRate,
+			Source:       action.Source,
+		})
+		result.Result = order
+		result.Err = err
+
+	case RuleWebhook:
+		result.Err = r.webhook(firing, action)
+
+	case RuleAlert:
+		r.cfg.Alert(firing, action.Message)
+	}
+
+	return result
+}
+
+// webhook posts the firing to the webhook url.
+func (r *RuleEngine) webhook(firing *RuleFiring, action *RuleAction) error {
+	body, err := json.Marshal(struct {
+		Rule    string            `json:"rule"`
+		Time    time.Time         `json:"time"`
+		Message string            `json:"message,omitempty"`
+		Values  map[string]string `json:"values"`
+	}{
+		Rule:    firing.Rule,
+		Time:    firing.Time,
+		Message: action.Message,
+		Values:  firing.Values,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpClient := r.cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	resp, err := httpClient.Post(action.URL, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+	}
+
+	return nil
+}
+
+// Start starts the periodic evaluation of the rules.
+func (r *RuleEngine) Start() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.started {
+		return errors.New("rule engine is already started")
+	}
+	r.started = true
+
+	r.wg.Add(1)
+	go r.run()
+
+	return nil
+}
+
+// Stop stops the evaluation of the rules.
+func (r *RuleEngine) Stop() {
+	r.mtx.Lock()
+	if !r.started {
+		r.mtx.Unlock()
+		return
+	}
+	r.started = false
+	close(r.quit)
+	r.mtx.Unlock()
+
+	r.wg.Wait()
+}
+
+// Err returns the last error of the evaluation or of the action.
+func (r *RuleEngine) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.lastErr
+}
+
+func (r *RuleEngine) setErr(err error) {
+	r.mtx.Lock()
+	r.lastErr = err
+	r.mtx.Unlock()
+}
+
+func (r *RuleEngine) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		firings, err := r.Evaluate()
+		if err != nil {
+			r.setErr(err)
+		}
+
+		for _, firing := range firings {
+			for _, action := range firing.Actions {
+				if action.Err != nil {
+					r.setErr(fmt.Errorf("action %v of rule %v has "+
+						"failed: %w", action.Action.Type, firing.Rule,
+						action.Err))
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package viabtc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// SessionSummaryConfig is an structure which holds parameters of the
+// trading session summary.
+type SessionSummaryConfig struct {
+	UserID uint32
+	Market MarketType
+
+	// Start and End is the window of the session, end is the current time
+	// if not specified.
+	Start time.Time
+	End   time.Time
+}
+
+// SessionSummary is the report of the user's trading on the market within
+// the session window. Amounts are denominated in the market stock, volumes
+// of money, fees and P&L are denominated in the market money unless stated
+// otherwise.
+type SessionSummary struct {
+	UserID uint32     `json:"user_id"`
+	Market MarketType `json:"market"`
+	Start  time.Time  `json:"start"`
+	End    time.Time  `json:"end"`
+
+	// FinishedOrders is the number of the orders finished within the
+	// session, FilledOrders and CanceledOrders is the number of them which
+	// have been fully executed and which have been canceled before that.
+	FinishedOrders int `json:"finished_orders"`
+	FilledOrders   int `json:"filled_orders"`
+	CanceledOrders int `json:"canceled_orders"`
+
+	// PendingOrders is the number of the orders placed within the session
+	// which are still in the order book.
+	PendingOrders int `json:"pending_orders"`
+
+	// FillRate is the executed part of the amount of the finished limit
+	// orders, market orders aren't included because amount of the market
+	// buy is denominated in money.
+	FillRate string `json:"fill_rate"`
+
+	// Deals is the number of the user's deals within the session.
+	Deals int `json:"deals"`
+
+	// BuyVolume and SellVolume is the bought and sold stock, MoneyVolume
+	// is the money of all deals.
+	BuyVolume   string `json:"buy_volume"`
+	SellVolume  string `json:"sell_volume"`
+	MoneyVolume string `json:"money_volume"`
+
+	// StockFees is the fee of the buy deals, which is taken in stock,
+	// MoneyFees is the fee of the sell deals. Fees is the sum of both
+	// valued in money at the prices of the deals.
+	StockFees string `json:"stock_fees"`
+	MoneyFees string `json:"money_fees"`
+	Fees      string `json:"fees"`
+
+	// RealizedPnL is the gain of the sells matched with the buys of the
+	// session in FIFO order. Stock which has been bought before the
+	// session is unknown, its sold amount is reported as UnmatchedAmount
+	// and isn't included in the P&L.
+	RealizedPnL     string `json:"realized_pnl"`
+	UnmatchedAmount string `json:"unmatched_amount"`
+}
+
+// SessionSummary fetches the user's orders and deals of the session window
+// and summarises them into the single report.
+func (e *Client) SessionSummary(cfg *SessionSummaryConfig) (*SessionSummary,
+	error) {
+
+	if cfg.Start.IsZero() {
+		return nil, errors.New("start of the session should be specified")
+	}
+
+	end := cfg.End
+	if end.IsZero() {
+		end = e.now()
+	}
+
+	if !cfg.Start.Before(end) {
+		return nil, fmt.Errorf("start of the session %v should be "+
+			"before the end %v", cfg.Start, end)
+	}
+
+	summary := &SessionSummary{
+		UserID: cfg.UserID,
+		Market: cfg.Market,
+		Start:  cfg.Start,
+		End:    end,
+	}
+
+	if err := e.summariseOrders(summary); err != nil {
+		return nil, err
+	}
+
+	if err := e.summariseDeals(summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// summariseOrders counts the finished and pending orders of the session.
+func (e *Client) summariseOrders(s *SessionSummary) error {
+	var (
+		placed   = new(big.Rat)
+		executed = new(big.Rat)
+	)
+
+	it := NewFinishedOrdersQuery(s.UserID).Market(s.Market).
+		Since(s.Start).Until(s.End).Iter(e)
+	for it.Next() {
+		order := it.Order()
+		s.FinishedOrders++
+
+		amount, err := parseDecimal(order.Amount)
+		if err != nil {
+			return fmt.Errorf("unable to parse amount of order %v: %w",
+				order.OrderID, err)
+		}
+
+		dealStock := new(big.Rat)
+		if order.DealStock != "" {
+			if dealStock, err = parseDecimal(order.DealStock); err != nil {
+				return fmt.Errorf("unable to parse deal stock of "+
+					"order %v: %w", order.OrderID, err)
+			}
+		}
+
+		// Market buy might leave the money which isn't enough for the
+		// smallest amount of stock, so it is considered filled.
+		if order.Type == MarketOrderType || dealStock.Cmp(amount) >= 0 {
+			s.FilledOrders++
+		} else {
+			s.CanceledOrders++
+		}
+
+		if order.Type == LimitOrderType {
+			placed.Add(placed, amount)
+			executed.Add(executed, dealStock)
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("unable to fetch finished orders: %w", err)
+	}
+
+	s.FillRate = new(big.Rat).FloatString(lotReportPrec)
+	if placed.Sign() != 0 {
+		s.FillRate = executed.Quo(executed, placed).FloatString(lotReportPrec)
+	}
+
+	pending, err := e.allPending(s.UserID, s.Market.String())
+	if err != nil {
+		return fmt.Errorf("unable to fetch pending orders: %w", err)
+	}
+
+	start, end := unixTime(s.Start), unixTime(s.End)
+	for _, order := range pending {
+		if order.CTime >= start && order.CTime < end {
+			s.PendingOrders++
+		}
+	}
+
+	return nil
+}
+
+// summariseDeals sums the volumes and fees of the session deals and
+// calculates the realized P&L.
+func (e *Client) summariseDeals(s *SessionSummary) error {
+	var deals []DealDetail
+
+	it := NewUserDealsQuery(s.UserID).Market(s.Market).
+		Since(s.Start).Until(s.End).Iter(e)
+	for it.Next() {
+		deals = append(deals, it.Deal())
+	}
+
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("unable to fetch deals: %w", err)
+	}
+
+	var (
+		buyVolume   = new(big.Rat)
+		sellVolume  = new(big.Rat)
+		moneyVolume = new(big.Rat)
+		stockFees   = new(big.Rat)
+		moneyFees   = new(big.Rat)
+		fees        = new(big.Rat)
+	)
+
+	for i := range deals {
+		d := &deals[i]
+
+		amount, deal, fee, price, err := parseDeal(d)
+		if err != nil {
+			return fmt.Errorf("unable to parse deal %v: %w", d.DealID,
+				err)
+		}
+
+		moneyVolume.Add(moneyVolume, deal)
+
+		switch d.Side {
+		case MarketOrderSideBid:
+			buyVolume.Add(buyVolume, amount)
+			stockFees.Add(stockFees, fee)
+			fees.Add(fees, new(big.Rat).Mul(fee, price))
+
+		case MarketOrderSideAsk:
+			sellVolume.Add(sellVolume, amount)
+			moneyFees.Add(moneyFees, fee)
+			fees.Add(fees, fee)
+		}
+	}
+
+	lots, err := MatchLots(s.Market, deals)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pnl       = new(big.Rat)
+		unmatched = new(big.Rat)
+	)
+
+	for _, line := range lots.Lines {
+		if line.Unmatched {
+			amount, err := parseDecimal(line.Amount)
+			if err != nil {
+				return err
+			}
+			unmatched.Add(unmatched, amount)
+			continue
+		}
+
+		gain, err := parseDecimal(line.Gain)
+		if err != nil {
+			return err
+		}
+		pnl.Add(pnl, gain)
+	}
+
+	s.Deals = len(deals)
+	s.BuyVolume = buyVolume.FloatString(lotReportPrec)
+	s.SellVolume = sellVolume.FloatString(lotReportPrec)
+	s.MoneyVolume = moneyVolume.FloatString(lotReportPrec)
+	s.StockFees = stockFees.FloatString(lotReportPrec)
+	s.MoneyFees = moneyFees.FloatString(lotReportPrec)
+	s.Fees = fees.FloatString(lotReportPrec)
+	s.RealizedPnL = pnl.FloatString(lotReportPrec)
+	s.UnmatchedAmount = unmatched.FloatString(lotReportPrec)
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package viabtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultSignatureHeader is the header of the signature of the request.
+	DefaultSignatureHeader = "X-Signature"
+
+	// DefaultTimestampHeader is the header of the time of the signature.
+	DefaultTimestampHeader = "X-Signature-Timestamp"
+
+	// DefaultKeyIDHeader is the header of the id of the signing key.
+	DefaultKeyIDHeader = "X-Signature-Key"
+)
+
+// Signer signs the rpc requests, so that the gateway in front of the engine
+// might verify that the request is made by the trusted client.
+type Signer interface {
+	// Sign returns the headers which should be attached to the request of
+	// the method with the given json encoded params array.
+	Sign(method string, params []byte, timestamp time.Time) (http.Header,
+		error)
+}
+
+// HMACSigner signs the requests with HMAC over the canonical string
+// "<method>\n<params>\n<timestamp>", where params is the json encoded
+// params array and timestamp is the unix time in seconds. Signature is
+// sent hex encoded along with the timestamp and the key id.
+type HMACSigner struct {
+	// Key is the secret shared with the gateway.
+	Key []byte
+
+	// KeyID, if specified, is sent in the key id header, so that the
+	// gateway might rotate the keys.
+	KeyID string
+
+	// Hash is the hash function of the HMAC, sha256 if not specified.
+	Hash func() hash.Hash
+
+	// SignatureHeader, TimestampHeader and KeyIDHeader are the names of
+	// the headers, the default ones are used if not specified.
+	SignatureHeader string
+	TimestampHeader string
+	KeyIDHeader     string
+}
+
+// A compile time check to ensure HMACSigner implements the Signer
+// interface.
+var _ Signer = (*HMACSigner)(nil)
+
+// Sign returns the signature headers of the request.
+func (s *HMACSigner) Sign(method string, params []byte,
+	timestamp time.Time) (http.Header, error) {
+
+	if len(s.Key) == 0 {
+		return nil, errors.New("signing key isn't specified")
+	}
+
+	hashFunc := s.Hash
+	if hashFunc == nil {
+		hashFunc = sha256.New
+	}
+
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(hashFunc, s.Key)
+	mac.Write([]byte(method))
+	mac.Write([]byte("\n"))
+	mac.Write(params)
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(ts))
+
+	header := http.Header{}
+	header.Set(headerOrDefault(s.SignatureHeader, DefaultSignatureHeader),
+		hex.EncodeToString(mac.Sum(nil)))
+	header.Set(headerOrDefault(s.TimestampHeader, DefaultTimestampHeader), ts)
+	if s.KeyID != "" {
+		header.Set(headerOrDefault(s.KeyIDHeader, DefaultKeyIDHeader),
+			s.KeyID)
+	}
+
+	return header, nil
+}
+
+func headerOrDefault(header, defaultHeader string) string {
+	if header == "" {
+		return defaultHeader
+	}
+
+	return header
+}
+
+// signRequest attaches the signature headers of the configured signer to
+// the request.
+func (e *Client) signRequest(req *http.Request, rpcReq *request) error {
+	if e.cfg.Signer == nil {
+		return nil
+	}
+
+	params, err := json.Marshal(rpcReq.Params)
+	if err != nil {
+		return err
+	}
+
+	header, err := e.cfg.Signer.Sign(rpcReq.Method, params, e.now())
+	if err != nil {
+		return fmt.Errorf("unable to sign request of %v: %w",
+			rpcReq.Method, err)
+	}
+
+	for key, values := range header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package viabtc
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSkewMaxAge is the age of the skew measurement after which it is
+// considered outdated and measured again before the time-ranged query.
+const clockSkewMaxAge = time.Minute
+
+var (
+	// ErrClockSkew is returned by time-ranged queries if the measured
+	// difference between the server and client clocks exceeds the
+	// configured threshold, because in this case the time range of the
+	// query is silently truncated by the engine.
+	ErrClockSkew = errors.New("clock skew between client and server " +
+		"exceeds the threshold")
+
+	// ErrClockSkewUnknown is returned by time-ranged queries if the clock
+	// skew guard is enabled, but the server doesn't report its time.
+	ErrClockSkewUnknown = errors.New("unable to measure clock skew, " +
+		"server response has no date header")
+)
+
+// clockSkew keeps track of the difference between the server and client
+// clocks, which is measured using the date header of the http responses.
+type clockSkew struct {
+	sync.RWMutex
+
+	skew     time.Duration
+	measured time.Time
+}
+
+// observe updates the skew using the date header of the response. The
+// server time is compared with the middle of the request round trip.
+func (c *clockSkew) observe(header http.Header, sent, received time.Time) {
+	date := header.Get("Date")
+	if date == "" {
+		return
+	}
+
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	local := sent.Add(received.Sub(sent) / 2)
+
+	c.Lock()
+	c.skew = server.Sub(local)
+	c.measured = received
+	c.Unlock()
+}
+
+// get returns the last measured skew and the time of measurement.
+func (c *clockSkew) get() (time.Duration, time.Time) {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.skew, c.measured
+}
+
+// ClockSkew returns the last measured difference between the server and
+// client clocks, positive if the server clock is ahead. Measurement is
+// based on the date header of the responses, which has one second
+// resolution. Second return value is false if skew hasn't been measured yet.
+func (e *Client) ClockSkew() (time.Duration, bool) {
+	skew, measured := e.skew.get()
+	return skew, !measured.IsZero()
+}
+
+// serverNow returns the current time of the server clock, estimated with
+// the last measured skew, or the client clock if skew hasn't been measured
+// yet.
+func (e *Client) serverNow() time.Time {
+	skew, _ := e.skew.get()
+	return e.now().Add(skew)
+}
+
+// MeasureClockSkew makes the lightweight request to the server in order to
+// measure the clock skew.
+func (e *Client) MeasureClockSkew() (time.Duration, error) {
+	if _, err := e.MarketList(&MarketListRequest{}); err != nil {
+		return 0, err
+	}
+
+	skew, ok := e.ClockSkew()
+	if !ok {
+		return 0, ErrClockSkewUnknown
+	}
+
+	return skew, nil
+}
+
+// guardClockSkew checks the clock skew before the time-ranged query, if the
+// guard is enabled. If the skew exceeds the threshold the time range is
+// either shifted to the server clock or ErrClockSkew is returned, depending
+// on configuration.
+func (e *Client) guardClockSkew(start, end *float64) error {
+	if e.cfg.MaxClockSkew <= 0 {
+		return nil
+	}
+
+	skew, measured := e.skew.get()
+	if measured.IsZero() || e.since(measured) > clockSkewMaxAge {
+		var err error
+		if skew, err = e.MeasureClockSkew(); err != nil {
+			return err
+		}
+	}
+
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+
+	if abs <= e.cfg.MaxClockSkew {
+		return nil
+	}
+
+	if !e.cfg.AdjustClockSkew {
+		return ErrClockSkew
+	}
+
+	// Engine expects the time range in whole seconds.
+	shift := skew.Round(time.Second).Seconds()
+
+	if *start != 0 {
+		*start += shift
+	}
+
+	if *end != 0 {
+		*end += shift
+	}
+
+	return nil
+}
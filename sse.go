@@ -0,0 +1,366 @@
+package viabtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSSEPollInterval is the interval with which the market data is
+	// fetched from the exchange client if not specified otherwise.
+	defaultSSEPollInterval = time.Second
+
+	// defaultSSEDepthLimit is the number of depth levels which is sent in
+	// depth events if not specified otherwise.
+	defaultSSEDepthLimit int32 = 10
+
+	// sseSubscriberBuffer is the number of events which might be queued for
+	// the single subscriber before events start to be dropped.
+	sseSubscriberBuffer = 64
+)
+
+// SSEConfig is an structure which holds configurable parameters of the
+// server-sent events gateway.
+type SSEConfig struct {
+	// Markets is the list of markets which are allowed to be streamed. If
+	// empty, any market might be requested.
+	Markets []MarketType
+
+	// PollInterval is the interval with which the market data is fetched
+	// from the exchange client.
+	PollInterval time.Duration
+
+	// DepthLimit is the number of top levels of the order book which are
+	// sent within depth events.
+	DepthLimit int32
+}
+
+// SSEDealsEvent is sent when new deals occurred on the market.
+type SSEDealsEvent struct {
+	Market string              `json:"market"`
+	Deals  MarketDealsResponse `json:"deals"`
+}
+
+// SSETickerEvent is sent when the last price or today's status of the
+// market has changed.
+type SSETickerEvent struct {
+	Market string                     `json:"market"`
+	Last   string                     `json:"last"`
+	Today  *MarketStatusTodayResponse `json:"today"`
+}
+
+// SSEDepthEvent is sent when the top of the market order book has changed.
+type SSEDepthEvent struct {
+	Market string  `json:"market"`
+	Asks   []Depth `json:"asks"`
+	Bids   []Depth `json:"bids"`
+}
+
+// SSEErrorEvent is sent when the market data couldn't be fetched from the
+// exchange client.
+type SSEErrorEvent struct {
+	Market string `json:"market"`
+	Error  string `json:"error"`
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// sseFeed polls the market data of the single market and fans it out to the
+// all connected subscribers.
+type sseFeed struct {
+	market      string
+	subscribers map[chan sseEvent]struct{}
+	quit        chan struct{}
+
+	// lastTicker and lastDepth are the latest sent ticker and depth
+	// events, they are sent to the new subscriber on join, so that it
+	// doesn't wait for the next change.
+	lastTicker *sseEvent
+	lastDepth  *sseEvent
+}
+
+// SSEHandler is the http handler which streams the market data to the
+// browsers and dashboards using server-sent events. The market is specified
+// with "market" query parameter, and the data of the single market is
+// fetched once regardless of the number of connected subscribers. New
+// subscriber of the running feed receives the latest ticker and depth
+// events on join.
+type SSEHandler struct {
+	client *Client
+	cfg    SSEConfig
+
+	mtx   sync.Mutex
+	feeds map[string]*sseFeed
+}
+
+// A compile time check to ensure SSEHandler implements the http.Handler
+// interface.
+var _ http.Handler = (*SSEHandler)(nil)
+
+// NewSSEHandler creates new instance of server-sent events gateway.
+func NewSSEHandler(client *Client, cfg *SSEConfig) *SSEHandler {
+	c := SSEConfig{}
+	if cfg != nil {
+		c = *cfg
+	}
+
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultSSEPollInterval
+	}
+
+	if c.DepthLimit <= 0 {
+		c.DepthLimit = defaultSSEDepthLimit
+	}
+
+	return &SSEHandler{
+		client: client,
+		cfg:    c,
+		feeds:  make(map[string]*sseFeed),
+	}
+}
+
+// ServeHTTP streams the events of the requested market until the client
+// disconnects.
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	market := r.URL.Query().Get("market")
+	if !h.isAllowed(market) {
+		http.Error(w, fmt.Sprintf("market %q is not available", market),
+			http.StatusBadRequest)
+		return
+	}
+
+	events, unsubscribe := h.subscribe(market)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-events:
+			_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name,
+				event.data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// isAllowed checks that the market might be streamed.
+func (h *SSEHandler) isAllowed(market string) bool {
+	if market == "" {
+		return false
+	}
+
+	if len(h.cfg.Markets) == 0 {
+		return true
+	}
+
+	for _, m := range h.cfg.Markets {
+		if m.String() == market {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribe registers new subscriber of the market events, and starts the
+// market feed if it is the first one.
+func (h *SSEHandler) subscribe(market string) (chan sseEvent, func()) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	feed, ok := h.feeds[market]
+	if !ok {
+		feed = &sseFeed{
+			market:      market,
+			subscribers: make(map[chan sseEvent]struct{}),
+			quit:        make(chan struct{}),
+		}
+		h.feeds[market] = feed
+		go h.poll(feed)
+	}
+
+	events := make(chan sseEvent, sseSubscriberBuffer)
+	feed.subscribers[events] = struct{}{}
+
+	for _, event := range []*sseEvent{feed.lastTicker, feed.lastDepth} {
+		if event != nil {
+			events <- *event
+		}
+	}
+
+	unsubscribe := func() {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+
+		delete(feed.subscribers, events)
+		if len(feed.subscribers) == 0 {
+			close(feed.quit)
+			delete(h.feeds, market)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// broadcast sends the event to the all subscribers of the feed, slow
+// subscribers which haven't yet consumed the previous events skip it.
+func (h *SSEHandler) broadcast(feed *sseFeed, name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	event := sseEvent{name: name, data: data}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	switch name {
+	case "ticker":
+		feed.lastTicker = &event
+	case "depth":
+		feed.lastDepth = &event
+	}
+
+	for subscriber := range feed.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// poll fetches the market data with the configured interval and broadcasts
+// the changes to the subscribers.
+func (h *SSEHandler) poll(feed *sseFeed) {
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var (
+		lastDealID int32
+		lastTicker *SSETickerEvent
+		lastDepth  *SSEDepthEvent
+	)
+
+	for {
+		deals, err := h.client.MarketDeals(&MarketDealsRequest{
+			Market: feed.market,
+			Limit:  MaxLimit,
+			LastID: lastDealID,
+		})
+		if err != nil {
+			h.broadcastError(feed, err)
+		} else {
+			var fresh MarketDealsResponse
+			maxDealID := lastDealID
+			for _, deal := range deals {
+				if deal.DealID > lastDealID {
+					fresh = append(fresh, deal)
+				}
+
+				if deal.DealID > maxDealID {
+					maxDealID = deal.DealID
+				}
+			}
+			lastDealID = maxDealID
+
+			if len(fresh) != 0 {
+				h.broadcast(feed, "deals", &SSEDealsEvent{
+					Market: feed.market,
+					Deals:  fresh,
+				})
+			}
+		}
+
+		tickerEvent, err := h.fetchTicker(feed.market)
+		if err != nil {
+			h.broadcastError(feed, err)
+		} else if !reflect.DeepEqual(tickerEvent, lastTicker) {
+			lastTicker = tickerEvent
+			h.broadcast(feed, "ticker", tickerEvent)
+		}
+
+		depth, err := h.client.OrderDepth(&OrderDepthRequest{
+			Market:   feed.market,
+			Limit:    h.cfg.DepthLimit,
+			Interval: "0",
+		})
+		if err != nil {
+			h.broadcastError(feed, err)
+		} else {
+			depthEvent := &SSEDepthEvent{
+				Market: feed.market,
+				Asks:   depth.Asks,
+				Bids:   depth.Bids,
+			}
+
+			if !reflect.DeepEqual(depthEvent, lastDepth) {
+				lastDepth = depthEvent
+				h.broadcast(feed, "depth", depthEvent)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-feed.quit:
+			return
+		}
+	}
+}
+
+// fetchTicker fetches the last price and today's status of the market.
+func (h *SSEHandler) fetchTicker(market string) (*SSETickerEvent, error) {
+	last, err := h.client.MarketLast(&MarketLastRequest{
+		Market: market,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	today, err := h.client.MarketStatusToday(&MarketStatusTodayRequest{
+		Market: market,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	event := &SSETickerEvent{
+		Market: market,
+		Today:  today,
+	}
+	if last != nil {
+		event.Last = *last
+	}
+
+	return event, nil
+}
+
+func (h *SSEHandler) broadcastError(feed *sseFeed, err error) {
+	h.broadcast(feed, "error", &SSEErrorEvent{
+		Market: feed.market,
+		Error:  err.Error(),
+	})
+}
@@ -0,0 +1,154 @@
+package viabtc
+
+import (
+	"sync"
+	"time"
+)
+
+// MarketDataStats describes how fresh is the market data which was received
+// by the client, it is used by strategies to refuse quoting on markets with
+// stale data.
+type MarketDataStats struct {
+	Market string `json:"market"`
+
+	// DepthUpdated is the time of the last successful depth fetch.
+	DepthUpdated time.Time `json:"depth_updated"`
+
+	// DepthLatency is the latency of the last successful depth fetch.
+	DepthLatency time.Duration `json:"depth_latency"`
+
+	// LastUpdated is the time of the last successful last price fetch.
+	LastUpdated time.Time `json:"last_updated"`
+
+	// LastLatency is the latency of the last successful last price fetch.
+	LastLatency time.Duration `json:"last_latency"`
+
+	// now returns the current time of the client which returned the
+	// stats, so that ages are measured with the same clock as IsStale.
+	now func() time.Time
+}
+
+// clock returns the current time according to the client clock, or the
+// local one if stats aren't returned by the client.
+func (s MarketDataStats) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+
+	return s.now()
+}
+
+// DepthAge returns how much time has passed since the depth has been fetched
+// last time according to the client clock. If depth has never been fetched
+// the age is unbounded.
+func (s MarketDataStats) DepthAge() time.Duration {
+	return age(s.DepthUpdated, s.clock())
+}
+
+// LastAge returns how much time has passed since the last price has been
+// fetched last time according to the client clock. If price has never been
+// fetched the age is unbounded.
+func (s MarketDataStats) LastAge() time.Duration {
+	return age(s.LastUpdated, s.clock())
+}
+
+// age returns the time passed since t till now, it is unbounded if t is
+// zero.
+func age(t, now time.Time) time.Duration {
+	if t.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+
+	return now.Sub(t)
+}
+
+// marketStats keeps track of the freshness of the market data.
+type marketStats struct {
+	sync.RWMutex
+	markets map[string]*MarketDataStats
+}
+
+func newMarketStats() *marketStats {
+	return &marketStats{
+		markets: make(map[string]*MarketDataStats),
+	}
+}
+
+func (s *marketStats) get(market string) *MarketDataStats {
+	stats, ok := s.markets[market]
+	if !ok {
+		stats = &MarketDataStats{Market: market}
+		s.markets[market] = stats
+	}
+
+	return stats
+}
+
+// recordDepth is used to notify that depth of the market has been fetched.
+func (s *marketStats) recordDepth(market string, updated time.Time,
+	latency time.Duration) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	stats := s.get(market)
+	stats.DepthUpdated = updated
+	stats.DepthLatency = latency
+}
+
+// recordLast is used to notify that last price of the market has been
+// fetched.
+func (s *marketStats) recordLast(market string, updated time.Time,
+	latency time.Duration) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	stats := s.get(market)
+	stats.LastUpdated = updated
+	stats.LastLatency = latency
+}
+
+// MarketStats returns the freshness of the market data of the given market,
+// which might be given either by the engine name or by the unified symbol.
+// Second returned value is false if no data has been fetched for the market.
+func (e *Client) MarketStats(market string) (MarketDataStats, bool) {
+	e.stats.RLock()
+	defer e.stats.RUnlock()
+
+	stats, ok := e.stats.markets[e.marketKey(market)]
+	if !ok {
+		return MarketDataStats{Market: market, now: e.now}, false
+	}
+
+	s := *stats
+	s.now = e.now
+	return s, true
+}
+
+// AllMarketStats returns the freshness of the data of all markets which have
+// been requested by the client, keyed by the engine market names.
+func (e *Client) AllMarketStats() map[string]MarketDataStats {
+	e.stats.RLock()
+	defer e.stats.RUnlock()
+
+	all := make(map[string]MarketDataStats, len(e.stats.markets))
+	for market, stats := range e.stats.markets {
+		s := *stats
+		s.now = e.now
+		all[market] = s
+	}
+
+	return all
+}
+
+// IsStale returns true if either depth or last price of the market haven't
+// been fetched within the given period of time according to the client
+// clock.
+func (e *Client) IsStale(market string, maxAge time.Duration) bool {
+	stats, _ := e.MarketStats(market)
+	now := e.now()
+
+	return age(stats.DepthUpdated, now) > maxAge ||
+		age(stats.LastUpdated, now) > maxAge
+}
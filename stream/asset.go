@@ -0,0 +1,45 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// BalanceUpdate is the new balances of the assets of the authorized user,
+// only the changed assets are included.
+type BalanceUpdate struct {
+	Balances viabtc.BalanceQueryResponse
+}
+
+// SubscribeBalance subscribes to the balances of the given assets of the
+// authorized user, or all assets if none is given, it is the push
+// alternative to polling BalanceQuery. The connection should be authorized
+// with Authenticate or Sign. The server keeps only one asset subscription
+// per connection, so the channel of the previous subscription is closed.
+func (c *Client) SubscribeBalance(ctx context.Context,
+	assets ...string) (<-chan *BalanceUpdate, error) {
+
+	return subscribe(ctx, c, &c.balance, "asset.subscribe",
+		marketParams(assets), "asset.update", c.handleBalance)
+}
+
+// UnsubscribeBalance cancels the asset subscription and closes its
+// channel.
+func (c *Client) UnsubscribeBalance(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.balance, "asset.unsubscribe")
+}
+
+// handleBalance decodes the asset notification, its params are
+// [{asset: {available, freeze}}].
+func (c *Client) handleBalance(params json.RawMessage) error {
+	update := &BalanceUpdate{}
+	if err := decodeParams(params, &update.Balances); err != nil {
+		return err
+	}
+
+	c.balance.push(update)
+	c.registry.dispatch(balanceKind, update)
+	return nil
+}
@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Authenticate authorizes the connection with the token issued by the web
+// backend of the exchange, which is verified by accessws using its auth
+// url. Source is the name of the client, e.g. "web". Authorization is
+// required by the user subscriptions, such as orders and assets.
+func (c *Client) Authenticate(ctx context.Context, token, source string) error {
+	return c.Call(ctx, "server.auth", []interface{}{token, source}, nil)
+}
+
+// Sign authorizes the connection with the api key, the signature is
+// verified by accessws using its sign url. Authorization is required by
+// the user subscriptions, such as orders and assets.
+func (c *Client) Sign(ctx context.Context, accessID, secretKey string) error {
+	tonce := time.Now().UnixNano() / int64(time.Millisecond)
+
+	return c.Call(ctx, "server.sign", []interface{}{
+		accessID, signature(accessID, secretKey, tonce), tonce,
+	}, nil)
+}
+
+// signature returns the signature of the sign request, which is the upper
+// case hex md5 of "access_id=<id>&tonce=<tonce>&secret_key=<key>".
+func signature(accessID, secretKey string, tonce int64) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("access_id=%v&tonce=%v&secret_key=%v",
+		accessID, tonce, secretKey)))
+
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
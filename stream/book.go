@@ -0,0 +1,323 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// ErrBookGap is the reason of the resynchronization of the order book,
+// either the depth update has been dropped or the delta has arrived before
+// the snapshot.
+var ErrBookGap = errors.New("gap in depth updates")
+
+// level is the price level of the order book with the parsed price.
+type level struct {
+	price *big.Rat
+	depth viabtc.Depth
+}
+
+// OrderBook is the local copy of the order book of the market, which is
+// maintained by applying the updates of the depth subscription. If an
+// update is missed the book is resynchronized by subscribing again, and
+// isn't ready until the new snapshot is received. It is safe for
+// concurrent use.
+//
+// The book uses the depth subscription of the client, so it shouldn't be
+// shared with SubscribeDepth or another book.
+type OrderBook struct {
+	client   *Client
+	market   string
+	limit    int32
+	interval string
+
+	mtx   sync.RWMutex
+	asks  []level
+	bids  []level
+	seq   uint64
+	ready bool
+	gaps  uint64
+	err   error
+
+	// closing is set by Close, so that the book isn't resynchronized
+	// after the subscription is canceled.
+	closing bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewOrderBook subscribes to the order book of the market with the given
+// number of levels and the merge interval of the prices, and maintains its
+// local copy until the book is closed.
+func NewOrderBook(ctx context.Context, c *Client, market string,
+	limit int32, interval string) (*OrderBook, error) {
+
+	b := &OrderBook{
+		client:   c,
+		market:   market,
+		limit:    limit,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+
+	updates, err := c.SubscribeDepth(ctx, market, limit, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	go b.run(updates)
+
+	return b, nil
+}
+
+// run applies the updates until the subscription is canceled, and
+// resubscribes on the gaps.
+func (b *OrderBook) run(updates <-chan *DepthUpdate) {
+	defer close(b.done)
+
+	for {
+		var err error
+		for update := range updates {
+			if err = b.apply(update); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			// Subscription has been canceled or the client has been
+			// closed.
+			b.stop(b.client.Err())
+			return
+		}
+
+		if !errors.Is(err, ErrBookGap) {
+			b.stop(err)
+			return
+		}
+
+		b.mtx.RLock()
+		closing := b.closing
+		b.mtx.RUnlock()
+
+		if closing {
+			b.stop(nil)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			b.client.cfg.CallTimeout)
+		updates, err = b.client.SubscribeDepth(ctx, b.market, b.limit,
+			b.interval)
+		cancel()
+
+		if err != nil {
+			b.stop(fmt.Errorf("unable to resynchronize book: %w", err))
+			return
+		}
+	}
+}
+
+// stop marks the book as stopped with the given reason.
+func (b *OrderBook) stop(err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.ready = false
+	b.err = err
+}
+
+// apply applies the update to the book, ErrBookGap is returned if the
+// book should be resynchronized.
+func (b *OrderBook) apply(update *DepthUpdate) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if update.Clean {
+		b.asks = b.asks[:0]
+		b.bids = b.bids[:0]
+	} else if !b.ready || update.Seq != b.seq+1 {
+		b.ready = false
+		b.gaps++
+		return ErrBookGap
+	}
+
+	var err error
+	if b.asks, err = b.merge(b.asks, update.Asks, false); err != nil {
+		return err
+	}
+	if b.bids, err = b.merge(b.bids, update.Bids, true); err != nil {
+		return err
+	}
+
+	b.seq = update.Seq
+	b.ready = true
+	return nil
+}
+
+// merge applies the changed levels to the side of the book, asks are
+// sorted by the price ascending and bids descending. Level with zero
+// volume is removed.
+func (b *OrderBook) merge(levels []level, changes []viabtc.Depth,
+	desc bool) ([]level, error) {
+
+	for _, change := range changes {
+		price, ok := new(big.Rat).SetString(change.Price)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse price: %q",
+				change.Price)
+		}
+
+		volume, ok := new(big.Rat).SetString(change.Volume)
+		if !ok {
+			return nil, fmt.Errorf("unable to parse volume: %q",
+				change.Volume)
+		}
+
+		i := sort.Search(len(levels), func(i int) bool {
+			if desc {
+				return levels[i].price.Cmp(price) <= 0
+			}
+			return levels[i].price.Cmp(price) >= 0
+		})
+		found := i < len(levels) && levels[i].price.Cmp(price) == 0
+
+		switch {
+		case volume.Sign() == 0 && found:
+			levels = append(levels[:i], levels[i+1:]...)
+
+		case volume.Sign() == 0:
+
+		case found:
+			levels[i].depth = change
+
+		default:
+			levels = append(levels, level{})
+			copy(levels[i+1:], levels[i:])
+			levels[i] = level{price: price, depth: change}
+		}
+	}
+
+	if b.limit > 0 && len(levels) > int(b.limit) {
+		levels = levels[:b.limit]
+	}
+
+	return levels, nil
+}
+
+// Market returns the market of the book.
+func (b *OrderBook) Market() string {
+	return b.market
+}
+
+// Ready returns true if the book is synchronized with the server.
+func (b *OrderBook) Ready() bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.ready
+}
+
+// Gaps returns the number of the detected gaps, after which the book has
+// been resynchronized.
+func (b *OrderBook) Gaps() uint64 {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.gaps
+}
+
+// BestBid returns the highest bid, false if there are no bids or the book
+// isn't ready.
+func (b *OrderBook) BestBid() (viabtc.Depth, bool) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if !b.ready || len(b.bids) == 0 {
+		return viabtc.Depth{}, false
+	}
+
+	return b.bids[0].depth, true
+}
+
+// BestAsk returns the lowest ask, false if there are no asks or the book
+// isn't ready.
+func (b *OrderBook) BestAsk() (viabtc.Depth, bool) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if !b.ready || len(b.asks) == 0 {
+		return viabtc.Depth{}, false
+	}
+
+	return b.asks[0].depth, true
+}
+
+// Levels returns up to n best levels of both sides of the book, all levels
+// if n isn't positive, or nothing if the book isn't ready.
+func (b *OrderBook) Levels(n int) (asks, bids []viabtc.Depth) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if !b.ready {
+		return nil, nil
+	}
+
+	return depths(b.asks, n), depths(b.bids, n)
+}
+
+// depths returns up to n first levels.
+func depths(levels []level, n int) []viabtc.Depth {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+
+	result := make([]viabtc.Depth, n)
+	for i := range result {
+		result[i] = levels[i].depth
+	}
+
+	return result
+}
+
+// Err returns the reason why the book has stopped, nil if it is still
+// running or has been closed by Close.
+func (b *OrderBook) Err() error {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return b.err
+}
+
+// Done returns the channel which is closed when the book stops.
+func (b *OrderBook) Done() <-chan struct{} {
+	return b.done
+}
+
+// Close cancels the depth subscription and waits until the book stops.
+func (b *OrderBook) Close(ctx context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.mtx.Lock()
+		b.closing = true
+		b.mtx.Unlock()
+
+		err = b.client.UnsubscribeDepth(ctx)
+	})
+
+	if err != nil && !errors.Is(err, ErrClosed) {
+		return err
+	}
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
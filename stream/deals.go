@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Deal is the public trade of the market.
+type Deal struct {
+	ID     int64   `json:"id"`
+	Time   float64 `json:"time"`
+	Price  string  `json:"price"`
+	Amount string  `json:"amount"`
+
+	// Type is the side of the taker, "buy" or "sell".
+	Type string `json:"type"`
+}
+
+// DealsUpdate is the batch of the new deals of the market.
+type DealsUpdate struct {
+	Market string
+
+	// Snapshot is true for the first update of the market after the
+	// subscription, which contains the recent deals rather than the new
+	// ones.
+	Snapshot bool
+
+	Deals []Deal
+}
+
+// dealsState tracks which markets have received the snapshot since the
+// last subscription.
+type dealsState struct {
+	subscription[*DealsUpdate]
+
+	seenMtx sync.Mutex
+	seen    map[string]struct{}
+}
+
+func (s *dealsState) reset() {
+	s.seenMtx.Lock()
+	defer s.seenMtx.Unlock()
+
+	s.seen = make(map[string]struct{})
+}
+
+// snapshot returns true if the update of the market is the first one.
+func (s *dealsState) snapshot(market string) bool {
+	s.seenMtx.Lock()
+	defer s.seenMtx.Unlock()
+
+	if _, ok := s.seen[market]; ok {
+		return false
+	}
+
+	s.seen[market] = struct{}{}
+	return true
+}
+
+// SubscribeDeals subscribes to the deals of the given markets. The first
+// update of every market is the snapshot of its recent deals. The server
+// keeps only one deals subscription per connection, so the channel of the
+// previous subscription is closed.
+func (c *Client) SubscribeDeals(ctx context.Context,
+	markets ...string) (<-chan *DealsUpdate, error) {
+
+	c.deals.reset()
+
+	return subscribe(ctx, c, &c.deals.subscription, "deals.subscribe",
+		marketParams(markets), "deals.update", c.handleDeals)
+}
+
+// UnsubscribeDeals cancels the deals subscription and closes its channel.
+func (c *Client) UnsubscribeDeals(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.deals.subscription, "deals.unsubscribe")
+}
+
+// handleDeals decodes the deals notification, its params are
+// [market, [deal, ...]].
+func (c *Client) handleDeals(params json.RawMessage) error {
+	update := &DealsUpdate{}
+	if err := decodeParams(params, &update.Market, &update.Deals); err != nil {
+		return err
+	}
+
+	update.Snapshot = c.deals.snapshot(update.Market)
+	c.deals.push(update)
+	c.registry.dispatch(dealsKind, update)
+	return nil
+}
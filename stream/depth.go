@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// DepthUpdate is the update of the order book of the market.
+type DepthUpdate struct {
+	Market string
+
+	// Clean is true if the update is the full snapshot of the book which
+	// replaces the previous state, otherwise it contains only the changed
+	// levels, and the level with zero volume should be removed.
+	Clean bool
+
+	Asks []viabtc.Depth
+	Bids []viabtc.Depth
+
+	// Seq is the number of the update since the subscription, starting
+	// from 1. Gap in the numbers means that the updates have been dropped
+	// because the channel was full, and the book should be resynchronized.
+	Seq uint64
+}
+
+// depthState numbers the updates of the depth subscription.
+type depthState struct {
+	subscription[*DepthUpdate]
+
+	seq atomic.Uint64
+}
+
+// SubscribeDepth subscribes to the order book of the market with the given
+// number of levels and the merge interval of the prices, e.g. "0" or
+// "0.01". The first update is the clean snapshot of the book, the
+// following ones contain the changed levels. The server keeps only one
+// depth subscription per connection, so the channel of the previous
+// subscription is closed.
+func (c *Client) SubscribeDepth(ctx context.Context, market string,
+	limit int32, interval string) (<-chan *DepthUpdate, error) {
+
+	c.depth.seq.Store(0)
+
+	return subscribe(ctx, c, &c.depth.subscription, "depth.subscribe",
+		[]interface{}{market, limit, interval}, "depth.update",
+		c.handleDepth)
+}
+
+// UnsubscribeDepth cancels the depth subscription and closes its channel.
+func (c *Client) UnsubscribeDepth(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.depth.subscription,
+		"depth.unsubscribe")
+}
+
+// handleDepth decodes the depth notification, its params are
+// [clean, {"asks": [...], "bids": [...]}, market].
+func (c *Client) handleDepth(params json.RawMessage) error {
+	update := &DepthUpdate{}
+
+	var book struct {
+		Asks []viabtc.Depth `json:"asks"`
+		Bids []viabtc.Depth `json:"bids"`
+	}
+
+	err := decodeParams(params, &update.Clean, &book, &update.Market)
+	if err != nil {
+		return err
+	}
+	update.Asks = book.Asks
+	update.Bids = book.Bids
+	update.Seq = c.depth.seq.Add(1)
+
+	c.depth.push(update)
+	c.registry.dispatch(depthKind, update)
+	return nil
+}
@@ -0,0 +1,176 @@
+package stream
+
+import (
+	"context"
+)
+
+// Event is the update of any subscription: *DepthUpdate, *DealsUpdate,
+// *PriceUpdate, *StateUpdate, *OrderUpdateEvent or *BalanceUpdate.
+type Event interface {
+	isEvent()
+}
+
+func (*DepthUpdate) isEvent()      {}
+func (*DealsUpdate) isEvent()      {}
+func (*PriceUpdate) isEvent()      {}
+func (*StateUpdate) isEvent()      {}
+func (*OrderUpdateEvent) isEvent() {}
+func (*BalanceUpdate) isEvent()    {}
+
+// Topic describes the subscription made with Subscribe, SubscribeFunc or
+// NewSubscription: DepthTopic, DealsTopic, PriceTopic, StateTopic,
+// OrdersTopic or BalanceTopic.
+type Topic interface {
+	subscribe(ctx context.Context, c *Client) (<-chan Event, error)
+
+	// kind returns the kind of the server subscription of the topic.
+	kind() *topicKind
+
+	// filter returns the part of the event which matches the topic of the
+	// multiplexed subscription, nil if nothing matches.
+	filter(s *Subscription, event Event) Event
+}
+
+// DepthTopic is the subscription to the order book of the market, see
+// SubscribeDepth.
+type DepthTopic struct {
+	Market   string
+	Limit    int32
+	Interval string
+}
+
+func (t DepthTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribeDepth(ctx, t.Market, t.Limit, t.Interval)
+	return events(c, updates, err)
+}
+
+// DealsTopic is the subscription to the deals of the markets, see
+// SubscribeDeals.
+type DealsTopic struct {
+	Markets []string
+}
+
+func (t DealsTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribeDeals(ctx, t.Markets...)
+	return events(c, updates, err)
+}
+
+// PriceTopic is the subscription to the last prices of the markets, see
+// SubscribePrice.
+type PriceTopic struct {
+	Markets []string
+}
+
+func (t PriceTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribePrice(ctx, t.Markets...)
+	return events(c, updates, err)
+}
+
+// StateTopic is the subscription to the state of the markets, see
+// SubscribeState.
+type StateTopic struct {
+	Markets []string
+}
+
+func (t StateTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribeState(ctx, t.Markets...)
+	return events(c, updates, err)
+}
+
+// OrdersTopic is the subscription to the orders of the authorized user,
+// see SubscribeOrders.
+type OrdersTopic struct {
+	Markets []string
+}
+
+func (t OrdersTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribeOrders(ctx, t.Markets...)
+	return events(c, updates, err)
+}
+
+// BalanceTopic is the subscription to the balances of the authorized user,
+// see SubscribeBalance.
+type BalanceTopic struct {
+	Assets []string
+}
+
+func (t BalanceTopic) subscribe(ctx context.Context, c *Client) (<-chan Event,
+	error) {
+
+	updates, err := c.SubscribeBalance(ctx, t.Assets...)
+	return events(c, updates, err)
+}
+
+// events forwards the typed updates of the subscription to the channel of
+// the events, which is closed with the channel of the updates.
+func events[T Event](c *Client, updates <-chan T,
+	err error) (<-chan Event, error) {
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		for update := range updates {
+			select {
+			case out <- update:
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Subscribe makes the subscription described by the topic and returns the
+// channel of its events, it is the alternative to the typed subscription
+// methods for the applications which handle the updates of all kinds in
+// the single place. As with the typed methods, the channel of the previous
+// subscription of the same kind is closed.
+func (c *Client) Subscribe(ctx context.Context, topic Topic) (<-chan Event,
+	error) {
+
+	return topic.subscribe(ctx, c)
+}
+
+// SubscribeFunc makes the subscription described by the topic and calls
+// the handler with its events in the separate goroutine, one event at a
+// time. If the subscription ends because the connection is lost, onError,
+// if not nil, is called with the reason, it isn't called if the
+// subscription is canceled or the client is closed by Close.
+func (c *Client) SubscribeFunc(ctx context.Context, topic Topic,
+	handler func(Event), onError func(error)) error {
+
+	events, err := c.Subscribe(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for event := range events {
+			handler(event)
+		}
+
+		if onError != nil {
+			if err := c.Err(); err != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return nil
+}
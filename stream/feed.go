@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy defines what happens with the update of the subscription
+// whose channel is full because the consumer doesn't keep up.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the new update, so that the consumer receives the
+	// queued ones.
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest drops the oldest queued update to make room for the new
+	// one, so that the consumer receives the most recent state.
+	DropOldest
+
+	// Block waits until the consumer receives the queued update. Nothing
+	// is lost, but the reading of the connection is blocked, including the
+	// responses to the calls and the updates of other subscriptions.
+	Block
+)
+
+// feed is the channel of the subscription updates which is safe to be
+// closed concurrently with the sends.
+type feed[T any] struct {
+	policy  OverflowPolicy
+	dropped *atomic.Uint64
+
+	mtx    sync.Mutex
+	ch     chan T
+	closed bool
+
+	// done is closed before the channel, so that the blocked send is
+	// interrupted.
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newFeed[T any](buffer int, policy OverflowPolicy,
+	dropped *atomic.Uint64) *feed[T] {
+
+	return &feed[T]{
+		policy:  policy,
+		dropped: dropped,
+		ch:      make(chan T, buffer),
+		done:    make(chan struct{}),
+	}
+}
+
+// push sends the update to the channel, the full channel is handled
+// according to the overflow policy.
+func (f *feed[T]) push(update T) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	select {
+	case f.ch <- update:
+		return
+	default:
+	}
+
+	switch f.policy {
+	case Block:
+		select {
+		case f.ch <- update:
+		case <-f.done:
+		}
+
+	case DropOldest:
+		for {
+			select {
+			case <-f.ch:
+				f.dropped.Add(1)
+			default:
+			}
+
+			select {
+			case f.ch <- update:
+				return
+			default:
+			}
+		}
+
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// close closes the channel of the feed.
+func (f *feed[T]) close() {
+	f.doneOnce.Do(func() {
+		close(f.done)
+	})
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if !f.closed {
+		f.closed = true
+		close(f.ch)
+	}
+}
@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// PriceUpdate is the new last price of the market.
+type PriceUpdate struct {
+	Market string
+	Price  string
+}
+
+// MarketState is the statistics of the market for the last 24 hours.
+type MarketState struct {
+	// Period is the length of the window in seconds.
+	Period int64  `json:"period"`
+	Last   string `json:"last"`
+	Open   string `json:"open"`
+	Close  string `json:"close"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Volume string `json:"volume"`
+
+	// Deal is the traded amount of the money asset.
+	Deal string `json:"deal"`
+}
+
+// StateUpdate is the new state of the market.
+type StateUpdate struct {
+	Market string
+	State  MarketState
+}
+
+// marketParams returns the params of the subscription to the markets or
+// the assets.
+func marketParams(markets []string) []interface{} {
+	params := make([]interface{}, len(markets))
+	for i, market := range markets {
+		params[i] = market
+	}
+
+	return params
+}
+
+// SubscribePrice subscribes to the last prices of the given markets, it is
+// the push alternative to polling MarketLast. The server keeps only one
+// price subscription per connection, so the channel of the previous
+// subscription is closed.
+func (c *Client) SubscribePrice(ctx context.Context,
+	markets ...string) (<-chan *PriceUpdate, error) {
+
+	return subscribe(ctx, c, &c.price, "price.subscribe",
+		marketParams(markets), "price.update", c.handlePrice)
+}
+
+// UnsubscribePrice cancels the price subscription and closes its channel.
+func (c *Client) UnsubscribePrice(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.price, "price.unsubscribe")
+}
+
+// handlePrice decodes the price notification, its params are
+// [market, price].
+func (c *Client) handlePrice(params json.RawMessage) error {
+	update := &PriceUpdate{}
+	if err := decodeParams(params, &update.Market, &update.Price); err != nil {
+		return err
+	}
+
+	c.price.push(update)
+	c.registry.dispatch(priceKind, update)
+	return nil
+}
+
+// SubscribeState subscribes to the 24 hours statistics of the given
+// markets, it is the push alternative to polling MarketStatusToday. The
+// server keeps only one state subscription per connection, so the channel
+// of the previous subscription is closed.
+func (c *Client) SubscribeState(ctx context.Context,
+	markets ...string) (<-chan *StateUpdate, error) {
+
+	return subscribe(ctx, c, &c.state, "state.subscribe",
+		marketParams(markets), "state.update", c.handleState)
+}
+
+// UnsubscribeState cancels the state subscription and closes its channel.
+func (c *Client) UnsubscribeState(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.state, "state.unsubscribe")
+}
+
+// handleState decodes the state notification, its params are
+// [market, state].
+func (c *Client) handleState(params json.RawMessage) error {
+	update := &StateUpdate{}
+	if err := decodeParams(params, &update.Market, &update.State); err != nil {
+		return err
+	}
+
+	c.state.push(update)
+	c.registry.dispatch(stateKind, update)
+	return nil
+}
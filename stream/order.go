@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// OrderEvent is the kind of the change of the user order.
+type OrderEvent int
+
+const (
+	// OrderPut is sent when the order is placed.
+	OrderPut OrderEvent = 1
+
+	// OrderUpdate is sent when the order is partially executed.
+	OrderUpdate OrderEvent = 2
+
+	// OrderFinish is sent when the order is fully executed or canceled.
+	OrderFinish OrderEvent = 3
+)
+
+func (e OrderEvent) String() string {
+	switch e {
+	case OrderPut:
+		return "put"
+	case OrderUpdate:
+		return "update"
+	case OrderFinish:
+		return "finish"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(e))
+	}
+}
+
+// OrderUpdateEvent is the change of the order of the authorized user.
+type OrderUpdateEvent struct {
+	Event OrderEvent
+	Order viabtc.OrderDetailedInfo
+}
+
+// SubscribeOrders subscribes to the orders of the authorized user on the
+// given markets, the connection should be authorized with Authenticate or
+// Sign. The server keeps only one order subscription per connection, so
+// the channel of the previous subscription is closed.
+func (c *Client) SubscribeOrders(ctx context.Context,
+	markets ...string) (<-chan *OrderUpdateEvent, error) {
+
+	return subscribe(ctx, c, &c.orders, "order.subscribe",
+		marketParams(markets), "order.update", c.handleOrder)
+}
+
+// UnsubscribeOrders cancels the order subscription and closes its channel.
+func (c *Client) UnsubscribeOrders(ctx context.Context) error {
+	return unsubscribe(ctx, c, &c.orders, "order.unsubscribe")
+}
+
+// handleOrder decodes the order notification, its params are
+// [event, order].
+func (c *Client) handleOrder(params json.RawMessage) error {
+	update := &OrderUpdateEvent{}
+	if err := decodeParams(params, &update.Event, &update.Order); err != nil {
+		return err
+	}
+
+	c.orders.push(update)
+	c.registry.dispatch(ordersKind, update)
+	return nil
+}
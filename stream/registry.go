@@ -0,0 +1,510 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+)
+
+// ErrTopicConflict is returned if the topic can't be multiplexed with the
+// active subscriptions of the same kind, e.g. the order books of different
+// markets, since the server keeps only one depth subscription per
+// connection.
+var ErrTopicConflict = errors.New("topic conflicts with active subscription")
+
+// topicKind is the kind of the server subscription, the topics of the same
+// kind are multiplexed into the single subscription request.
+type topicKind struct {
+	subscribe    string
+	unsubscribe  string
+	notification string
+
+	// params returns the params of the subscription request which
+	// covers all given topics.
+	params func(topics []Topic) ([]interface{}, error)
+
+	// reset, if not nil, is called before the subscription request.
+	reset func(c *Client)
+}
+
+var (
+	depthKind = &topicKind{
+		subscribe:    "depth.subscribe",
+		unsubscribe:  "depth.unsubscribe",
+		notification: "depth.update",
+		params:       depthParams,
+		reset:        func(c *Client) { c.depth.seq.Store(0) },
+	}
+
+	dealsKind = &topicKind{
+		subscribe:    "deals.subscribe",
+		unsubscribe:  "deals.unsubscribe",
+		notification: "deals.update",
+		params:       listParams,
+		reset:        func(c *Client) { c.deals.reset() },
+	}
+
+	priceKind = &topicKind{
+		subscribe:    "price.subscribe",
+		unsubscribe:  "price.unsubscribe",
+		notification: "price.update",
+		params:       listParams,
+	}
+
+	stateKind = &topicKind{
+		subscribe:    "state.subscribe",
+		unsubscribe:  "state.unsubscribe",
+		notification: "state.update",
+		params:       listParams,
+	}
+
+	ordersKind = &topicKind{
+		subscribe:    "order.subscribe",
+		unsubscribe:  "order.unsubscribe",
+		notification: "order.update",
+		params:       listParams,
+	}
+
+	balanceKind = &topicKind{
+		subscribe:    "asset.subscribe",
+		unsubscribe:  "asset.unsubscribe",
+		notification: "asset.update",
+		params:       listParams,
+	}
+)
+
+// kindHandler returns the handler of the notifications of the kind.
+func (c *Client) kindHandler(kind *topicKind) func(params json.RawMessage) error {
+	switch kind {
+	case depthKind:
+		return c.handleDepth
+	case dealsKind:
+		return c.handleDeals
+	case priceKind:
+		return c.handlePrice
+	case stateKind:
+		return c.handleState
+	case ordersKind:
+		return c.handleOrder
+	default:
+		return c.handleBalance
+	}
+}
+
+// depthParams returns the params of the depth subscription, all depth
+// topics should be the same.
+func depthParams(topics []Topic) ([]interface{}, error) {
+	first := topics[0].(DepthTopic)
+	for _, topic := range topics[1:] {
+		if topic.(DepthTopic) != first {
+			return nil, fmt.Errorf("%w: depth of %v", ErrTopicConflict,
+				first.Market)
+		}
+	}
+
+	return []interface{}{first.Market, first.Limit, first.Interval}, nil
+}
+
+// listParams returns the union of the markets or assets of the topics,
+// the topic without them covers everything, and so does the union.
+func listParams(topics []Topic) ([]interface{}, error) {
+	seen := make(map[string]struct{})
+	var names []string
+
+	for _, topic := range topics {
+		list := topic.(interface{ list() []string }).list()
+		if len(list) == 0 {
+			return []interface{}{}, nil
+		}
+
+		for _, name := range list {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return marketParams(names), nil
+}
+
+// contains returns true if the list is empty, which means everything, or
+// contains the name.
+func contains(list []string, name string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, item := range list {
+		if item == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t DepthTopic) kind() *topicKind   { return depthKind }
+func (t DealsTopic) kind() *topicKind   { return dealsKind }
+func (t PriceTopic) kind() *topicKind   { return priceKind }
+func (t StateTopic) kind() *topicKind   { return stateKind }
+func (t OrdersTopic) kind() *topicKind  { return ordersKind }
+func (t BalanceTopic) kind() *topicKind { return balanceKind }
+
+func (t DealsTopic) list() []string   { return t.Markets }
+func (t PriceTopic) list() []string   { return t.Markets }
+func (t StateTopic) list() []string   { return t.Markets }
+func (t OrdersTopic) list() []string  { return t.Markets }
+func (t BalanceTopic) list() []string { return t.Assets }
+
+func (t DepthTopic) filter(s *Subscription, event Event) Event {
+	return event
+}
+
+func (t DealsTopic) filter(s *Subscription, event Event) Event {
+	update := event.(*DealsUpdate)
+	if !contains(t.Markets, update.Market) {
+		return nil
+	}
+
+	// Server repeats the snapshot of every market when the multiplexed
+	// subscription is extended, it is delivered only once.
+	if _, ok := s.seen[update.Market]; ok && update.Snapshot {
+		return nil
+	}
+	s.seen[update.Market] = struct{}{}
+
+	return event
+}
+
+func (t PriceTopic) filter(s *Subscription, event Event) Event {
+	if !contains(t.Markets, event.(*PriceUpdate).Market) {
+		return nil
+	}
+	return event
+}
+
+func (t StateTopic) filter(s *Subscription, event Event) Event {
+	if !contains(t.Markets, event.(*StateUpdate).Market) {
+		return nil
+	}
+	return event
+}
+
+func (t OrdersTopic) filter(s *Subscription, event Event) Event {
+	if !contains(t.Markets, event.(*OrderUpdateEvent).Order.Market.String()) {
+		return nil
+	}
+	return event
+}
+
+func (t BalanceTopic) filter(s *Subscription, event Event) Event {
+	update := event.(*BalanceUpdate)
+	if len(t.Assets) == 0 {
+		return event
+	}
+
+	balances := make(viabtc.BalanceQueryResponse)
+	for asset, balance := range update.Balances {
+		if contains(t.Assets, string(asset)) {
+			balances[asset] = balance
+		}
+	}
+
+	if len(balances) == 0 {
+		return nil
+	}
+
+	return &BalanceUpdate{Balances: balances}
+}
+
+// Subscription is the subscription of the registry of the client, the
+// subscriptions of the same kind are multiplexed over the single server
+// subscription, and the events are routed to them by the markets or the
+// assets of their topics.
+type Subscription struct {
+	id     uint64
+	topic  Topic
+	client *Client
+	feed   *feed[Event]
+
+	// seen is the markets whose deals snapshot has been delivered, it is
+	// accessed only by the read loop.
+	seen map[string]struct{}
+}
+
+// ID returns the identifier of the subscription, unique for the client.
+func (s *Subscription) ID() uint64 {
+	return s.id
+}
+
+// Topic returns the topic of the subscription.
+func (s *Subscription) Topic() Topic {
+	return s.topic
+}
+
+// Events returns the channel of the events of the subscription, which is
+// closed when the subscription is canceled or the client is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.feed.ch
+}
+
+// Unsubscribe cancels the subscription and closes its channel, the server
+// subscription is narrowed to the remaining subscriptions of the same kind
+// or canceled if there are none.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	return s.client.registry.remove(ctx, s)
+}
+
+// kindState is the state of the server subscription of the kind.
+type kindState struct {
+	subs   map[uint64]*Subscription
+	params []interface{}
+}
+
+// registry multiplexes the subscriptions of the client.
+type registry struct {
+	client *Client
+
+	// updateMtx serializes the changes of the server subscriptions, it
+	// isn't held by the dispatch, so that the notifications don't block
+	// the changes.
+	updateMtx sync.Mutex
+
+	mtx    sync.Mutex
+	nextID uint64
+	kinds  map[*topicKind]*kindState
+	closed bool
+}
+
+// topics returns the topics of the subscriptions of the kind ordered by
+// the subscription id.
+func (st *kindState) topics() []Topic {
+	subs := make([]*Subscription, 0, len(st.subs))
+	for _, s := range st.subs {
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].id < subs[j].id })
+
+	topics := make([]Topic, len(subs))
+	for i, s := range subs {
+		topics[i] = s.topic
+	}
+
+	return topics
+}
+
+// add registers the subscription and extends the server subscription of
+// its kind if needed.
+func (r *registry) add(ctx context.Context, topic Topic) (*Subscription,
+	error) {
+
+	c := r.client
+	kind := topic.kind()
+
+	r.updateMtx.Lock()
+	defer r.updateMtx.Unlock()
+
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		return nil, c.closeErr()
+	}
+
+	st, ok := r.kinds[kind]
+	if !ok {
+		st = &kindState{subs: make(map[uint64]*Subscription)}
+		r.kinds[kind] = st
+	}
+
+	r.nextID++
+	s := &Subscription{
+		id:     r.nextID,
+		topic:  topic,
+		client: c,
+		feed:   newFeed[Event](c.cfg.Buffer, c.cfg.Overflow, &c.dropped),
+		seen:   make(map[string]struct{}),
+	}
+
+	// Subscription is registered before the request, since the server
+	// might send the first notification before the response.
+	st.subs[s.id] = s
+	params, err := kind.params(st.topics())
+	previous := st.params
+	r.mtx.Unlock()
+
+	if err == nil {
+		err = r.update(ctx, kind, st, previous, params)
+	}
+
+	if err != nil {
+		r.mtx.Lock()
+		delete(st.subs, s.id)
+		r.mtx.Unlock()
+
+		s.feed.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// remove unregisters the subscription and narrows or cancels the server
+// subscription of its kind.
+func (r *registry) remove(ctx context.Context, s *Subscription) error {
+	kind := s.topic.kind()
+
+	r.updateMtx.Lock()
+	defer r.updateMtx.Unlock()
+
+	r.mtx.Lock()
+	st, ok := r.kinds[kind]
+	if !ok || st.subs[s.id] == nil {
+		r.mtx.Unlock()
+		return nil
+	}
+
+	delete(st.subs, s.id)
+	previous := st.params
+
+	var (
+		params []interface{}
+		err    error
+	)
+	if len(st.subs) != 0 {
+		params, err = kind.params(st.topics())
+	}
+	r.mtx.Unlock()
+
+	s.feed.close()
+
+	if err != nil {
+		return err
+	}
+
+	return r.update(ctx, kind, st, previous, params)
+}
+
+// update makes the server subscription request if its params have changed,
+// or cancels it if params is nil.
+func (r *registry) update(ctx context.Context, kind *topicKind,
+	st *kindState, previous, params []interface{}) error {
+
+	c := r.client
+
+	switch {
+	case params == nil:
+		if err := c.Call(ctx, kind.unsubscribe, nil, nil); err != nil {
+			return err
+		}
+
+	case reflect.DeepEqual(previous, params):
+		return nil
+
+	default:
+		c.handle(kind.notification, c.kindHandler(kind), nil)
+
+		if kind.reset != nil {
+			kind.reset(c)
+		}
+
+		if err := c.Call(ctx, kind.subscribe, params, nil); err != nil {
+			return err
+		}
+	}
+
+	r.mtx.Lock()
+	st.params = params
+	r.mtx.Unlock()
+
+	return nil
+}
+
+// dispatch delivers the event to the subscriptions of the kind whose
+// topics match it.
+func (r *registry) dispatch(kind *topicKind, event Event) {
+	r.mtx.Lock()
+	st, ok := r.kinds[kind]
+	if !ok {
+		r.mtx.Unlock()
+		return
+	}
+
+	type delivery struct {
+		feed  *feed[Event]
+		event Event
+	}
+
+	var deliveries []delivery
+	for _, s := range st.subs {
+		filtered := s.topic.filter(s, event)
+		if filtered != nil {
+			deliveries = append(deliveries, delivery{s.feed, filtered})
+		}
+	}
+	r.mtx.Unlock()
+
+	for _, d := range deliveries {
+		d.feed.push(d.event)
+	}
+}
+
+// list returns the active subscriptions ordered by the id.
+func (r *registry) list() []*Subscription {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var subs []*Subscription
+	for _, st := range r.kinds {
+		for _, s := range st.subs {
+			subs = append(subs, s)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].id < subs[j].id })
+
+	return subs
+}
+
+// close closes the channels of all subscriptions.
+func (r *registry) close() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.closed = true
+	for _, st := range r.kinds {
+		for id, s := range st.subs {
+			s.feed.close()
+			delete(st.subs, id)
+		}
+	}
+}
+
+// NewSubscription registers the subscription described by the topic in
+// the registry of the client. Unlike Subscribe, it doesn't replace the
+// previous subscription of the same kind: the markets or the assets of all
+// subscriptions of the kind are combined into the single server
+// subscription, and the events are routed to the subscriptions whose
+// topics match them. Order books can't be multiplexed, so the depth topics
+// of the active subscriptions should be the same, otherwise
+// ErrTopicConflict is returned.
+//
+// The registry and the typed subscription methods of the same kind
+// shouldn't be used together, since they share the server subscription.
+func (c *Client) NewSubscription(ctx context.Context,
+	topic Topic) (*Subscription, error) {
+
+	return c.registry.add(ctx, topic)
+}
+
+// ActiveSubscriptions returns the active subscriptions of the registry
+// ordered by their ids.
+func (c *Client) ActiveSubscriptions() []*Subscription {
+	return c.registry.list()
+}
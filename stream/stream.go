@@ -0,0 +1,529 @@
+// Package stream implements the client of the WebSocket api of the ViaBTC
+// exchange (accessws), which pushes the market data and the user events
+// instead of polling the engine over http:
+//
+//	client, err := stream.Dial(ctx, &stream.Config{
+//		URL: "ws://127.0.0.1:8090",
+//	})
+//	if err != nil {
+//		...
+//	}
+//	defer client.Close()
+//
+//	updates, err := client.SubscribeDepth(ctx, "BTCETH", 50, "0")
+//	if err != nil {
+//		...
+//	}
+//
+//	for update := range updates {
+//		...
+//	}
+//
+// Channels of the subscriptions are closed when the client is closed or the
+// connection is lost, the reason is returned by Err.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultBuffer is the number of updates which might be queued for the
+	// subscription before updates start to be dropped.
+	defaultBuffer = 64
+
+	// defaultCallTimeout is the timeout of the request if the context of
+	// the call has no deadline.
+	defaultCallTimeout = 10 * time.Second
+
+	// defaultPingInterval is the interval of the pings sent by the client.
+	defaultPingInterval = 15 * time.Second
+
+	// defaultIdleTimeout is the time without any frame from the server
+	// after which the connection is considered dead.
+	defaultIdleTimeout = 45 * time.Second
+
+	// pingWriteTimeout is the timeout of the write of the control frame.
+	pingWriteTimeout = 5 * time.Second
+)
+
+// ErrClosed is returned by the calls of the closed client.
+var ErrClosed = errors.New("stream client is closed")
+
+// ErrIdleTimeout is the reason of the close of the connection from which
+// no frame has been received within the idle timeout.
+var ErrIdleTimeout = errors.New("no frames received within idle timeout")
+
+// Config is an structure which holds configurable parameters of the stream
+// client.
+type Config struct {
+	// URL is the url of the WebSocket api, e.g. "ws://127.0.0.1:8090".
+	URL string
+
+	// Header is sent with the handshake request, e.g. the authorization
+	// header required by the reverse proxy.
+	Header http.Header
+
+	// Dialer is used to establish the connection, websocket.DefaultDialer
+	// is used if not specified.
+	Dialer *websocket.Dialer
+
+	// Buffer is the number of updates which might be queued for the single
+	// subscription, the updates of the subscriptions which don't keep up
+	// are handled according to the overflow policy.
+	Buffer int
+
+	// Overflow is the policy of the subscriptions whose buffer is full,
+	// DropNewest by default.
+	Overflow OverflowPolicy
+
+	// CallTimeout is the timeout of the request if the context of the call
+	// has no deadline.
+	CallTimeout time.Duration
+
+	// PingInterval is the interval of the pings sent to the server, so
+	// that the proxies don't drop the idle connection and the server
+	// replies with the pongs. Negative value disables the pings.
+	PingInterval time.Duration
+
+	// IdleTimeout is the time without any frame from the server, including
+	// the pongs, after which the half-open connection is closed and the
+	// client fails with ErrIdleTimeout. It should be greater than the ping
+	// interval. Negative value disables the timeout.
+	IdleTimeout time.Duration
+
+	// OnFrame, if specified, is called with every inbound and outbound
+	// data frame before it is handled or sent, e.g. to debug or archive
+	// the traffic, or to decode the notifications which the client
+	// doesn't model. Inbound frames are passed from the read loop, so the
+	// hook should be fast, and it shouldn't modify the data.
+	OnFrame func(direction Direction, data []byte)
+}
+
+// Direction is the direction of the frame passed to the frame hook.
+type Direction int
+
+const (
+	// Inbound is the frame received from the server.
+	Inbound Direction = iota
+
+	// Outbound is the frame sent to the server.
+	Outbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Inbound:
+		return "inbound"
+	case Outbound:
+		return "outbound"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(d))
+	}
+}
+
+// request is the request of the WebSocket api.
+type request struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     int64         `json:"id"`
+}
+
+// message is either the response to the request, if id is set, or the
+// notification of the subscription.
+type message struct {
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *viabtc.RPCError `json:"error"`
+	ID     *int64           `json:"id"`
+}
+
+// Client is the client of the WebSocket api, it is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	conn *websocket.Conn
+
+	// writeMtx serializes the writes to the connection.
+	writeMtx sync.Mutex
+
+	mtx      sync.Mutex
+	nextID   int64
+	pending  map[int64]chan *message
+	handlers map[string]func(params json.RawMessage) error
+	closers  map[string]func()
+	closed   bool
+	err      error
+
+	// depth, deals, price and state is the subscriptions of the market
+	// data.
+	depth depthState
+	deals dealsState
+	price subscription[*PriceUpdate]
+	state subscription[*StateUpdate]
+
+	// orders and balance is the subscriptions of the authorized user.
+	orders  subscription[*OrderUpdateEvent]
+	balance subscription[*BalanceUpdate]
+
+	// registry is the multiplexed subscriptions.
+	registry *registry
+
+	// dropped is the number of the updates dropped by the overflow
+	// policy.
+	dropped atomic.Uint64
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Dial connects to the WebSocket api.
+func Dial(ctx context.Context, cfg *Config) (*Client, error) {
+	c := &Client{
+		cfg:      *cfg,
+		pending:  make(map[int64]chan *message),
+		handlers: make(map[string]func(params json.RawMessage) error),
+		closers:  make(map[string]func()),
+		quit:     make(chan struct{}),
+	}
+	c.registry = &registry{
+		client: c,
+		kinds:  make(map[*topicKind]*kindState),
+	}
+
+	if c.cfg.Buffer <= 0 {
+		c.cfg.Buffer = defaultBuffer
+	}
+	if c.cfg.CallTimeout <= 0 {
+		c.cfg.CallTimeout = defaultCallTimeout
+	}
+	if c.cfg.PingInterval == 0 {
+		c.cfg.PingInterval = defaultPingInterval
+	}
+	if c.cfg.IdleTimeout == 0 {
+		c.cfg.IdleTimeout = defaultIdleTimeout
+	}
+
+	dialer := c.cfg.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	conn, _, err := dialer.DialContext(ctx, c.cfg.URL, c.cfg.Header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %v: %w", c.cfg.URL, err)
+	}
+	c.conn = conn
+
+	// Any frame, including the pings and pongs, proves that the
+	// connection is alive.
+	c.touch()
+	conn.SetPongHandler(func(string) error {
+		c.touch()
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		c.touch()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data),
+			time.Now().Add(pingWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	c.wg.Add(1)
+	go c.read()
+
+	if c.cfg.PingInterval > 0 {
+		c.wg.Add(1)
+		go c.ping()
+	}
+
+	return c, nil
+}
+
+// Call makes the request of the method and decodes its result into the
+// given value, if it isn't nil. Error returned by the server is
+// *viabtc.RPCError.
+func (c *Client) Call(ctx context.Context, method string,
+	params []interface{}, result interface{}) error {
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.CallTimeout)
+		defer cancel()
+	}
+
+	c.mtx.Lock()
+	if c.closed {
+		c.mtx.Unlock()
+		return c.closeErr()
+	}
+	c.nextID++
+	id := c.nextID
+	reply := make(chan *message, 1)
+	c.pending[id] = reply
+	c.mtx.Unlock()
+
+	defer func() {
+		c.mtx.Lock()
+		delete(c.pending, id)
+		c.mtx.Unlock()
+	}()
+
+	err := c.write(&request{Method: method, Params: params, ID: id})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			msg.Error.Method = method
+			return msg.Error
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("unable to decode result of %v: %w", method,
+				err)
+		}
+		return nil
+
+	case <-c.quit:
+		return c.closeErr()
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write sends the request to the server.
+func (c *Client) write(req *request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+
+	if c.cfg.OnFrame != nil {
+		c.cfg.OnFrame(Outbound, data)
+	}
+
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// handle registers the handler of the notifications of the method, closer
+// is called when the client is closed.
+func (c *Client) handle(method string,
+	handler func(params json.RawMessage) error, closer func()) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.handlers[method] = handler
+	if closer != nil {
+		c.closers[method] = closer
+	}
+}
+
+// touch extends the read deadline of the connection by the idle timeout.
+func (c *Client) touch() {
+	if c.cfg.IdleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.cfg.IdleTimeout))
+	}
+}
+
+// ping sends the pings to the server until the client is closed.
+func (c *Client) ping() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(pingWriteTimeout))
+			if err != nil {
+				c.shutdown(fmt.Errorf("unable to send ping: %w", err))
+				return
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// read reads the messages until the connection is closed and dispatches
+// them to the pending calls and the subscriptions.
+func (c *Client) read() {
+	defer c.wg.Done()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = ErrIdleTimeout
+			}
+
+			c.shutdown(err)
+			return
+		}
+		c.touch()
+
+		if c.cfg.OnFrame != nil {
+			c.cfg.OnFrame(Inbound, data)
+		}
+
+		msg := &message{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			c.shutdown(fmt.Errorf("unable to decode message: %w", err))
+			return
+		}
+
+		if msg.ID != nil && msg.Method == "" {
+			c.mtx.Lock()
+			reply, ok := c.pending[*msg.ID]
+			c.mtx.Unlock()
+
+			if ok {
+				reply <- msg
+			}
+			continue
+		}
+
+		c.mtx.Lock()
+		handler, ok := c.handlers[msg.Method]
+		c.mtx.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		if err := handler(msg.Params); err != nil {
+			c.shutdown(fmt.Errorf("unable to handle %v: %w", msg.Method,
+				err))
+			return
+		}
+	}
+}
+
+// shutdown closes the connection and the channels of the subscriptions,
+// the first error is kept as the reason.
+func (c *Client) shutdown(err error) {
+	c.mtx.Lock()
+	if c.closed {
+		c.mtx.Unlock()
+		return
+	}
+	c.closed = true
+	c.err = err
+	closers := c.closers
+	c.closers = make(map[string]func())
+	c.mtx.Unlock()
+
+	close(c.quit)
+	c.conn.Close()
+
+	for _, closer := range closers {
+		closer()
+	}
+	c.registry.close()
+}
+
+// closeErr returns the error which calls of the closed client fail with.
+func (c *Client) closeErr() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.err != nil && c.err != ErrClosed {
+		return fmt.Errorf("%w: %v", ErrClosed, c.err)
+	}
+
+	return ErrClosed
+}
+
+// Err returns the reason why the client has been closed, nil if it is
+// still connected or has been closed by Close.
+func (c *Client) Err() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.err == ErrClosed {
+		return nil
+	}
+
+	return c.err
+}
+
+// Dropped returns the number of the updates of all subscriptions which have
+// been dropped because the consumers didn't keep up.
+func (c *Client) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Done returns the channel which is closed when the client is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.quit
+}
+
+// Close closes the connection and the channels of the subscriptions.
+func (c *Client) Close() error {
+	c.writeMtx.Lock()
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	c.writeMtx.Unlock()
+
+	c.shutdown(ErrClosed)
+	c.wg.Wait()
+
+	return nil
+}
+
+// decodeParams decodes the params of the notification into the given
+// values, the number of params should be the same.
+func decodeParams(params json.RawMessage, values ...interface{}) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) != len(values) {
+		return fmt.Errorf("expected %v params, got %v", len(values),
+			len(raw))
+	}
+
+	for i, value := range values {
+		if err := json.Unmarshal(raw[i], value); err != nil {
+			return fmt.Errorf("param #%v: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// subscription holds the subscription of the single kind, the server keeps
+// only one subscription of every kind per connection, and the new request
+// replaces the previous one.
+type subscription[T any] struct {
+	// subscribeMtx serializes the subscription requests, it isn't held
+	// by the notification handler, so that the updates which arrive
+	// before the response don't block the reading.
+	subscribeMtx sync.Mutex
+
+	mtx  sync.Mutex
+	feed *feed[T]
+}
+
+// swap replaces the feed of the subscription and returns the previous one.
+func (s *subscription[T]) swap(f *feed[T]) *feed[T] {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	previous := s.feed
+	s.feed = f
+	return previous
+}
+
+// push sends the update to the current feed, if any.
+func (s *subscription[T]) push(update T) {
+	s.mtx.Lock()
+	f := s.feed
+	s.mtx.Unlock()
+
+	if f != nil {
+		f.push(update)
+	}
+}
+
+// close closes the current feed.
+func (s *subscription[T]) close() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.feed != nil {
+		s.feed.close()
+	}
+}
+
+// subscribe makes the subscription request and replaces the feed of the
+// subscription, the channel of the previous one is closed. Handler of the
+// notifications is registered before the request, since the server might
+// send the first notification before the response.
+func subscribe[T any](ctx context.Context, c *Client, s *subscription[T],
+	method string, params []interface{}, notification string,
+	handler func(params json.RawMessage) error) (<-chan T, error) {
+
+	s.subscribeMtx.Lock()
+	defer s.subscribeMtx.Unlock()
+
+	f := newFeed[T](c.cfg.Buffer, c.cfg.Overflow, &c.dropped)
+	previous := s.swap(f)
+
+	c.handle(notification, handler, s.close)
+
+	if err := c.Call(ctx, method, params, nil); err != nil {
+		s.swap(previous)
+		f.close()
+		return nil, err
+	}
+
+	if previous != nil {
+		previous.close()
+	}
+
+	return f.ch, nil
+}
+
+// unsubscribe cancels the subscription and closes its channel.
+func unsubscribe[T any](ctx context.Context, c *Client, s *subscription[T],
+	method string) error {
+
+	s.subscribeMtx.Lock()
+	defer s.subscribeMtx.Unlock()
+
+	if err := c.Call(ctx, method, nil, nil); err != nil {
+		return err
+	}
+
+	if previous := s.swap(nil); previous != nil {
+		previous.close()
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package viabtc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SymbolMap maps the engine market names, e.g. "BTCETH", to the unified
+// "BASE/QUOTE" symbols, e.g. "ETH/BTC", and back. Base is the market stock
+// and quote is the market money. Markets which are absent in the overrides
+// are mapped using the stock and money of the market.
+//
+// If the request is made with the unified symbol, the markets in its
+// response are reported with the base and quote of the symbol as the stock
+// and money, so that Unified returns the symbol of the request, even for
+// the overridden markets which engine names don't consist of their assets.
+type SymbolMap struct {
+	toUnified map[string]string
+	toEngine  map[string]string
+}
+
+// NewSymbolMap creates new symbol mapping, overrides map engine market
+// names to unified symbols for markets which names can't be derived
+// automatically.
+func NewSymbolMap(overrides map[string]string) *SymbolMap {
+	m := &SymbolMap{
+		toUnified: make(map[string]string, len(overrides)),
+		toEngine:  make(map[string]string, len(overrides)),
+	}
+
+	for engine, unified := range overrides {
+		m.toUnified[engine] = unified
+		m.toEngine[unified] = engine
+	}
+
+	return m
+}
+
+// Unified returns the unified symbol of the market.
+func (m *SymbolMap) Unified(market MarketType) string {
+	if unified, ok := m.toUnified[market.String()]; ok {
+		return unified
+	}
+
+	return string(market.Stock) + "/" + string(market.Money)
+}
+
+// UnifiedName returns the unified symbol of the market given by its engine
+// name.
+func (m *SymbolMap) UnifiedName(market string) string {
+	if unified, ok := m.toUnified[market]; ok {
+		return unified
+	}
+
+	return m.Unified(NewMarket(market))
+}
+
+// Engine returns the engine market name of the unified symbol. Symbols
+// which aren't in the "BASE/QUOTE" notation are considered to be engine
+// market names already and returned as is.
+func (m *SymbolMap) Engine(symbol string) (string, error) {
+	if engine, ok := m.toEngine[symbol]; ok {
+		return engine, nil
+	}
+
+	if !strings.Contains(symbol, "/") {
+		return symbol, nil
+	}
+
+	parts := strings.Split(symbol, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("wrong symbol %q, should be in "+
+			"BASE/QUOTE notation", symbol)
+	}
+
+	market := MarketType{
+		Stock: AssetType(parts[0]),
+		Money: AssetType(parts[1]),
+	}
+	return market.String(), nil
+}
+
+// translateRequest returns the copy of the request with unified symbol in
+// the market field replaced with the engine market name. Requests without
+// market field are returned as is.
+func (m *SymbolMap) translateRequest(params interface{}) (interface{}, error) {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return params, nil
+	}
+
+	field := v.Elem().FieldByName("Market")
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return params, nil
+	}
+
+	engine, err := m.Engine(field.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if engine == field.String() {
+		return params, nil
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	c.Elem().FieldByName("Market").SetString(engine)
+	return c.Interface(), nil
+}
+
+// marketField returns the market field of the request, empty string is
+// returned if there is no such field.
+func marketField(params interface{}) string {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+
+	field := v.Elem().FieldByName("Market")
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return ""
+	}
+
+	return field.String()
+}
+
+// symbolMarket returns the market with the base and quote of the unified
+// symbol as the stock and money.
+func symbolMarket(symbol string) (MarketType, bool) {
+	parts := strings.Split(symbol, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return MarketType{}, false
+	}
+
+	return MarketType{
+		Stock: AssetType(parts[0]),
+		Money: AssetType(parts[1]),
+	}, true
+}
+
+var marketTypeType = reflect.TypeOf(MarketType{})
+
+// translateResponse replaces the markets with the given engine name in the
+// decoded response with the market of the unified symbol.
+func (m *SymbolMap) translateResponse(resp interface{}, engine,
+	symbol string) {
+
+	market, ok := symbolMarket(symbol)
+	if !ok {
+		return
+	}
+
+	translateMarkets(reflect.ValueOf(resp), engine, market)
+}
+
+// translateMarkets walks the value and replaces the markets with the given
+// engine name. Unexported fields and maps are skipped.
+func translateMarkets(v reflect.Value, engine string, market MarketType) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			translateMarkets(v.Elem(), engine, market)
+		}
+
+	case reflect.Struct:
+		if v.Type() == marketTypeType {
+			if v.CanSet() && v.Interface().(MarketType).String() == engine {
+				v.Set(reflect.ValueOf(market))
+			}
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			translateMarkets(v.Field(i), engine, market)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			translateMarkets(v.Index(i), engine, market)
+		}
+	}
+}
+
+// marketKey returns the engine name of the market given either by the
+// engine name or by the unified symbol, so that the market data stats and
+// the cache are keyed by the same name regardless of the notation used in
+// the request.
+func (e *Client) marketKey(market string) string {
+	if e.cfg.SymbolMap == nil {
+		return market
+	}
+
+	engine, err := e.cfg.SymbolMap.Engine(market)
+	if err != nil {
+		return market
+	}
+
+	return engine
+}
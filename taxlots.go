@@ -0,0 +1,396 @@
+package viabtc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lotReportPrec is the number of decimal places of amounts in the tax-lot
+// report.
+const lotReportPrec = 8
+
+// LotLine is the single gain/loss line item of the tax-lot report, which
+// corresponds to the part of the purchase lot disposed by the sell deal.
+// Lot might be bought on the other market of the same stock, cost basis is
+// denominated in the money of the buy market, proceeds in the money of the
+// sell market, fees and gain in the money of the sell market.
+type LotLine struct {
+	// Market is the market of the sell deal.
+	Market string    `json:"market"`
+	Asset  AssetType `json:"asset"`
+
+	// BuyMarket is the market of the buy deal which opened the lot, it is
+	// empty if line is unmatched.
+	BuyMarket string `json:"buy_market"`
+
+	// Amount is the amount of stock disposed from the lot.
+	Amount string `json:"amount"`
+
+	// Acquired is the time of the buy deal which opened the lot, it is zero
+	// if the sold stock hasn't been bought within the report deals.
+	Acquired  time.Time `json:"acquired"`
+	BuyDealID int32     `json:"buy_deal_id"`
+
+	Disposed   time.Time `json:"disposed"`
+	SellDealID int32     `json:"sell_deal_id"`
+
+	// CostBasis is the money spent on the disposed amount, it includes the
+	// buy fee, because buy fee is taken in stock and reduces the lot.
+	CostBasis string `json:"cost_basis"`
+
+	// Proceeds is the money received for the disposed amount, net of the
+	// sell fee.
+	Proceeds string `json:"proceeds"`
+
+	// Fees is the share of buy and sell fees attributed to the line, valued
+	// in money. It is informational, fees are already accounted for in the
+	// cost basis and proceeds.
+	Fees string `json:"fees"`
+
+	// Gain is the difference between the proceeds and the cost basis, it
+	// is empty if lot was bought for the other money than it is sold, since
+	// they can't be compared without the exchange rate.
+	Gain string `json:"gain"`
+
+	// Unmatched is true if there were no open lots for the sold amount, in
+	// this case cost basis is zero.
+	Unmatched bool `json:"unmatched"`
+}
+
+// OpenLot is the remaining part of the purchase which hasn't been sold
+// until the end of the report.
+type OpenLot struct {
+	Market    string    `json:"market"`
+	Asset     AssetType `json:"asset"`
+	Amount    string    `json:"amount"`
+	CostBasis string    `json:"cost_basis"`
+	Acquired  time.Time `json:"acquired"`
+	BuyDealID int32     `json:"buy_deal_id"`
+}
+
+// LotReport is the result of FIFO matching of buys and sells.
+type LotReport struct {
+	Lines []LotLine `json:"lines"`
+	Open  []OpenLot `json:"open"`
+}
+
+// lot is the purchased stock which is waiting to be sold.
+type lot struct {
+	market   MarketType
+	dealID   int32
+	time     float64
+	amount   *big.Rat
+	cost     *big.Rat
+	feeValue *big.Rat
+}
+
+// share returns the part of the value which corresponds to the taken
+// amount of the total.
+func share(value, taken, total *big.Rat) *big.Rat {
+	if total.Sign() == 0 {
+		return new(big.Rat)
+	}
+
+	r := new(big.Rat).Mul(value, taken)
+	return r.Quo(r, total)
+}
+
+func timeFromUnix(t float64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+
+	sec := int64(t)
+	return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC()
+}
+
+// parseDeal parses the decimal fields of the deal.
+func parseDeal(d *DealDetail) (amount, deal, fee, price *big.Rat, err error) {
+	if amount, err = parseDecimal(d.Amount); err != nil {
+		return
+	}
+	if deal, err = parseDecimal(d.Deal); err != nil {
+		return
+	}
+	if price, err = parseDecimal(d.Price); err != nil {
+		return
+	}
+
+	fee = new(big.Rat)
+	if d.Fee != "" {
+		fee, err = parseDecimal(d.Fee)
+	}
+	return
+}
+
+// MatchLots matches the sell deals of the market with the preceding buy
+// deals in FIFO order and returns the gain/loss line items. Deals might be
+// given in any order, they are sorted by time. Buy fee is taken in stock and
+// reduces the purchased lot, sell fee is taken in money and reduces the
+// proceeds.
+func MatchLots(market MarketType, deals []DealDetail) (*LotReport, error) {
+	return MatchAssetLots(map[MarketType][]DealDetail{market: deals})
+}
+
+// marketDeal is the deal along with the market it was made on.
+type marketDeal struct {
+	market MarketType
+	DealDetail
+}
+
+// MatchAssetLots matches the sell deals with the preceding buy deals of the
+// same stock asset in FIFO order, regardless of the market they were made
+// on, and returns the gain/loss line items. Deals of every market might be
+// given in any order, they are sorted by time.
+func MatchAssetLots(deals map[MarketType][]DealDetail) (*LotReport, error) {
+	var sorted []marketDeal
+	for market, marketDeals := range deals {
+		for _, d := range marketDeals {
+			sorted = append(sorted, marketDeal{market: market, DealDetail: d})
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time < sorted[j].Time
+		}
+		if sorted[i].DealID != sorted[j].DealID {
+			return sorted[i].DealID < sorted[j].DealID
+		}
+		return sorted[i].market.String() < sorted[j].market.String()
+	})
+
+	report := &LotReport{}
+
+	// Lots are kept by the stock asset, so that the stock bought on one
+	// market is disposed when it is sold on the other one.
+	lots := make(map[AssetType][]*lot)
+	var assets []AssetType
+
+	for i := range sorted {
+		d := &sorted[i].DealDetail
+		market := sorted[i].market
+		asset := market.Stock
+
+		if _, ok := lots[asset]; !ok {
+			lots[asset] = nil
+			assets = append(assets, asset)
+		}
+
+		amount, deal, fee, price, err := parseDeal(d)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse deal %v of %v: %w",
+				d.DealID, market, err)
+		}
+
+		switch d.Side {
+		case MarketOrderSideBid:
+			received := new(big.Rat).Sub(amount, fee)
+			if received.Sign() <= 0 {
+				continue
+			}
+
+			lots[asset] = append(lots[asset], &lot{
+				market:   market,
+				dealID:   d.DealID,
+				time:     d.Time,
+				amount:   received,
+				cost:     deal,
+				feeValue: new(big.Rat).Mul(fee, price),
+			})
+
+		case MarketOrderSideAsk:
+			proceeds := new(big.Rat).Sub(deal, fee)
+			remaining := new(big.Rat).Set(amount)
+
+			for remaining.Sign() > 0 {
+				line := LotLine{
+					Market:     market.String(),
+					Asset:      asset,
+					Disposed:   timeFromUnix(d.Time),
+					SellDealID: d.DealID,
+				}
+
+				var (
+					taken, cost, lotFee *big.Rat
+					sameMoney           = true
+				)
+				if len(lots[asset]) == 0 {
+					taken = remaining
+					cost = new(big.Rat)
+					lotFee = new(big.Rat)
+					line.Unmatched = true
+				} else {
+					l := lots[asset][0]
+
+					taken = l.amount
+					if remaining.Cmp(taken) < 0 {
+						taken = remaining
+					}
+					taken = new(big.Rat).Set(taken)
+
+					cost = share(l.cost, taken, l.amount)
+					lotFee = share(l.feeValue, taken, l.amount)
+
+					l.cost.Sub(l.cost, cost)
+					l.feeValue.Sub(l.feeValue, lotFee)
+					l.amount.Sub(l.amount, taken)
+					if l.amount.Sign() == 0 {
+						lots[asset] = lots[asset][1:]
+					}
+
+					line.BuyMarket = l.market.String()
+					line.Acquired = timeFromUnix(l.time)
+					line.BuyDealID = l.dealID
+
+					// Buy fee is valued in the money of the buy market,
+					// so it is attributed only if money is the same.
+					sameMoney = l.market.Money == market.Money
+					if !sameMoney {
+						lotFee = new(big.Rat)
+					}
+				}
+
+				lineProceeds := share(proceeds, taken, amount)
+				lineFees := new(big.Rat).Add(lotFee, share(fee, taken, amount))
+
+				line.Amount = taken.FloatString(lotReportPrec)
+				line.CostBasis = cost.FloatString(lotReportPrec)
+				line.Proceeds = lineProceeds.FloatString(lotReportPrec)
+				line.Fees = lineFees.FloatString(lotReportPrec)
+				if sameMoney {
+					gain := new(big.Rat).Sub(lineProceeds, cost)
+					line.Gain = gain.FloatString(lotReportPrec)
+				}
+				report.Lines = append(report.Lines, line)
+
+				remaining = new(big.Rat).Sub(remaining, taken)
+			}
+
+		default:
+			return nil, fmt.Errorf("deal %v has unknown side: %v",
+				d.DealID, d.Side)
+		}
+	}
+
+	for _, asset := range assets {
+		for _, l := range lots[asset] {
+			report.Open = append(report.Open, OpenLot{
+				Market:    l.market.String(),
+				Asset:     asset,
+				Amount:    l.amount.FloatString(lotReportPrec),
+				CostBasis: l.cost.FloatString(lotReportPrec),
+				Acquired:  timeFromUnix(l.time),
+				BuyDealID: l.dealID,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// LotReportConfig is an structure which holds parameters of the tax-lot
+// report.
+type LotReportConfig struct {
+	UserID  uint32
+	Markets []MarketType
+
+	// Start and End limit the deals which are matched. Lots bought before
+	// the start aren't known, so sells of them are reported as unmatched.
+	Start time.Time
+	End   time.Time
+}
+
+// LotReport fetches the user's deals on every market and matches buys to
+// sells of the same stock asset using FIFO lots, so that the stock bought
+// on one market and sold on the other one is matched.
+func (e *Client) LotReport(cfg *LotReportConfig) (*LotReport, error) {
+	deals := make(map[MarketType][]DealDetail)
+
+	for _, market := range cfg.Markets {
+		var marketDeals []DealDetail
+
+		it := NewUserDealsQuery(cfg.UserID).Market(market).
+			Since(cfg.Start).Until(cfg.End).Iter(e)
+		for it.Next() {
+			marketDeals = append(marketDeals, it.Deal())
+		}
+
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+
+		deals[market] = append(deals[market], marketDeals...)
+	}
+
+	report, err := MatchAssetLots(deals)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		return report.Lines[i].Disposed.Before(report.Lines[j].Disposed)
+	})
+
+	return report, nil
+}
+
+// lotReportHeader is the header of the CSV tax-lot report.
+var lotReportHeader = []string{
+	"market", "asset", "amount", "buy_market", "acquired", "buy_deal_id",
+	"disposed", "sell_deal_id", "cost_basis", "proceeds", "fees", "gain",
+	"unmatched",
+}
+
+func formatLotTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// WriteCSV writes the gain/loss line items of the report in CSV format with
+// the header row.
+func (r *LotReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(lotReportHeader); err != nil {
+		return err
+	}
+
+	for _, line := range r.Lines {
+		buyDealID := ""
+		if !line.Unmatched {
+			buyDealID = fmt.Sprint(line.BuyDealID)
+		}
+
+		record := []string{
+			line.Market,
+			string(line.Asset),
+			line.Amount,
+			line.BuyMarket,
+			formatLotTime(line.Acquired),
+			buyDealID,
+			formatLotTime(line.Disposed),
+			fmt.Sprint(line.SellDealID),
+			line.CostBasis,
+			line.Proceeds,
+			line.Fees,
+			line.Gain,
+			strconv.FormatBool(line.Unmatched),
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
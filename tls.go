@@ -0,0 +1,62 @@
+package viabtc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// LoadTLSConfig creates the TLS configuration of the client from the PEM
+// files. CA bundle, if specified, replaces the system roots in the
+// verification of the server certificate. Client certificate and key, if
+// specified, are presented to the server which requires mutual TLS.
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA bundle %v doesn't contain "+
+				"certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("both client certificate and key " +
+				"should be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client "+
+				"certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// scheme returns the scheme of the server url.
+func (cfg *Config) scheme() string {
+	if u := cfg.baseURL(); u != nil {
+		return u.Scheme
+	}
+
+	if cfg.TLS || cfg.TLSConfig != nil {
+		return "https"
+	}
+
+	return "http"
+}
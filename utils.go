@@ -1,9 +1,10 @@
 package viabtc
 
 import (
+	"fmt"
+	"math/big"
 	"reflect"
-
-	"github.com/go-errors/errors"
+	"regexp"
 )
 
 // extractArguments is an helper function which is used to iterate over
@@ -43,7 +44,34 @@ func extractArguments(s interface{}) ([]interface{}, error) {
 
 		return args, nil
 	default:
-		return nil, errors.Errorf("unknown type: %v", t)
+		return nil, fmt.Errorf("unknown type: %v", t)
+
+	}
+}
+
+// parseDecimal converts the decimal string representation of the amount,
+// which is used by the exchange client, into the rational number, so that
+// it could be used in calculations without loss of precision.
+func parseDecimal(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse decimal: %q", s)
+	}
+
+	return r, nil
+}
 
+// strictDecimal is the plain non-negative decimal number, without sign,
+// exponent or fraction.
+var strictDecimal = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// parseStrictDecimal converts the decimal string into the rational number
+// like parseDecimal, but accepts only the plain notation understood by the
+// engine, e.g. "1.5", while "1/2", "1e3", "+1" or ".5" are rejected.
+func parseStrictDecimal(s string) (*big.Rat, error) {
+	if !strictDecimal.MatchString(s) {
+		return nil, fmt.Errorf("unable to parse decimal: %q", s)
 	}
+
+	return parseDecimal(s)
 }
@@ -0,0 +1,159 @@
+package viabtc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestionDistance is the maximum edit distance between the unknown
+// name and the existing one for the latter to be suggested, for short names
+// the distance is limited to one third of the name length.
+const maxSuggestionDistance = 2
+
+// SymbolValidationError is returned when some of the configured markets or
+// assets don't exist in the engine, it describes every problem found.
+type SymbolValidationError struct {
+	Problems []string
+}
+
+// A compile time check to ensure SymbolValidationError implements the
+// error interface.
+var _ error = (*SymbolValidationError)(nil)
+
+func (e *SymbolValidationError) Error() string {
+	return "invalid symbols: " + strings.Join(e.Problems, "; ")
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// suggest returns the names which are close to the unknown one.
+func suggest(name string, names []string) []string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > maxSuggestionDistance {
+		limit = maxSuggestionDistance
+	}
+
+	var suggestions []string
+	for _, candidate := range names {
+		d := editDistance(strings.ToUpper(name), strings.ToUpper(candidate))
+		if d <= limit {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// ValidateSymbols checks that the given markets and assets exist in the
+// engine, it is intended to be run on service boot before any trading
+// begins. The registry is loaded if it hasn't been loaded yet. All
+// problems are reported at once within SymbolValidationError, with
+// suggestions for typos and reversed pairs.
+func (e *Client) ValidateSymbols(markets []MarketType,
+	assets []AssetType) error {
+
+	if e.registry.Updated().IsZero() {
+		if err := e.registry.Refresh(); err != nil {
+			return err
+		}
+	}
+
+	var marketNames []string
+	for _, info := range e.registry.Markets() {
+		marketNames = append(marketNames, info.MarketName.String())
+	}
+
+	var assetNames []string
+	for _, info := range e.registry.AssetList() {
+		assetNames = append(assetNames, info.Name)
+	}
+
+	var problems []string
+	for _, asset := range assets {
+		if e.registry.AssetExists(asset) {
+			continue
+		}
+
+		problem := fmt.Sprintf("asset %v doesn't exist", asset)
+		if s := suggest(string(asset), assetNames); len(s) != 0 {
+			problem += fmt.Sprintf(", did you mean %v?",
+				strings.Join(s, " or "))
+		}
+		problems = append(problems, problem)
+	}
+
+	for _, market := range markets {
+		if e.registry.MarketExists(market) {
+			continue
+		}
+
+		problem := fmt.Sprintf("market %v doesn't exist", market)
+
+		reversed := MarketType{Stock: market.Money, Money: market.Stock}
+		stockExists := e.registry.AssetExists(market.Stock)
+		moneyExists := e.registry.AssetExists(market.Money)
+
+		switch {
+		case e.registry.MarketExists(reversed):
+			problem += fmt.Sprintf(", stock and money are swapped, did "+
+				"you mean %v?", reversed)
+
+		case stockExists && moneyExists:
+			problem += ", both assets exist, the pair might be delisted " +
+				"or never listed"
+
+		case !stockExists:
+			problem += fmt.Sprintf(", its stock %v doesn't exist",
+				market.Stock)
+
+		case !moneyExists:
+			problem += fmt.Sprintf(", its money %v doesn't exist",
+				market.Money)
+		}
+
+		if s := suggest(market.String(), marketNames); len(s) != 0 {
+			problem += fmt.Sprintf(", similar markets: %v",
+				strings.Join(s, ", "))
+		}
+		problems = append(problems, problem)
+	}
+
+	if len(problems) != 0 {
+		return &SymbolValidationError{Problems: problems}
+	}
+
+	return nil
+}
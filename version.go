@@ -0,0 +1,22 @@
+package viabtc
+
+// Version is the version of the client package.
+const Version = "0.1.0"
+
+// DefaultUserAgent is the user agent of the requests if client
+// identification isn't configured.
+const DefaultUserAgent = "viabtc_rpc_client/" + Version
+
+// userAgent returns the user agent of the requests made with the
+// configuration.
+func (c *Config) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+
+	if c.ClientName != "" {
+		return c.ClientName + " " + DefaultUserAgent
+	}
+
+	return DefaultUserAgent
+}
@@ -0,0 +1,52 @@
+package viabtc
+
+import (
+	"context"
+	"fmt"
+)
+
+// warmupDepthLimit is the number of depth levels fetched during warm-up.
+const warmupDepthLimit int32 = 20
+
+// Warmup prefetches the metadata of markets and assets into the registry,
+// so that precisions and minimum amounts are available without additional
+// requests, and checks that the given markets exist. If market data
+// warm-up is enabled in configuration, the last price and depth of the
+// given markets are fetched as well, which also establishes connections
+// to the server. It is intended to be called on startup, so that the first
+// trading decision isn't delayed by the burst of cold requests.
+func (e *Client) Warmup(ctx context.Context, markets ...MarketType) error {
+	if err := e.registry.RefreshContext(ctx); err != nil {
+		return err
+	}
+
+	if err := e.ValidateSymbols(markets, nil); err != nil {
+		return err
+	}
+
+	if !e.cfg.WarmupMarketData {
+		return nil
+	}
+
+	return e.fanOut(len(markets), func(i int) error {
+		market := markets[i].String()
+
+		if _, err := e.MarketLastContext(ctx, &MarketLastRequest{
+			Market: market,
+		}); err != nil {
+			return fmt.Errorf("unable to fetch last price of %v: %w",
+				market, err)
+		}
+
+		if _, err := e.OrderDepthContext(ctx, &OrderDepthRequest{
+			Market:   market,
+			Limit:    warmupDepthLimit,
+			Interval: DefaultDepthInterval,
+		}); err != nil {
+			return fmt.Errorf("unable to fetch depth of %v: %w",
+				market, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,756 @@
+package viabtc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WatchDataType is the type of the market data which is watched.
+type WatchDataType string
+
+const (
+	// WatchDeals is the new deals of the market, data of the event is
+	// MarketDealsResponse.
+	WatchDeals WatchDataType = "deals"
+
+	// WatchLast is the last price of the market, data of the event is
+	// string.
+	WatchLast WatchDataType = "last"
+
+	// WatchDepth is the top of the order book, data of the event is
+	// *OrderDepthResponse.
+	WatchDepth WatchDataType = "depth"
+
+	// WatchKLine is the klines of the recent intervals, data of the event
+	// is MarketKLineResponse.
+	WatchKLine WatchDataType = "kline"
+
+	// WatchStatus is the market status within the period, data of the
+	// event is *MarketStatusResponse.
+	WatchStatus WatchDataType = "status"
+)
+
+const (
+	// defaultWatchInterval is the polling interval if not specified.
+	defaultWatchInterval = time.Second
+
+	// defaultWatchDepthLimit is the number of depth levels if not
+	// specified.
+	defaultWatchDepthLimit int32 = 10
+
+	// defaultWatchDealsLimit is the maximum number of deals fetched per
+	// poll.
+	defaultWatchDealsLimit int32 = 100
+
+	// watchDedupCapacity is the number of the recently emitted deals
+	// remembered by the poller.
+	watchDedupCapacity = 10 * int(defaultWatchDealsLimit)
+
+	// defaultWatchKLineWindow is the number of recent klines fetched per
+	// poll.
+	defaultWatchKLineWindow = 2
+
+	// defaultChannelSinkBuffer is the buffer of the channel sink if not
+	// specified, events are dropped if the buffer is full.
+	defaultChannelSinkBuffer = 256
+)
+
+// SpecDuration is the duration which is specified in the watch spec as
+// string, e.g. "500ms" or "1m".
+type SpecDuration time.Duration
+
+// UnmarshalJSON decodes the duration from the string.
+func (d *SpecDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = SpecDuration(duration)
+	return nil
+}
+
+// MarshalJSON encodes the duration as string.
+func (d SpecDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// WatchEntry describes the market data which should be polled and the
+// sinks it should be delivered to.
+type WatchEntry struct {
+	Markets []string        `json:"markets"`
+	Data    []WatchDataType `json:"data"`
+
+	// Interval is the polling interval.
+	Interval SpecDuration `json:"interval"`
+
+	// DepthLimit is the number of depth levels.
+	DepthLimit int32 `json:"depth_limit"`
+
+	// KLineInterval is the kline interval in seconds.
+	KLineInterval int32 `json:"kline_interval"`
+
+	// StatusPeriod is the market status period in seconds.
+	StatusPeriod int32 `json:"status_period"`
+
+	// Sinks is the names of the sinks which receive the events.
+	Sinks []string `json:"sinks"`
+}
+
+// SinkSpec describes the sink of the watch events.
+type SinkSpec struct {
+	Name string `json:"name"`
+
+	// Type is the type of the sink, "channel" and "file" are built-in,
+	// others might be registered with RegisterWatchSink.
+	Type string `json:"type"`
+
+	// Path is the path of the file sink.
+	Path string `json:"path,omitempty"`
+
+	// Buffer is the buffer size of the channel sink.
+	Buffer int `json:"buffer,omitempty"`
+
+	// Options holds the parameters of the registered sink types.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// WatchSpec is the declarative description of the market data service.
+type WatchSpec struct {
+	Watches []WatchEntry `json:"watches"`
+	Sinks   []SinkSpec   `json:"sinks"`
+}
+
+// ParseWatchSpec parses the json spec.
+func ParseWatchSpec(data []byte) (*WatchSpec, error) {
+	spec := &WatchSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("unable to parse watch spec: %w", err)
+	}
+
+	return spec, nil
+}
+
+// ParseWatchSpecYAML parses the yaml spec, it has the same structure as the
+// json one.
+func ParseWatchSpecYAML(data []byte) (*WatchSpec, error) {
+	data, err := yamlToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse watch spec: %w", err)
+	}
+
+	return ParseWatchSpec(data)
+}
+
+// yamlToJSON converts the yaml document to json, so that the specs are
+// decoded by the same json decoders regardless of the format.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// LoadWatchSpec reads the spec from the file, files with ".yaml" and ".yml"
+// extensions are parsed as yaml, others as json.
+func LoadWatchSpec(path string) (*WatchSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseWatchSpecYAML(data)
+	default:
+		return ParseWatchSpec(data)
+	}
+}
+
+// WatchEvent is the market data event delivered to the sinks.
+type WatchEvent struct {
+	Market string        `json:"market"`
+	Type   WatchDataType `json:"type"`
+	Time   time.Time     `json:"time"`
+
+	// Data is the fetched market data, its type depends on the type of the
+	// event.
+	Data interface{} `json:"data"`
+}
+
+// UnmarshalJSON decodes the event, the data is decoded into the type which
+// corresponds to the type of the event.
+func (e *WatchEvent) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Market string          `json:"market"`
+		Type   WatchDataType   `json:"type"`
+		Time   time.Time       `json:"time"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var v interface{}
+	switch raw.Type {
+	case WatchDeals:
+		v = &MarketDealsResponse{}
+	case WatchLast:
+		v = new(string)
+	case WatchDepth:
+		v = &OrderDepthResponse{}
+	case WatchKLine:
+		v = &MarketKLineResponse{}
+	case WatchStatus:
+		v = &MarketStatusResponse{}
+	default:
+		return fmt.Errorf("unknown watch event type: %v", raw.Type)
+	}
+
+	if err := json.Unmarshal(raw.Data, v); err != nil {
+		return err
+	}
+
+	e.Market = raw.Market
+	e.Type = raw.Type
+	e.Time = raw.Time
+
+	// Data has the same type as in the emitted events.
+	switch d := v.(type) {
+	case *MarketDealsResponse:
+		e.Data = *d
+	case *string:
+		e.Data = *d
+	case *MarketKLineResponse:
+		e.Data = *d
+	default:
+		e.Data = v
+	}
+
+	return nil
+}
+
+// WatchSink receives the watch events.
+type WatchSink interface {
+	// Emit delivers the event, it shouldn't block for long, because
+	// polling of the market waits for it.
+	Emit(event *WatchEvent) error
+
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// WatchSinkFactory creates the sink from the spec.
+type WatchSinkFactory func(spec *SinkSpec) (WatchSink, error)
+
+var (
+	sinkFactoriesMtx sync.RWMutex
+	sinkFactories    = make(map[string]WatchSinkFactory)
+)
+
+// RegisterWatchSink registers the sink type, so that it might be used in
+// watch specs. It is intended to be called from init functions of the
+// packages which implement sinks.
+func RegisterWatchSink(sinkType string, factory WatchSinkFactory) {
+	sinkFactoriesMtx.Lock()
+	defer sinkFactoriesMtx.Unlock()
+
+	sinkFactories[sinkType] = factory
+}
+
+// ChannelSink delivers the events to the go channel, events are dropped if
+// the consumer doesn't keep up.
+type ChannelSink struct {
+	events chan *WatchEvent
+	once   sync.Once
+}
+
+// A compile time check to ensure ChannelSink implements the WatchSink
+// interface.
+var _ WatchSink = (*ChannelSink)(nil)
+
+// NewChannelSink creates the channel sink with the given buffer.
+func NewChannelSink(buffer int) *ChannelSink {
+	if buffer <= 0 {
+		buffer = defaultChannelSinkBuffer
+	}
+
+	return &ChannelSink{
+		events: make(chan *WatchEvent, buffer),
+	}
+}
+
+// Events returns the channel of events, it is closed when sink is closed.
+func (s *ChannelSink) Events() <-chan *WatchEvent {
+	return s.events
+}
+
+// Emit delivers the event without blocking.
+func (s *ChannelSink) Emit(event *WatchEvent) error {
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return fmt.Errorf("channel sink is full, %v event of %v "+
+			"dropped", event.Type, event.Market)
+	}
+}
+
+// Close closes the channel of events.
+func (s *ChannelSink) Close() error {
+	s.once.Do(func() {
+		close(s.events)
+	})
+	return nil
+}
+
+// FileSink records the events into the file as json lines, the recorded
+// events might be read back with WatchEventReader.
+type FileSink struct {
+	mtx  sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// A compile time check to ensure FileSink implements the WatchSink
+// interface.
+var _ WatchSink = (*FileSink)(nil)
+
+// NewFileSink opens the file for appending the events.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sink file: %w", err)
+	}
+
+	return &FileSink{
+		file: f,
+		w:    bufio.NewWriter(f),
+	}, nil
+}
+
+// Emit appends the event to the file.
+func (s *FileSink) Emit(event *WatchEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return s.w.Flush()
+}
+
+// Close flushes and closes the file.
+func (s *FileSink) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+
+	return s.file.Close()
+}
+
+// WatchEventReader reads the events recorded by the file sink.
+type WatchEventReader struct {
+	scanner *bufio.Scanner
+	event   *WatchEvent
+	err     error
+}
+
+// NewWatchEventReader creates the reader of the recorded events.
+func NewWatchEventReader(r io.Reader) *WatchEventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+
+	return &WatchEventReader{
+		scanner: scanner,
+	}
+}
+
+// Next reads the next event, it returns false when all events were read or
+// error occurred.
+func (r *WatchEventReader) Next() bool {
+	for r.err == nil && r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		event := &WatchEvent{}
+		if err := json.Unmarshal(line, event); err != nil {
+			r.err = err
+			return false
+		}
+
+		r.event = event
+		return true
+	}
+
+	if r.err == nil {
+		r.err = r.scanner.Err()
+	}
+	return false
+}
+
+// Event returns the current event.
+func (r *WatchEventReader) Event() *WatchEvent {
+	return r.event
+}
+
+// Err returns the error which stopped the reading.
+func (r *WatchEventReader) Err() error {
+	return r.err
+}
+
+// newSink creates the sink from the spec.
+func newSink(spec *SinkSpec) (WatchSink, error) {
+	switch spec.Type {
+	case "channel":
+		return NewChannelSink(spec.Buffer), nil
+
+	case "file":
+		if spec.Path == "" {
+			return nil, fmt.Errorf("path of file sink %v isn't "+
+				"specified", spec.Name)
+		}
+		return NewFileSink(spec.Path)
+	}
+
+	sinkFactoriesMtx.RLock()
+	factory, ok := sinkFactories[spec.Type]
+	sinkFactoriesMtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q of sink %v, sink "+
+			"package might be not imported", spec.Type, spec.Name)
+	}
+
+	return factory(spec)
+}
+
+// watchPoller polls the single type of the market data of the single
+// market.
+type watchPoller struct {
+	market   string
+	dataType WatchDataType
+	entry    *WatchEntry
+	sinks    []WatchSink
+
+	// last is the last emitted data, it is used to emit only changes.
+	last interface{}
+
+	// lastDealID is the id of the last emitted deal.
+	lastDealID int32
+
+	// dedup skips the deals which have been already emitted.
+	dedup *DealDeduplicator
+}
+
+// WatchSupervisor runs the pollers of the market data described by the
+// watch spec and delivers the events to the sinks.
+type WatchSupervisor struct {
+	client *Client
+
+	sinks   map[string]WatchSink
+	pollers []*watchPoller
+
+	mtx     sync.Mutex
+	started bool
+	lastErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatchSupervisor validates the spec and creates the sinks.
+func NewWatchSupervisor(client *Client, spec *WatchSpec) (*WatchSupervisor,
+	error) {
+
+	s := &WatchSupervisor{
+		client: client,
+		sinks:  make(map[string]WatchSink),
+		quit:   make(chan struct{}),
+	}
+
+	for i := range spec.Sinks {
+		sinkSpec := &spec.Sinks[i]
+		if _, ok := s.sinks[sinkSpec.Name]; ok {
+			s.closeSinks()
+			return nil, fmt.Errorf("duplicate sink %v", sinkSpec.Name)
+		}
+
+		sink, err := newSink(sinkSpec)
+		if err != nil {
+			s.closeSinks()
+			return nil, err
+		}
+		s.sinks[sinkSpec.Name] = sink
+	}
+
+	for i := range spec.Watches {
+		entry := spec.Watches[i]
+
+		if entry.Interval <= 0 {
+			entry.Interval = SpecDuration(defaultWatchInterval)
+		}
+		if entry.DepthLimit <= 0 {
+			entry.DepthLimit = defaultWatchDepthLimit
+		}
+		if entry.KLineInterval <= 0 {
+			entry.KLineInterval = DefaultKLineInterval
+		}
+		if entry.StatusPeriod <= 0 {
+			entry.StatusPeriod = DefaultStatusPeriod
+		}
+
+		var sinks []WatchSink
+		for _, name := range entry.Sinks {
+			sink, ok := s.sinks[name]
+			if !ok {
+				s.closeSinks()
+				return nil, fmt.Errorf("unknown sink %v", name)
+			}
+			sinks = append(sinks, sink)
+		}
+
+		for _, market := range entry.Markets {
+			for _, dataType := range entry.Data {
+				switch dataType {
+				case WatchDeals, WatchLast, WatchDepth, WatchKLine,
+					WatchStatus:
+				default:
+					s.closeSinks()
+					return nil, fmt.Errorf("unknown data type %q",
+						dataType)
+				}
+
+				s.pollers = append(s.pollers, &watchPoller{
+					market:   market,
+					dataType: dataType,
+					entry:    &entry,
+					sinks:    sinks,
+					dedup:    NewDealDeduplicator(watchDedupCapacity),
+				})
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Sink returns the sink by its name, it is used to get the events of the
+// channel sinks.
+func (s *WatchSupervisor) Sink(name string) (WatchSink, bool) {
+	sink, ok := s.sinks[name]
+	return sink, ok
+}
+
+// Channel returns the events of the channel sink.
+func (s *WatchSupervisor) Channel(name string) (<-chan *WatchEvent, bool) {
+	sink, ok := s.sinks[name].(*ChannelSink)
+	if !ok {
+		return nil, false
+	}
+
+	return sink.Events(), true
+}
+
+// Start starts polling.
+func (s *WatchSupervisor) Start() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.started {
+		return errors.New("supervisor is already started")
+	}
+	s.started = true
+
+	for _, p := range s.pollers {
+		s.wg.Add(1)
+		go s.run(p)
+	}
+
+	return nil
+}
+
+// Stop stops polling and closes the sinks.
+func (s *WatchSupervisor) Stop() {
+	s.mtx.Lock()
+	if !s.started {
+		s.mtx.Unlock()
+		s.closeSinks()
+		return
+	}
+	s.started = false
+	close(s.quit)
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	s.closeSinks()
+}
+
+// Err returns the last error of polling or delivery.
+func (s *WatchSupervisor) Err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.lastErr
+}
+
+func (s *WatchSupervisor) setErr(err error) {
+	s.mtx.Lock()
+	s.lastErr = err
+	s.mtx.Unlock()
+}
+
+func (s *WatchSupervisor) closeSinks() {
+	names := make([]string, 0, len(s.sinks))
+	for name := range s.sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s.sinks[name].Close()
+	}
+}
+
+func (s *WatchSupervisor) run(p *watchPoller) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(p.entry.Interval))
+	defer ticker.Stop()
+
+	for {
+		if err := s.poll(p); err != nil {
+			s.setErr(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// poll fetches the market data and emits the event if it has changed.
+func (s *WatchSupervisor) poll(p *watchPoller) error {
+	var (
+		data interface{}
+		err  error
+	)
+
+	switch p.dataType {
+	case WatchDeals:
+		var deals MarketDealsResponse
+		deals, err = s.client.MarketDeals(&MarketDealsRequest{
+			Market: p.market,
+			Limit:  defaultWatchDealsLimit,
+			LastID: p.lastDealID,
+		})
+		if err != nil || len(deals) == 0 {
+			break
+		}
+
+		// Deals are returned from newest to oldest.
+		p.lastDealID = deals[0].DealID
+		if deals = p.dedup.Deals(deals); len(deals) != 0 {
+			data = deals
+		}
+
+	case WatchLast:
+		var last *string
+		last, err = s.client.MarketLast(&MarketLastRequest{
+			Market: p.market,
+		})
+
+		// Engine might answer with null result, in this case there is
+		// nothing to emit.
+		if err == nil && last != nil {
+			data = *last
+		}
+
+	case WatchDepth:
+		data, err = s.client.OrderDepth(&OrderDepthRequest{
+			Market:   p.market,
+			Limit:    p.entry.DepthLimit,
+			Interval: DefaultDepthInterval,
+		})
+
+	case WatchKLine:
+		now := float64(s.client.now().Unix())
+		interval := p.entry.KLineInterval
+		data, err = s.client.MarketKLine(&MarketKLineRequest{
+			Market:    p.market,
+			StartTime: now - float64(interval*defaultWatchKLineWindow),
+			EndTime:   now,
+			Interval:  interval,
+		})
+
+	case WatchStatus:
+		data, err = s.client.MarketStatus(&MarketStatusRequest{
+			Market: p.market,
+			Period: p.entry.StatusPeriod,
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to poll %v of %v: %w", p.dataType,
+			p.market, err)
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	if p.dataType != WatchDeals && reflect.DeepEqual(data, p.last) {
+		return nil
+	}
+	p.last = data
+
+	event := &WatchEvent{
+		Market: p.market,
+		Type:   p.dataType,
+		Time:   s.client.now().UTC(),
+		Data:   data,
+	}
+
+	var emitErr error
+	for _, sink := range p.sinks {
+		if err := sink.Emit(event); err != nil {
+			emitErr = err
+		}
+	}
+
+	return emitErr
+}
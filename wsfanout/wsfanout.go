@@ -0,0 +1,431 @@
+// Package wsfanout implements the WebSocket server which distributes the
+// market data to the internal consumers. Server is the sink of the watch
+// events, so that the data is obtained from the engine once by the watch
+// supervisor and fanned out to all connected subscribers of its topic.
+// Importing the package registers the "websocket" sink type, so that it
+// might be used in watch specs:
+//
+//	{"name": "ws", "type": "websocket", "options": {
+//		"addr": ":8090",
+//		"path": "/ws"
+//	}}
+//
+// Topic of the events is "<market>.<type>", e.g. "BTCETH.depth". Consumers
+// manage the subscriptions by sending the requests:
+//
+//	{"op": "subscribe", "topics": ["BTCETH.depth", "BTCETH.deals"]}
+//	{"op": "unsubscribe", "topics": ["BTCETH.deals"]}
+//
+// and receive the events of the subscribed topics:
+//
+//	{"topic": "BTCETH.depth", "event": {"market": "BTCETH", ...}}
+//
+// The last event of the topic is sent right after the subscription, so that
+// the consumer doesn't wait for the next change of the data.
+package wsfanout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	viabtc "github.com/bitlum/viabtc_rpc_client"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultPath is the path of the WebSocket endpoint if not specified.
+	defaultPath = "/ws"
+
+	// defaultBuffer is the number of messages which might be queued for
+	// the single subscriber before messages start to be dropped.
+	defaultBuffer = 64
+
+	// defaultWriteTimeout is the timeout of the single write to the
+	// subscriber if not specified.
+	defaultWriteTimeout = 10 * time.Second
+
+	// pingInterval is the interval of the keepalive pings.
+	pingInterval = 30 * time.Second
+)
+
+func init() {
+	viabtc.RegisterWatchSink("websocket", New)
+}
+
+// Topic returns the topic of the market data.
+func Topic(market string, dataType viabtc.WatchDataType) string {
+	return market + "." + string(dataType)
+}
+
+// Config is an structure which holds configurable parameters of the
+// fan-out server.
+type Config struct {
+	// Buffer is the number of messages which might be queued for the
+	// single subscriber, messages are dropped for the subscribers which
+	// don't keep up.
+	Buffer int
+
+	// WriteTimeout is the timeout of the single write to the subscriber,
+	// the subscriber is disconnected if it is exceeded.
+	WriteTimeout time.Duration
+
+	// CheckOrigin, if specified, is used to validate the origin of the
+	// connection, any origin is allowed otherwise.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// request is the subscription request of the consumer.
+type request struct {
+	Op     string   `json:"op"`
+	Topics []string `json:"topics"`
+}
+
+// response is the reply of the server to the subscription request.
+type response struct {
+	Op     string   `json:"op,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// message is the event of the topic sent to the subscribers.
+type message struct {
+	Topic string             `json:"topic"`
+	Event *viabtc.WatchEvent `json:"event"`
+}
+
+// subscriber is the single connected consumer.
+type subscriber struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	topics map[string]struct{}
+
+	closeOnce sync.Once
+	quit      chan struct{}
+}
+
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+		s.conn.Close()
+	})
+}
+
+// Server serves the WebSocket endpoint and fans out the emitted events to
+// the subscribers of their topics.
+type Server struct {
+	cfg      Config
+	upgrader websocket.Upgrader
+
+	mtx         sync.Mutex
+	subscribers map[*subscriber]struct{}
+	topics      map[string]map[*subscriber]struct{}
+	last        map[string][]byte
+	closed      bool
+
+	// http is the server started by the sink factory.
+	http *http.Server
+
+	wg sync.WaitGroup
+}
+
+// A compile time check to ensure Server implements the viabtc.WatchSink
+// interface.
+var _ viabtc.WatchSink = (*Server)(nil)
+
+// A compile time check to ensure Server implements the http.Handler
+// interface.
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates new fan-out server, it should be mounted to the http
+// server by the caller.
+func NewServer(cfg *Config) *Server {
+	c := *cfg
+	if c.Buffer <= 0 {
+		c.Buffer = defaultBuffer
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = defaultWriteTimeout
+	}
+
+	s := &Server{
+		cfg:         c,
+		subscribers: make(map[*subscriber]struct{}),
+		topics:      make(map[string]map[*subscriber]struct{}),
+		last:        make(map[string][]byte),
+	}
+
+	s.upgrader.CheckOrigin = c.CheckOrigin
+	if s.upgrader.CheckOrigin == nil {
+		s.upgrader.CheckOrigin = func(*http.Request) bool {
+			return true
+		}
+	}
+
+	return s
+}
+
+// New creates the server which listens on the "addr" option of the spec and
+// serves the endpoint on the "path" option.
+func New(spec *viabtc.SinkSpec) (viabtc.WatchSink, error) {
+	addr := spec.Options["addr"]
+	if addr == "" {
+		return nil, errors.New("addr option should be specified")
+	}
+
+	path := spec.Options["path"]
+	if path == "" {
+		path = defaultPath
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v: %v", addr, err)
+	}
+
+	s := NewServer(&Config{})
+
+	mux := http.NewServeMux()
+	mux.Handle(path, s)
+	s.http = &http.Server{Handler: mux}
+
+	go s.http.Serve(listener)
+
+	return s, nil
+}
+
+// Emit sends the event to the subscribers of its topic, the subscribers
+// which haven't yet consumed the previous messages skip it.
+func (s *Server) Emit(event *viabtc.WatchEvent) error {
+	topic := Topic(event.Market, event.Type)
+
+	data, err := json.Marshal(&message{
+		Topic: topic,
+		Event: event,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return errors.New("server is closed")
+	}
+
+	s.last[topic] = data
+	for sub := range s.topics[topic] {
+		select {
+		case sub.send <- data:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribers returns the number of the subscribers of the topic.
+func (s *Server) Subscribers(topic string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.topics[topic])
+}
+
+// Topics returns the number of the subscribers of every topic which has
+// any.
+func (s *Server) Topics() map[string]int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	topics := make(map[string]int, len(s.topics))
+	for topic, subs := range s.topics {
+		topics[topic] = len(subs)
+	}
+
+	return topics
+}
+
+// Close disconnects all subscribers and stops the http server if it has
+// been started by the sink factory.
+func (s *Server) Close() error {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return nil
+	}
+	s.closed = true
+
+	for sub := range s.subscribers {
+		sub.close()
+	}
+	s.mtx.Unlock()
+
+	var err error
+	if s.http != nil {
+		err = s.http.Close()
+	}
+
+	s.wg.Wait()
+	return err
+}
+
+// ServeHTTP upgrades the connection and serves the subscriber until it
+// disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	sub := &subscriber{
+		conn:   conn,
+		send:   make(chan []byte, s.cfg.Buffer),
+		topics: make(map[string]struct{}),
+		quit:   make(chan struct{}),
+	}
+
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		conn.Close()
+		return
+	}
+	s.subscribers[sub] = struct{}{}
+	s.wg.Add(1)
+	s.mtx.Unlock()
+
+	defer s.wg.Done()
+	defer s.remove(sub)
+
+	go s.write(sub)
+	s.read(sub)
+}
+
+// remove unsubscribes the subscriber from all topics and closes it.
+func (s *Server) remove(sub *subscriber) {
+	s.mtx.Lock()
+	for topic := range sub.topics {
+		s.unsubscribe(sub, topic)
+	}
+	delete(s.subscribers, sub)
+	s.mtx.Unlock()
+
+	sub.close()
+}
+
+// unsubscribe removes the subscriber from the topic, mutex should be held.
+func (s *Server) unsubscribe(sub *subscriber, topic string) {
+	delete(sub.topics, topic)
+
+	subs := s.topics[topic]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(s.topics, topic)
+	}
+}
+
+// handle applies the subscription request and returns the reply.
+func (s *Server) handle(sub *subscriber, req *request) *response {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch req.Op {
+	case "subscribe":
+		for _, topic := range req.Topics {
+			if _, ok := sub.topics[topic]; ok {
+				continue
+			}
+			sub.topics[topic] = struct{}{}
+
+			subs, ok := s.topics[topic]
+			if !ok {
+				subs = make(map[*subscriber]struct{})
+				s.topics[topic] = subs
+			}
+			subs[sub] = struct{}{}
+
+			if data, ok := s.last[topic]; ok {
+				select {
+				case sub.send <- data:
+				default:
+				}
+			}
+		}
+
+	case "unsubscribe":
+		for _, topic := range req.Topics {
+			s.unsubscribe(sub, topic)
+		}
+
+	default:
+		return &response{Error: fmt.Sprintf("unknown op: %v", req.Op)}
+	}
+
+	return &response{Op: req.Op, Topics: req.Topics}
+}
+
+// read handles the subscription requests until the connection is closed.
+func (s *Server) read(sub *subscriber) {
+	for {
+		_, data, err := sub.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var resp *response
+		req := &request{}
+		if err := json.Unmarshal(data, req); err != nil {
+			resp = &response{Error: fmt.Sprintf("unable to parse "+
+				"request: %v", err)}
+		} else {
+			resp = s.handle(sub, req)
+		}
+
+		data, err = json.Marshal(resp)
+		if err != nil {
+			return
+		}
+
+		select {
+		case sub.send <- data:
+		case <-sub.quit:
+			return
+		}
+	}
+}
+
+// write sends the queued messages and the keepalive pings to the
+// subscriber.
+func (s *Server) write(sub *subscriber) {
+	defer sub.close()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-sub.send:
+			sub.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+			if err := sub.conn.WriteMessage(websocket.TextMessage,
+				data); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			sub.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+			if err := sub.conn.WriteMessage(websocket.PingMessage,
+				nil); err != nil {
+				return
+			}
+
+		case <-sub.quit:
+			return
+		}
+	}
+}